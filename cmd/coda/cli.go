@@ -0,0 +1,78 @@
+package main
+
+import (
+	"coda/internal/agent"
+	"coda/internal/llm"
+	"coda/internal/logger"
+	"coda/internal/review"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"go.uber.org/fx"
+)
+
+// cliDiscoveryTimeout bounds the model discovery run by the command-line client.
+const cliDiscoveryTimeout = 10 * time.Second
+
+// newReviewService builds and starts the review service used by the
+// command-line client. Reviews are kept in memory rather than in the review
+// history of the server, and the models installed on the provider servers are
+// discovered once. The returned stop function must be called when the command
+// exits: it flushes the pending traces and closes the repository of agentic
+// reviews and the completion cache.
+func newReviewService(ctx context.Context) (context.Context, *review.Service, func(), error) {
+	// Keep stdout for the command output
+	log := logger.NewConsole(os.Stderr, slog.LevelWarn)
+	logger.Default = log
+	ctx = logger.WithLogger(ctx, log)
+
+	// The command discovers the models once below rather than in the background
+	discover := !cfg.LLM.Discovery.Disabled
+	cfg.LLM.Discovery.Disabled = true
+
+	var (
+		svc      *review.Service
+		registry *llm.Registry
+	)
+	app := fx.New(
+		llm.Module,
+		agent.Module,
+		fx.Supply(cfg),
+		fx.Provide(func() review.Store { return review.NewMemoryStore() }),
+		fx.Provide(review.NewService),
+		fx.Populate(&svc, &registry),
+		fx.NopLogger,
+	)
+	if err := app.Err(); err != nil {
+		return nil, nil, nil, fmt.Errorf("initializing: %w", err)
+	}
+
+	startCtx, cancel := context.WithTimeout(ctx, app.StartTimeout())
+	defer cancel()
+	if err := app.Start(startCtx); err != nil {
+		return nil, nil, nil, fmt.Errorf("starting: %w", err)
+	}
+
+	stop := func() {
+		// Flush even when the command was interrupted
+		stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), app.StopTimeout())
+		defer cancel()
+		if err := app.Stop(stopCtx); err != nil {
+			logger.Warn(ctx, "shutdown failed", "err", err)
+		}
+	}
+
+	if discover {
+		discoveryCtx, cancel := context.WithTimeout(ctx, cliDiscoveryTimeout)
+		defer cancel()
+
+		if err := registry.Refresh(discoveryCtx); err != nil {
+			logger.Warn(ctx, "model discovery failed", "err", err)
+		}
+	}
+
+	return ctx, svc, stop, nil
+}
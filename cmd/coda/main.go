@@ -2,18 +2,17 @@ package main
 
 import (
 	"coda/internal/config"
-	"coda/internal/infrastructure"
-	"coda/internal/llm"
-	"coda/internal/review"
 	"context"
 	"fmt"
 	"log"
 	"os"
 
 	"github.com/joho/godotenv"
-	"go.uber.org/fx"
+	"github.com/spf13/cobra"
 
 	// Supported LLM providers
+	_ "coda/internal/llm/anthropic"
+	_ "coda/internal/llm/gemini"
 	_ "coda/internal/llm/ollama"
 	_ "coda/internal/llm/openai"
 )
@@ -31,29 +30,38 @@ func run() error {
 		return fmt.Errorf("loading .env file: %w", err)
 	}
 
-	var err error
-	cfg, err = config.Load(config.ENV(os.Getenv("ENV")), os.Getenv("CONFIG_DIR"))
-	if err != nil {
-		return fmt.Errorf("loading config: %w", err)
-	}
-
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	serverApp(ctx).Run()
-	return nil
+	return newRootCmd().ExecuteContext(ctx)
 }
 
-func serverApp(ctx context.Context) *fx.App {
-	var opts []fx.Option
-	opts = append(opts, infrastructure.Module)
-	opts = append(opts, llm.Module)
-	opts = append(opts, review.Module)
-	opts = append(opts, fx.Supply(cfg))
-	opts = append(opts, fx.Invoke(infrastructure.ServerLifetimeHooks))
-	if cfg.Global.Env != config.ENVLocal {
-		opts = append(opts, fx.NopLogger)
+// newRootCmd creates the coda command with its subcommands.
+// Without a subcommand it serves the web application, like the serve subcommand.
+func newRootCmd() *cobra.Command {
+	root := &cobra.Command{
+		Use:           "coda",
+		Short:         "AI code review server and command-line client",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.NoArgs,
+		// Configuration is loaded before running any subcommand
+		PersistentPreRunE: func(_ *cobra.Command, _ []string) error {
+			var err error
+			cfg, err = config.Load(config.ENV(os.Getenv("ENV")), os.Getenv("CONFIG_DIR"))
+			if err != nil {
+				return fmt.Errorf("loading config: %w", err)
+			}
+			return nil
+		},
+		RunE: runServe,
 	}
-	opts = append(opts, fx.Supply(ctx))
-	return fx.New(opts...)
+
+	root.AddCommand(
+		newServeCmd(),
+		newReviewCmd(),
+		newModelsCmd(),
+	)
+
+	return root
 }
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// newModelsCmd creates the models command, which lists the models available for reviews.
+func newModelsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "models",
+		Short: "List the models available for reviews",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			_, svc, stop, err := newReviewService(cmd.Context())
+			if err != nil {
+				return err
+			}
+			defer stop()
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "PROVIDER\tNAME\tDISPLAY NAME\tCONTEXT\tJSON")
+			for _, m := range svc.Models() {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%t\n", m.Provider, m.Name, m.DisplayName, m.ContextWindow, m.Capabilities.SupportsJSON)
+			}
+			return w.Flush()
+		},
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"coda/internal/review"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Output formats of the review command
+const (
+	formatMarkdown = "markdown"
+	formatJSON     = "json"
+)
+
+// reviewLevels are the accepted values of the detail and strictness flags.
+var reviewLevels = []string{"low", "medium", "high"}
+
+// reviewOptions contains the flags of the review command.
+type reviewOptions struct {
+	model      string
+	language   string
+	detail     string
+	strictness string
+	format     string
+	repo       string
+	noCache    bool
+}
+
+// newReviewCmd creates the review command, which reviews a file or the standard input.
+func newReviewCmd() *cobra.Command {
+	var opts reviewOptions
+
+	cmd := &cobra.Command{
+		Use:   "review [file|archive|-]...",
+		Short: "Review files, or the standard input when the file is - or omitted",
+		Long: `Review files, or the standard input when the file is - or omitted.
+Several files, or a zip or tar archive, are reviewed together as a module.`,
+		Example: `  coda review main.go
+  coda review handler.go service.go store.go
+  coda review module.tar.gz --format json
+  git diff | coda review - --format json
+  git format-patch -1 --stdout | coda review -
+  coda review internal/api/reviews.go --repo .`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				args = []string{"-"}
+			}
+			return runReview(cmd, args, opts)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&opts.model, "model", "m", "", "model name or display name (default: "+review.DefaultModel.Name+")")
+	flags.StringVarP(&opts.language, "language", "l", "", "programming language, or diff to review a patch (default: inferred from the file extension or content)")
+	flags.StringVar(&opts.detail, "detail", review.DefaultDetailLevel, "detail level: low, medium or high")
+	flags.StringVar(&opts.strictness, "strictness", review.DefaultStrictness, "strictness: low, medium or high")
+	flags.StringVarP(&opts.format, "format", "f", formatMarkdown, "output format: markdown or json")
+	flags.BoolVar(&opts.noCache, "no-cache", false, "review again instead of reusing the cached result of an identical review")
+	flags.StringVar(&opts.repo, "repo", "", "repository the model can read to look up referenced symbols (default: agent.repositoryPath of the configuration)")
+
+	return cmd
+}
+
+// runReview reviews the code read from paths and writes the review to stdout.
+func runReview(cmd *cobra.Command, paths []string, opts reviewOptions) error {
+	if !slices.Contains(reviewLevels, opts.detail) {
+		return fmt.Errorf("invalid detail level %q: must be one of %s", opts.detail, strings.Join(reviewLevels, ", "))
+	}
+	if !slices.Contains(reviewLevels, opts.strictness) {
+		return fmt.Errorf("invalid strictness %q: must be one of %s", opts.strictness, strings.Join(reviewLevels, ", "))
+	}
+	if opts.format != formatMarkdown && opts.format != formatJSON {
+		return fmt.Errorf("invalid format %q: must be %s or %s", opts.format, formatMarkdown, formatJSON)
+	}
+
+	var (
+		code  string
+		files []review.File
+		err   error
+	)
+	if len(paths) == 1 && !review.IsArchive(paths[0]) {
+		code, err = readCode(cmd.InOrStdin(), paths[0])
+	} else {
+		files, err = readFiles(paths)
+	}
+	if err != nil {
+		return err
+	}
+
+	language := opts.language
+	if language == "" {
+		language = review.LanguageFromPath(paths[0])
+	}
+
+	if opts.repo != "" {
+		cfg.Agent.RepositoryPath = opts.repo
+	}
+
+	ctx, svc, stop, err := newReviewService(cmd.Context())
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	model, err := svc.ResolveModel(opts.model)
+	if err != nil {
+		return err
+	}
+
+	req := review.Request{
+		Code:        code,
+		Files:       files,
+		Language:    language,
+		DetailLevel: opts.detail,
+		Strictness:  opts.strictness,
+		Model:       model,
+		NoCache:     opts.noCache,
+	}
+
+	out := cmd.OutOrStdout()
+	if opts.format == formatJSON {
+		rv, err := svc.Review(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rv)
+	}
+
+	// Print Markdown reviews as they are generated, and the progress of
+	// chunked reviews to stderr
+	var printed int
+	rv, err := svc.StreamReview(ctx, req, func(update review.StreamUpdate) error {
+		if update.Parts > 0 {
+			_, err := fmt.Fprintf(cmd.ErrOrStderr(), "Reviewed part %d/%d\n", update.Part, update.Parts)
+			return err
+		}
+		_, err := io.WriteString(out, update.Content[printed:])
+		printed = len(update.Content)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// Structured reviews are only available once complete
+	if printed == 0 {
+		_, err = io.WriteString(out, rv.Markdown())
+		return err
+	}
+	_, err = io.WriteString(out, "\n")
+	return err
+}
+
+// readCode reads the code to review from a file, or from r when path is -.
+func readCode(r io.Reader, path string) (string, error) {
+	var (
+		data []byte
+		err  error
+	)
+	if path == "-" {
+		data, err = io.ReadAll(io.LimitReader(r, review.MaxCodeLength+1))
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading code: %w", err)
+	}
+
+	if strings.TrimSpace(string(data)) == "" {
+		return "", fmt.Errorf("no code to review")
+	}
+	if len(data) > review.MaxCodeLength {
+		return "", fmt.Errorf("code is too long: the limit is %d characters", review.MaxCodeLength)
+	}
+	return string(data), nil
+}
+
+// readFiles reads the files of a module review, expanding archives.
+func readFiles(paths []string) ([]review.File, error) {
+	var files []review.File
+	for _, path := range paths {
+		if path == "-" {
+			return nil, fmt.Errorf("the standard input cannot be reviewed with other files")
+		}
+
+		if review.IsArchive(path) {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, fmt.Errorf("reading files: %w", err)
+			}
+			archived, err := review.ReadUpload(path, f)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, archived...)
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading files: %w", err)
+		}
+		files = append(files, review.File{Path: filepath.ToSlash(filepath.Clean(path)), Content: string(data)})
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no source files to review")
+	}
+	return files, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"coda/internal/agent"
+	"coda/internal/config"
+	"coda/internal/infrastructure"
+	"coda/internal/llm"
+	"coda/internal/review"
+	"coda/internal/telemetry"
+	"context"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/fx"
+)
+
+// newServeCmd creates the serve command, which runs the web application.
+func newServeCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the web application and the JSON API",
+		Args:  cobra.NoArgs,
+		RunE:  runServe,
+	}
+}
+
+// runServe runs the web application until it receives a termination signal.
+func runServe(cmd *cobra.Command, _ []string) error {
+	serverApp(cmd.Context()).Run()
+	return nil
+}
+
+func serverApp(ctx context.Context) *fx.App {
+	var opts []fx.Option
+	opts = append(opts, infrastructure.Module)
+	opts = append(opts, llm.Module)
+	opts = append(opts, agent.Module)
+	opts = append(opts, review.Module)
+	opts = append(opts, telemetry.Module)
+	opts = append(opts, fx.Supply(cfg))
+	opts = append(opts, fx.Invoke(infrastructure.ServerLifetimeHooks))
+	if cfg.Global.Env != config.ENVLocal {
+		opts = append(opts, fx.NopLogger)
+	}
+	opts = append(opts, fx.Supply(ctx))
+	return fx.New(opts...)
+}
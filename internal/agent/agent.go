@@ -0,0 +1,336 @@
+// Package agent runs language models in a loop with tools, letting them read a
+// repository to look up the symbols referenced by the code they review.
+package agent
+
+import (
+	"coda/internal/config"
+	"coda/internal/llm"
+	"coda/internal/llm/langfuse"
+	"coda/internal/logger"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofrs/uuid/v5"
+)
+
+// Default limits of a run
+const (
+	DefaultMaxSteps  = 8
+	DefaultMaxTokens = 100_000
+)
+
+// finalInstruction is sent to the model when the limits of the run are reached,
+// to get an answer without further tool calls.
+const finalInstruction = "You cannot call tools anymore. Answer now with the information you have."
+
+// ErrNoTools is returned when a run is started without tools.
+var ErrNoTools = errors.New("no tools")
+
+// Limits bounds the work of a run.
+type Limits struct {
+	MaxSteps  int // Maximum number of steps with tool calls
+	MaxTokens int // Tokens after which the model must answer without tools
+}
+
+// Runner drives a Completer in a loop: the model is called with the tools,
+// the tools it calls are run and their results sent back, until the model
+// answers without calling tools or the limits are reached.
+type Runner struct {
+	completer   llm.Completer
+	langfuse    *langfuse.Exporter
+	tools       []Tool
+	limits      Limits
+	environment string
+}
+
+// NewRunner creates a Runner calling the tools. Unset limits default to
+// DefaultMaxSteps and DefaultMaxTokens. Runs are sent to Langfuse through the
+// exporter, if not nil.
+func NewRunner(cfg *config.Config, completer llm.Completer, exporter *langfuse.Exporter, tools []Tool, limits Limits) *Runner {
+	if limits.MaxSteps <= 0 {
+		limits.MaxSteps = DefaultMaxSteps
+	}
+	if limits.MaxTokens <= 0 {
+		limits.MaxTokens = DefaultMaxTokens
+	}
+
+	r := &Runner{
+		completer:   completer,
+		langfuse:    exporter,
+		tools:       tools,
+		limits:      limits,
+		environment: "development",
+	}
+	if cfg.Global.Env == config.ENVProduction {
+		r.environment = "production"
+	}
+	return r
+}
+
+// Step is a model call of a run and the tools it called.
+type Step struct {
+	Number int
+	Calls  []ToolCall
+	Usage  llm.Usage
+}
+
+// ToolCall is a tool called by the model and its result.
+type ToolCall struct {
+	Call   *llm.FunctionCall
+	Output string // Result sent back to the model, the error message if the tool failed
+	Err    error
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	Response *llm.CompleteResponse // Final answer of the model
+	Messages []llm.Message         // Conversation, tool calls and results included
+	Steps    []Step
+	Tokens   int       // Tokens used by all the steps
+	Cost     *llm.Cost // Estimated cost of all the steps, nil for unpriced models
+}
+
+// Run completes the prompt, letting the model call the tools of the runner.
+// Tool failures are reported to the model rather than ending the run. When the
+// step or token limit is reached, the model is asked to answer without tools.
+// Every step is recorded as a Langfuse span.
+func (r *Runner) Run(ctx context.Context, params llm.CompleteParams, model llm.Model) (*Result, error) {
+	if len(r.tools) == 0 {
+		return nil, ErrNoTools
+	}
+
+	tools := make(map[string]Tool, len(r.tools))
+	params.Functions = nil
+	for _, t := range r.tools {
+		tools[t.Definition.Name] = t
+		params.Functions = append(params.Functions, t.Definition)
+	}
+	params.Messages = append([]llm.Message(nil), params.Messages...)
+
+	trace := r.newTrace(params, model)
+	defer trace.send()
+
+	res := &Result{}
+	for step := 1; ; step++ {
+		final := len(params.Functions) == 0
+		span := trace.startStep(step, final)
+
+		ret, err := r.completer.Complete(ctx, params, model)
+		if err != nil {
+			span.end(nil, err)
+			return nil, err
+		}
+
+		usage := stepUsage(params.Messages, ret)
+		res.Tokens += usage.TotalTokens
+		res.Cost = res.Cost.Add(ret.Metadata.Cost)
+		calls := ret.FunctionCalls()
+		if len(calls) == 0 || final {
+			span.end(&Step{Number: step, Usage: usage}, nil)
+			trace.end(ret, res.Tokens)
+			res.Response = ret
+			res.Messages = append(params.Messages, ret.Messages...)
+			return res, nil
+		}
+
+		// Run the tools and send their results back
+		s := Step{Number: step, Usage: usage}
+		params.Messages = append(params.Messages, ret.Messages...)
+		for _, call := range calls {
+			tc := r.call(ctx, tools, call)
+			span.tool(tc)
+			s.Calls = append(s.Calls, tc)
+			params.Messages = append(params.Messages, llm.NewFunctionResultMessage(call, tc.Output))
+		}
+		span.end(&s, nil)
+		res.Steps = append(res.Steps, s)
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if step >= r.limits.MaxSteps || res.Tokens >= r.limits.MaxTokens {
+			logger.Info(ctx, "agent limits reached",
+				"model", model.Name,
+				"steps", step,
+				"tokens", res.Tokens)
+			params.Functions = nil
+			params.Messages = append(params.Messages, llm.NewUserMessage(finalInstruction))
+		}
+	}
+}
+
+// call runs the tool called by the model.
+func (r *Runner) call(ctx context.Context, tools map[string]Tool, call *llm.FunctionCall) ToolCall {
+	tc := ToolCall{Call: call}
+
+	tool, ok := tools[call.Name]
+	if !ok {
+		tc.Err = fmt.Errorf("%w: %s", llm.ErrFunctionNotFound, call.Name)
+	} else {
+		tc.Output, tc.Err = tool.Run(ctx, call.Arguments)
+	}
+
+	if tc.Err != nil {
+		tc.Output = "Error: " + tc.Err.Error()
+		logger.Info(ctx, "agent tool failed", "tool", call.Name, "err", tc.Err)
+	}
+	return tc
+}
+
+// stepUsage returns the tokens used by a model call, estimated from the
+// messages when the provider does not report them.
+func stepUsage(messages []llm.Message, ret *llm.CompleteResponse) llm.Usage {
+	if ret.Usage != nil && ret.Usage.TotalTokens > 0 {
+		return *ret.Usage
+	}
+
+	prompt := llm.EstimateMessageTokens(messages)
+	completion := llm.EstimateMessageTokens(ret.Messages)
+	for _, call := range ret.FunctionCalls() {
+		completion += llm.EstimateTokens(call.Name + call.Arguments)
+	}
+	return llm.Usage{
+		Unit:             "tokens",
+		PromptTokens:     prompt,
+		CompletionTokens: completion,
+		TotalTokens:      prompt + completion,
+	}
+}
+
+// trace records a run in Langfuse: a trace with a span per step, and a child
+// span per tool call. The events are sent when the run ends.
+type trace struct {
+	runner *Runner
+	id     string
+	events []langfuse.Event
+}
+
+// newTrace starts the trace of a run, or returns nil if Langfuse is not configured.
+func (r *Runner) newTrace(params llm.CompleteParams, model llm.Model) *trace {
+	if r.langfuse == nil {
+		return nil
+	}
+
+	t := &trace{runner: r, id: newID()}
+	t.events = append(t.events, langfuse.CreateTrace(newID(), langfuse.TraceBody{
+		ID:          t.id,
+		Name:        "Agent Run",
+		Input:       params.Messages,
+		Timestamp:   now(),
+		Environment: r.environment,
+		Tags:        []string{"agent", model.Name, string(model.Provider)},
+	}))
+	return t
+}
+
+// end records the final answer of the run.
+func (t *trace) end(ret *llm.CompleteResponse, tokens int) {
+	if t == nil {
+		return
+	}
+	t.events = append(t.events, langfuse.CreateTrace(newID(), langfuse.TraceBody{
+		ID:       t.id,
+		Output:   ret.Messages[0].Content,
+		Metadata: map[string]any{"tokens": tokens},
+	}))
+}
+
+// send queues the events of the trace to be sent to Langfuse in the background.
+func (t *trace) send() {
+	if t == nil || len(t.events) == 0 {
+		return
+	}
+	t.runner.langfuse.Enqueue(t.events...)
+}
+
+// stepSpan is the span of a step.
+type stepSpan struct {
+	trace *trace
+	body  langfuse.SpanBody
+}
+
+// startStep starts the span of a step.
+func (t *trace) startStep(number int, final bool) *stepSpan {
+	if t == nil {
+		return nil
+	}
+	return &stepSpan{
+		trace: t,
+		body: langfuse.SpanBody{
+			ID:          newID(),
+			TraceID:     t.id,
+			Name:        fmt.Sprintf("Step %d", number),
+			StartTime:   now(),
+			Environment: t.runner.environment,
+			Metadata:    map[string]any{"final": final},
+		},
+	}
+}
+
+// tool records a tool call as a child span of the step.
+func (s *stepSpan) tool(tc ToolCall) {
+	if s == nil {
+		return
+	}
+
+	body := langfuse.SpanBody{
+		ID:                  newID(),
+		TraceID:             s.trace.id,
+		ParentObservationID: s.body.ID,
+		Name:                "Tool " + tc.Call.Name,
+		StartTime:           now(),
+		EndTime:             now(),
+		Input:               tc.Call.Arguments,
+		Output:              tc.Output,
+		Level:               "DEFAULT",
+		Environment:         s.trace.runner.environment,
+	}
+	if tc.Err != nil {
+		body.Level = "WARNING"
+		body.StatusMessage = tc.Err.Error()
+	}
+	s.trace.events = append(s.trace.events, langfuse.CreateSpan(newID(), body))
+}
+
+// end records the step, or the error that ended it.
+func (s *stepSpan) end(step *Step, err error) {
+	if s == nil {
+		return
+	}
+
+	s.body.EndTime = now()
+	s.body.Level = "DEFAULT"
+	if err != nil {
+		s.body.Level = "ERROR"
+		s.body.StatusMessage = err.Error()
+	}
+	if step != nil {
+		var calls []*llm.FunctionCall
+		for _, tc := range step.Calls {
+			calls = append(calls, tc.Call)
+		}
+		s.body.Input = calls
+		s.body.Metadata = map[string]any{
+			"final":  len(calls) == 0,
+			"tokens": step.Usage.TotalTokens,
+		}
+	}
+	s.trace.events = append(s.trace.events, langfuse.CreateSpan(newID(), s.body))
+}
+
+// newID returns a new Langfuse event or observation ID.
+func newID() string {
+	u, err := uuid.NewV7()
+	if err != nil {
+		u, _ = uuid.NewV4()
+	}
+	return u.String()
+}
+
+// now returns the current time in the Langfuse timestamp format.
+func now() string {
+	return time.Now().UTC().Format(time.RFC3339Nano)
+}
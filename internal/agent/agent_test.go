@@ -0,0 +1,114 @@
+package agent
+
+import (
+	"coda/internal/config"
+	"coda/internal/llm"
+	"context"
+	"errors"
+	"testing"
+)
+
+// scriptedCompleter calls the echo tool until it is given no functions, then answers.
+type scriptedCompleter struct {
+	llm.Completer
+	calls  int
+	params []llm.CompleteParams
+}
+
+func (c *scriptedCompleter) Complete(_ context.Context, params llm.CompleteParams, _ llm.Model) (*llm.CompleteResponse, error) {
+	c.calls++
+	c.params = append(c.params, params)
+
+	if len(params.Functions) == 0 || c.calls == 3 {
+		return &llm.CompleteResponse{Messages: []llm.Message{{Role: llm.RoleAssistant, Content: "done"}}}, nil
+	}
+	return &llm.CompleteResponse{
+		Messages: []llm.Message{{
+			Role:         llm.RoleAssistant,
+			FunctionCall: &llm.FunctionCall{ID: "call_1", Name: "echo", Arguments: `{"text": "hi"}`},
+		}},
+		Usage: &llm.Usage{TotalTokens: 100},
+	}, nil
+}
+
+func echoTool() Tool {
+	return Tool{
+		Definition: llm.FunctionDefinition{Name: "echo"},
+		Run: func(_ context.Context, args string) (string, error) {
+			return args, nil
+		},
+	}
+}
+
+func TestRunner(t *testing.T) {
+	tests := []struct {
+		name      string
+		limits    Limits
+		wantSteps int
+		wantCalls int
+		wantFinal bool // The last call was made without tools
+	}{
+		{
+			name:      "AnswersAfterTools",
+			limits:    Limits{MaxSteps: 5},
+			wantSteps: 2,
+			wantCalls: 3,
+		},
+		{
+			name:      "StepLimit",
+			limits:    Limits{MaxSteps: 1},
+			wantSteps: 1,
+			wantCalls: 2,
+			wantFinal: true,
+		},
+		{
+			name:      "TokenLimit",
+			limits:    Limits{MaxSteps: 5, MaxTokens: 100},
+			wantSteps: 1,
+			wantCalls: 2,
+			wantFinal: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			completer := &scriptedCompleter{}
+			runner := NewRunner(&config.Config{}, completer, nil, []Tool{echoTool()}, tt.limits)
+
+			res, err := runner.Run(context.Background(), llm.CompleteParams{
+				Messages: []llm.Message{llm.NewUserMessage("review")},
+			}, llm.Model{Name: "test"})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if res.Response.Messages[0].Content != "done" {
+				t.Errorf("answer = %q, want %q", res.Response.Messages[0].Content, "done")
+			}
+			if len(res.Steps) != tt.wantSteps {
+				t.Errorf("got %d steps, want %d", len(res.Steps), tt.wantSteps)
+			}
+			if completer.calls != tt.wantCalls {
+				t.Errorf("got %d model calls, want %d", completer.calls, tt.wantCalls)
+			}
+
+			last := completer.params[len(completer.params)-1]
+			if final := len(last.Functions) == 0; final != tt.wantFinal {
+				t.Errorf("last call without tools = %v, want %v", final, tt.wantFinal)
+			}
+
+			// The tool result answers the call
+			result := completer.params[1].Messages[2]
+			if result.Role != llm.RoleFunction || result.CallID != "call_1" || result.Content != `{"text": "hi"}` {
+				t.Errorf("tool result = %+v", result)
+			}
+		})
+	}
+}
+
+func TestRunnerNoTools(t *testing.T) {
+	runner := NewRunner(&config.Config{}, &scriptedCompleter{}, nil, nil, Limits{})
+	if _, err := runner.Run(context.Background(), llm.CompleteParams{}, llm.Model{}); !errors.Is(err, ErrNoTools) {
+		t.Errorf("Run() error = %v, want %v", err, ErrNoTools)
+	}
+}
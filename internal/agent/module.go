@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"coda/internal/config"
+	"coda/internal/llm"
+	"coda/internal/llm/langfuse"
+	"context"
+
+	"go.uber.org/fx"
+)
+
+// Module is the fx module for the agent package.
+// It provides a Runner over the configured repository, or a nil Runner when
+// agentic reviews are disabled.
+var Module = fx.Module("agent",
+	fx.Provide(newRunner),
+)
+
+// newRunner opens the configured repository and closes it when the application stops.
+func newRunner(lc fx.Lifecycle, cfg *config.Config, completer llm.Completer, exporter *langfuse.Exporter) (*Runner, error) {
+	if cfg.Agent.RepositoryPath == "" {
+		return nil, nil
+	}
+
+	repo, err := OpenRepository(cfg.Agent.RepositoryPath)
+	if err != nil {
+		return nil, err
+	}
+
+	lc.Append(fx.Hook{
+		OnStop: func(_ context.Context) error {
+			return repo.Close()
+		},
+	})
+
+	return NewRunner(cfg, completer, exporter, repo.Tools(), Limits{
+		MaxSteps:  cfg.Agent.MaxSteps,
+		MaxTokens: cfg.Agent.MaxTokens,
+	}), nil
+}
@@ -0,0 +1,358 @@
+package agent
+
+import (
+	"bufio"
+	"bytes"
+	"coda/internal/llm"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits of the output of the repository tools, keeping tool results small
+// enough for the context window
+const (
+	maxOutputBytes = 16 << 10 // Output is truncated beyond this size
+	maxReadLines   = 400      // Maximum number of lines returned by read_file
+	maxGrepMatches = 50       // Maximum number of matches returned by grep
+	maxListEntries = 500      // Maximum number of entries returned by list_directory
+	maxSearchSize  = 1 << 20  // Files larger than this are not searched
+)
+
+// ErrOutsideRepository is returned when a tool is given a path outside the repository.
+var ErrOutsideRepository = errors.New("path is outside the repository")
+
+// skippedDirs are directories not searched by grep, such as dependencies and
+// build output.
+var skippedDirs = []string{".git", "node_modules", "vendor", "dist", "build", "target", "__pycache__"}
+
+// Tool is a function the model can call during a run.
+type Tool struct {
+	Definition llm.FunctionDefinition
+	Run        func(ctx context.Context, args string) (string, error) // Arguments are a JSON object
+}
+
+// Repository is a read-only view of a local repository. Paths are relative to
+// its root and cannot escape it, through symbolic links included.
+type Repository struct {
+	dir  string
+	root *os.Root
+	fsys fs.FS
+}
+
+// OpenRepository opens the repository in the directory.
+func OpenRepository(dir string) (*Repository, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, fmt.Errorf("opening repository %s: %w", dir, err)
+	}
+	return &Repository{dir: dir, root: root, fsys: root.FS()}, nil
+}
+
+// Close releases the repository.
+func (r *Repository) Close() error {
+	return r.root.Close()
+}
+
+// Tools returns the tools reading the repository: read_file, list_directory,
+// grep and git_blame.
+func (r *Repository) Tools() []Tool {
+	return []Tool{
+		{
+			Definition: llm.FunctionDefinition{
+				Name:        "read_file",
+				Description: fmt.Sprintf("Read lines of a file of the repository, prefixed with their line numbers. At most %d lines are returned.", maxReadLines),
+				Parameters: object(map[string]any{
+					"path":      property("string", "Path of the file relative to the repository root"),
+					"startLine": property("integer", "First line to read, starting at 1 (default: 1)"),
+					"endLine":   property("integer", "Last line to read (default: the end of the file)"),
+				}, "path"),
+			},
+			Run: r.readFile,
+		},
+		{
+			Definition: llm.FunctionDefinition{
+				Name:        "list_directory",
+				Description: "List the files and directories of a directory of the repository. Directories end with a slash.",
+				Parameters: object(map[string]any{
+					"path": property("string", "Path of the directory relative to the repository root (default: the root)"),
+				}),
+			},
+			Run: r.listDirectory,
+		},
+		{
+			Definition: llm.FunctionDefinition{
+				Name:        "grep",
+				Description: fmt.Sprintf("Search the files of the repository for lines matching a regular expression, such as the definition of a symbol. At most %d matches are returned as path:line: text.", maxGrepMatches),
+				Parameters: object(map[string]any{
+					"pattern": property("string", "Regular expression in Go RE2 syntax"),
+					"path":    property("string", "File or directory to search, relative to the repository root (default: the root)"),
+					"include": property("string", "Glob matching the names of the files to search, such as *.go"),
+				}, "pattern"),
+			},
+			Run: r.grep,
+		},
+		{
+			Definition: llm.FunctionDefinition{
+				Name:        "git_blame",
+				Description: "Show the commit, author and date of the last change of lines of a file of the repository.",
+				Parameters: object(map[string]any{
+					"path":      property("string", "Path of the file relative to the repository root"),
+					"startLine": property("integer", "First line, starting at 1 (default: 1)"),
+					"endLine":   property("integer", "Last line (default: 50 lines from the first)"),
+				}, "path"),
+			},
+			Run: r.gitBlame,
+		},
+	}
+}
+
+// fileArgs are the arguments of the tools reading a file.
+type fileArgs struct {
+	Path      string `json:"path"`
+	StartLine int    `json:"startLine"`
+	EndLine   int    `json:"endLine"`
+}
+
+// readFile returns numbered lines of a file.
+func (r *Repository) readFile(_ context.Context, args string) (string, error) {
+	var a fileArgs
+	if err := json.Unmarshal([]byte(args), &a); err != nil {
+		return "", err
+	}
+	name, err := r.clean(a.Path)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := fs.ReadFile(r.fsys, name)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(data) || bytes.ContainsRune(data, 0) {
+		return "", fmt.Errorf("%s is a binary file", name)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	start := max(a.StartLine, 1)
+	end := a.EndLine
+	if end < start || end > len(lines) {
+		end = len(lines)
+	}
+	if start > len(lines) {
+		return "", fmt.Errorf("%s has %d lines", name, len(lines))
+	}
+
+	var b strings.Builder
+	for i := start; i <= end && i < start+maxReadLines; i++ {
+		fmt.Fprintf(&b, "%d | %s\n", i, lines[i-1])
+	}
+	if end >= start+maxReadLines {
+		fmt.Fprintf(&b, "[%d more lines: read from line %d]\n", end-start-maxReadLines+1, start+maxReadLines)
+	}
+	return truncate(b.String()), nil
+}
+
+// listDirectory returns the entries of a directory.
+func (r *Repository) listDirectory(_ context.Context, args string) (string, error) {
+	var a struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal([]byte(args), &a); err != nil {
+		return "", err
+	}
+	name, err := r.clean(a.Path)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := fs.ReadDir(r.fsys, name)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for i, e := range entries {
+		if i == maxListEntries {
+			fmt.Fprintf(&b, "[%d more entries]\n", len(entries)-maxListEntries)
+			break
+		}
+		b.WriteString(e.Name())
+		if e.IsDir() {
+			b.WriteString("/")
+		}
+		b.WriteString("\n")
+	}
+	return truncate(b.String()), nil
+}
+
+// grep returns the lines of the files matching a regular expression.
+func (r *Repository) grep(ctx context.Context, args string) (string, error) {
+	var a struct {
+		Pattern string `json:"pattern"`
+		Path    string `json:"path"`
+		Include string `json:"include"`
+	}
+	if err := json.Unmarshal([]byte(args), &a); err != nil {
+		return "", err
+	}
+	re, err := regexp.Compile(a.Pattern)
+	if err != nil {
+		return "", err
+	}
+	if _, err := path.Match(a.Include, ""); err != nil {
+		return "", fmt.Errorf("invalid include glob: %w", err)
+	}
+	name, err := r.clean(a.Path)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	matches := 0
+	err = fs.WalkDir(r.fsys, name, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			if p != name && slices.Contains(skippedDirs, d.Name()) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if a.Include != "" {
+			if ok, _ := path.Match(a.Include, d.Name()); !ok {
+				return nil
+			}
+		}
+		if info, err := d.Info(); err != nil || info.Size() > maxSearchSize {
+			return nil
+		}
+
+		data, err := fs.ReadFile(r.fsys, p)
+		if err != nil || bytes.ContainsRune(data, 0) {
+			return nil
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, maxSearchSize)
+		for line := 1; scanner.Scan(); line++ {
+			if !re.Match(scanner.Bytes()) {
+				continue
+			}
+			if matches == maxGrepMatches {
+				b.WriteString("[more matches: narrow the pattern or the path]\n")
+				return fs.SkipAll
+			}
+			fmt.Fprintf(&b, "%s:%d: %s\n", p, line, strings.TrimSpace(scanner.Text()))
+			matches++
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if matches == 0 {
+		return "No matches", nil
+	}
+	return truncate(b.String()), nil
+}
+
+// gitBlame returns the last change of lines of a file.
+func (r *Repository) gitBlame(ctx context.Context, args string) (string, error) {
+	var a fileArgs
+	if err := json.Unmarshal([]byte(args), &a); err != nil {
+		return "", err
+	}
+	name, err := r.clean(a.Path)
+	if err != nil {
+		return "", err
+	}
+
+	// Check the file through the repository root so that links cannot escape it
+	if info, err := fs.Stat(r.fsys, name); err != nil {
+		return "", err
+	} else if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", name)
+	}
+
+	start := max(a.StartLine, 1)
+	end := a.EndLine
+	if end < start {
+		end = start + 49
+	}
+	end = min(end, start+maxReadLines-1)
+
+	cmd := exec.CommandContext(ctx, "git", "-C", r.dir, "blame", "--date=short", "-L", fmt.Sprintf("%d,%d", start, end), "--", name)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("git blame: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("git blame: %w", err)
+	}
+	return truncate(string(out)), nil
+}
+
+// clean converts a path given by the model to a path of the repository file
+// system. Absolute paths and paths escaping the root are rejected.
+func (r *Repository) clean(name string) (string, error) {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "./")
+	if name == "" || name == "/" {
+		return ".", nil
+	}
+	if path.IsAbs(name) {
+		return "", fmt.Errorf("%w: %s is absolute", ErrOutsideRepository, name)
+	}
+
+	name = path.Clean(name)
+	if !fs.ValidPath(name) {
+		return "", fmt.Errorf("%w: %s", ErrOutsideRepository, name)
+	}
+	return name, nil
+}
+
+// truncate shortens a tool output to maxOutputBytes.
+func truncate(s string) string {
+	if len(s) <= maxOutputBytes {
+		return s
+	}
+	cut := maxOutputBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "\n[output truncated]\n"
+}
+
+// object returns the JSON schema of an object with the properties.
+func object(properties map[string]any, required ...string) map[string]any {
+	schema := map[string]any{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// property returns the JSON schema of a property.
+func property(typ, description string) map[string]any {
+	return map[string]any{
+		"type":        typ,
+		"description": description,
+	}
+}
@@ -0,0 +1,108 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRepositoryTools(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {\n\tgreet()\n}\n")
+	writeFile(t, filepath.Join(dir, "greet", "greet.go"), "package greet\n\nfunc greet() {}\n")
+	writeFile(t, filepath.Join(dir, "node_modules", "lib.js"), "function greet() {}\n")
+	writeFile(t, filepath.Join(filepath.Dir(dir), "secret.txt"), "secret\n")
+	if err := os.Symlink(filepath.Join(filepath.Dir(dir), "secret.txt"), filepath.Join(dir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := OpenRepository(dir)
+	if err != nil {
+		t.Fatalf("OpenRepository() error = %v", err)
+	}
+	defer repo.Close()
+
+	tools := make(map[string]Tool)
+	for _, tool := range repo.Tools() {
+		tools[tool.Definition.Name] = tool
+	}
+
+	tests := []struct {
+		name    string
+		tool    string
+		args    string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "ReadFile",
+			tool: "read_file",
+			args: `{"path": "main.go", "startLine": 3, "endLine": 4}`,
+			want: "3 | func main() {\n4 | \tgreet()\n",
+		},
+		{
+			name:    "ReadFileOutside",
+			tool:    "read_file",
+			args:    `{"path": "../secret.txt"}`,
+			wantErr: true,
+		},
+		{
+			name:    "ReadFileAbsolute",
+			tool:    "read_file",
+			args:    `{"path": "/etc/passwd"}`,
+			wantErr: true,
+		},
+		{
+			name:    "ReadFileLinkOutside",
+			tool:    "read_file",
+			args:    `{"path": "link.txt"}`,
+			wantErr: true,
+		},
+		{
+			name: "ListDirectory",
+			tool: "list_directory",
+			args: `{}`,
+			want: "greet/\nlink.txt\nmain.go\nnode_modules/\n",
+		},
+		{
+			name: "Grep",
+			tool: "grep",
+			args: `{"pattern": "func greet"}`,
+			want: "greet/greet.go:3: func greet() {}\n",
+		},
+		{
+			name: "GrepNoMatches",
+			tool: "grep",
+			args: `{"pattern": "func missing", "include": "*.go"}`,
+			want: "No matches",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tools[tt.tool].Run(context.Background(), tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("%s error = %v, wantErr %v", tt.tool, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("%s = %q, want %q", tt.tool, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := tools["read_file"].Run(context.Background(), `{"path": "../secret.txt"}`); !errors.Is(err, ErrOutsideRepository) {
+		t.Errorf("read_file error = %v, want %v", err, ErrOutsideRepository)
+	}
+}
+
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,38 @@
+// Package api provides the versioned JSON API for running code reviews from
+// scripts and editor plugins. It shares the review pipeline with the web UI.
+package api
+
+import (
+	"coda/internal/review"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-playground/validator"
+)
+
+// API serves the JSON API.
+type API struct {
+	reviews  *review.Service
+	validate *validator.Validate
+}
+
+// newAPI creates a new API backed by the given review service.
+func newAPI(reviews *review.Service) *API {
+	return &API{
+		reviews:  reviews,
+		validate: validator.New(),
+	}
+}
+
+// ConfigureRoutes sets up the API routes.
+// This function is called by the infrastructure layer during server initialization.
+func ConfigureRoutes(a *API, r *chi.Mux) {
+	r.Route("/api/v1", a.RegisterRoutes)
+}
+
+// RegisterRoutes registers the version 1 API routes.
+func (a *API) RegisterRoutes(r chi.Router) {
+	r.Get("/models", a.listModels)
+	r.Get("/reviews", a.listReviews)
+	r.Post("/reviews", a.createReview)
+	r.Get("/reviews/{id}", a.getReview)
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"coda/internal/llm"
+	"coda/internal/logger"
+	"coda/internal/review"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator"
+)
+
+// Request errors
+var (
+	errInvalidBody      = errors.New("invalid request body")
+	errInvalidParameter = errors.New("invalid parameter")
+)
+
+// errorResponse is the body of an error response.
+type errorResponse struct {
+	Error errorDetail `json:"error"`
+}
+
+// errorDetail describes an error.
+type errorDetail struct {
+	Code    string            `json:"code"`             // Machine-readable error code
+	Message string            `json:"message"`          // Human-readable error message
+	Fields  map[string]string `json:"fields,omitempty"` // Failed validation rule per field
+}
+
+// apiError maps an error to an HTTP status code, an error code and the message
+// returned to clients, which never includes the details of the error.
+type apiError struct {
+	err     error
+	status  int
+	code    string
+	message string
+}
+
+// apiErrors lists the errors returned to clients, checked in order.
+// Unlisted errors are reported as internal errors.
+var apiErrors = []apiError{
+	{errInvalidBody, http.StatusBadRequest, "invalid_body", "invalid request body"},
+	{errInvalidParameter, http.StatusBadRequest, "invalid_parameter", "invalid query parameter"},
+	{review.ErrUnknownModel, http.StatusBadRequest, "unknown_model", "unknown model"},
+	{review.ErrInvalidDiff, http.StatusBadRequest, "invalid_diff", "invalid diff"},
+	{review.ErrTooManyFiles, http.StatusBadRequest, "too_many_files", "too many files"},
+	{review.ErrNotFound, http.StatusNotFound, "not_found", "review not found"},
+	{llm.ErrContextLengthExceeded, http.StatusRequestEntityTooLarge, "context_length_exceeded", "the code exceeds the context length of the model"},
+	{llm.ErrTokenLimitReached, http.StatusRequestEntityTooLarge, "token_limit_reached", "the review exceeds the token limit of the model"},
+	{llm.ErrContentFiltered, http.StatusUnprocessableEntity, "content_filtered", "the review was blocked by the content filter of the provider"},
+	{llm.ErrContentNotAllowed, http.StatusUnprocessableEntity, "content_not_allowed", "the content is not allowed by the provider"},
+	{llm.ErrTooManyRequests, http.StatusTooManyRequests, "too_many_requests", "too many requests to the model provider"},
+	{llm.ErrRateLimited, http.StatusTooManyRequests, "rate_limited", "rate limited by the model provider"},
+	{llm.ErrBudgetExceeded, http.StatusTooManyRequests, "budget_exceeded", "review budget exceeded"},
+	{llm.ErrModelNotFound, http.StatusBadGateway, "model_not_found", "model not found by the provider"},
+	{llm.ErrInvalidAPIKey, http.StatusBadGateway, "provider_authentication_failed", "authentication with the model provider failed"},
+	{llm.ErrAuthenticationFailed, http.StatusBadGateway, "provider_authentication_failed", "authentication with the model provider failed"},
+	{llm.ErrServiceUnavailable, http.StatusServiceUnavailable, "service_unavailable", "the model provider is unavailable"},
+	{llm.ErrModelOverloaded, http.StatusServiceUnavailable, "model_overloaded", "the model is overloaded"},
+	{llm.ErrTimeout, http.StatusGatewayTimeout, "timeout", "the model provider timed out"},
+}
+
+// writeError writes the error response matching the error.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make(map[string]string, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields[jsonFieldName(fe.Field())] = fe.Tag()
+		}
+		writeJSON(w, r, http.StatusBadRequest, errorResponse{Error: errorDetail{
+			Code:    "validation_failed",
+			Message: "request validation failed",
+			Fields:  fields,
+		}})
+		return
+	}
+
+	// The errors may carry provider responses, URLs and paths, so their
+	// details are only logged
+	for _, e := range apiErrors {
+		if errors.Is(err, e.err) {
+			if e.status >= http.StatusInternalServerError {
+				logger.Error(r.Context(), "upstream error", "code", e.code, "err", err)
+			} else {
+				logger.Warn(r.Context(), "request failed", "code", e.code, "err", err)
+			}
+			writeJSON(w, r, e.status, errorResponse{Error: errorDetail{
+				Code:    e.code,
+				Message: e.message,
+			}})
+			return
+		}
+	}
+
+	// Hide the details of unexpected errors
+	logger.Error(r.Context(), "internal server error", "err", err)
+	writeJSON(w, r, http.StatusInternalServerError, errorResponse{Error: errorDetail{
+		Code:    "internal_error",
+		Message: "internal server error",
+	}})
+}
+
+// writeJSON writes a JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error(r.Context(), "failed to write response", "err", err)
+	}
+}
+
+// jsonFieldName converts a Go field name to the JSON field name of the request.
+func jsonFieldName(field string) string {
+	if field == "" {
+		return field
+	}
+	return strings.ToLower(field[:1]) + field[1:]
+}
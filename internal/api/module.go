@@ -0,0 +1,8 @@
+package api
+
+import "go.uber.org/fx"
+
+// Module is the api fx module that provides the JSON API.
+var Module = fx.Module("api",
+	fx.Provide(newAPI), // Provides the API handlers
+)
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"coda/internal/llm"
+	"coda/internal/review"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Limits of the review list
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+// maxRequestBodySize bounds the size of a create review request body.
+// It leaves room for JSON escaping of the code and files.
+const maxRequestBodySize = 2 * review.MaxUploadSize
+
+// createReviewRequest is the body of a create review request.
+// Files are reviewed together as a module instead of the code.
+type createReviewRequest struct {
+	Code        string        `json:"code" validate:"required_without=Files,max=500000"`
+	Files       []fileRequest `json:"files" validate:"omitempty,max=200,dive"`
+	Language    string        `json:"language" validate:"omitempty,max=50"`
+	DetailLevel string        `json:"detailLevel" validate:"omitempty,oneof=low medium high"`
+	Strictness  string        `json:"strictness" validate:"omitempty,oneof=low medium high"`
+	Model       string        `json:"model"`   // Model name or display name, defaults to the default model
+	NoCache     bool          `json:"noCache"` // Review again instead of reusing the cached result of an identical request
+}
+
+// fileRequest is a file of a module review.
+type fileRequest struct {
+	Path    string `json:"path" validate:"required,max=500"`
+	Content string `json:"content" validate:"required,max=262144"`
+}
+
+// reviewListResponse is the body of a list reviews response.
+type reviewListResponse struct {
+	Reviews []*review.Review `json:"reviews"`
+}
+
+// modelResponse describes a model available for reviews.
+type modelResponse struct {
+	Name          string               `json:"name"`
+	DisplayName   string               `json:"displayName"`
+	Provider      string               `json:"provider"`
+	Endpoint      string               `json:"endpoint,omitempty"`
+	MaxTokens     int                  `json:"maxTokens"`
+	ContextWindow int                  `json:"contextWindow"`
+	Family        string               `json:"family,omitempty"`
+	Version       string               `json:"version,omitempty"`
+	Capabilities  capabilitiesResponse `json:"capabilities"`
+}
+
+// capabilitiesResponse describes the features supported by a model.
+type capabilitiesResponse struct {
+	Streaming bool `json:"streaming"`
+	Functions bool `json:"functions"`
+	Vision    bool `json:"vision"`
+	JSON      bool `json:"json"`
+}
+
+// modelListResponse is the body of a list models response.
+type modelListResponse struct {
+	Models []modelResponse `json:"models"`
+}
+
+// createReview runs a code review and returns it.
+func (a *API) createReview(w http.ResponseWriter, r *http.Request) {
+	var body createReviewRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBodySize)).Decode(&body); err != nil {
+		writeError(w, r, fmt.Errorf("%w: %w", errInvalidBody, err))
+		return
+	}
+	if err := a.validate.Struct(body); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	model, err := a.reviews.ResolveModel(body.Model)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var files []review.File
+	for _, f := range body.Files {
+		files = append(files, review.File{Path: f.Path, Content: f.Content})
+	}
+
+	rv, err := a.reviews.Review(r.Context(), review.Request{
+		Code:        body.Code,
+		Files:       files,
+		Language:    body.Language,
+		DetailLevel: body.DetailLevel,
+		Strictness:  body.Strictness,
+		Model:       model,
+		NoCache:     body.NoCache,
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/reviews/"+rv.ID)
+	writeJSON(w, r, http.StatusCreated, rv)
+}
+
+// getReview returns a review from the review history.
+func (a *API) getReview(w http.ResponseWriter, r *http.Request) {
+	rv, err := a.reviews.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, rv)
+}
+
+// listReviews returns the most recent reviews, limited by the limit query parameter.
+func (a *API) listReviews(w http.ResponseWriter, r *http.Request) {
+	limit := defaultListLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxListLimit {
+			writeError(w, r, fmt.Errorf("%w: limit must be between 1 and %d", errInvalidParameter, maxListLimit))
+			return
+		}
+		limit = n
+	}
+
+	reviews, err := a.reviews.List(r.Context(), limit)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if reviews == nil {
+		reviews = []*review.Review{}
+	}
+
+	writeJSON(w, r, http.StatusOK, reviewListResponse{Reviews: reviews})
+}
+
+// listModels returns the models available for reviews.
+func (a *API) listModels(w http.ResponseWriter, r *http.Request) {
+	models := a.reviews.Models()
+
+	resp := modelListResponse{Models: make([]modelResponse, 0, len(models))}
+	for _, m := range models {
+		resp.Models = append(resp.Models, newModelResponse(m))
+	}
+
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// newModelResponse converts a model to its API representation.
+func newModelResponse(m llm.Model) modelResponse {
+	return modelResponse{
+		Name:          m.Name,
+		DisplayName:   m.DisplayName,
+		Provider:      m.Provider.String(),
+		Endpoint:      m.Endpoint,
+		MaxTokens:     m.MaxToken,
+		ContextWindow: m.ContextWindow,
+		Family:        m.Family,
+		Version:       m.Version,
+		Capabilities: capabilitiesResponse{
+			Streaming: m.Capabilities.SupportsStreaming,
+			Functions: m.Capabilities.SupportsFunctions,
+			Vision:    m.Capabilities.SupportsVision,
+			JSON:      m.Capabilities.SupportsJSON,
+		},
+	}
+}
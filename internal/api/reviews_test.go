@@ -0,0 +1,82 @@
+package api
+
+import (
+	"coda/internal/llm"
+	"coda/internal/review"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// fakeCompleter returns a canned structured review, or fails for code containing "fail".
+type fakeCompleter struct {
+	llm.Completer
+}
+
+func (fakeCompleter) GetAvailableModels() []llm.Model {
+	return []llm.Model{review.DefaultModel}
+}
+
+func (fakeCompleter) Complete(_ context.Context, params llm.CompleteParams, _ llm.Model) (*llm.CompleteResponse, error) {
+	if strings.Contains(params.Messages[1].Content, "fail") {
+		return nil, fmt.Errorf("POST https://llm.internal/v1/chat/completions: %w", llm.ErrTooManyRequests)
+	}
+	return &llm.CompleteResponse{
+		Messages: []llm.Message{
+			{Content: `{"summary": "ok", "findings": [{"severity": "major", "category": "bug", "startLine": 1, "message": "m"}]}`},
+		},
+	}, nil
+}
+
+func TestReviewsAPI(t *testing.T) {
+	store, err := review.NewBoltStore(filepath.Join(t.TempDir(), "reviews.db"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	r := chi.NewMux()
+	ConfigureRoutes(newAPI(review.NewService(fakeCompleter{}, store, nil)), r)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"ListModels", http.MethodGet, "/api/v1/models", "", http.StatusOK, `"name":"gpt-4o-mini"`},
+		{"Create", http.MethodPost, "/api/v1/reviews", `{"code": "x = 1"}`, http.StatusCreated, `"findings":[{"severity":"major"`},
+		{"CreateInvalidBody", http.MethodPost, "/api/v1/reviews", `{"code":`, http.StatusBadRequest, `"code":"invalid_body"`},
+		{"CreateModule", http.MethodPost, "/api/v1/reviews", `{"files": [{"path": "b.py", "content": "import a"}, {"path": "a.py", "content": "x = 1"}]}`, http.StatusCreated, `"manifest":{"entries":[{"path":"a.py","language":"python","lines":1`},
+		{"CreateMissingCode", http.MethodPost, "/api/v1/reviews", `{}`, http.StatusBadRequest, `"fields":{"code":"required_without"}`},
+		{"CreateInvalidLevel", http.MethodPost, "/api/v1/reviews", `{"code": "x", "strictness": "extreme"}`, http.StatusBadRequest, `"fields":{"strictness":"oneof"}`},
+		{"CreateUnknownModel", http.MethodPost, "/api/v1/reviews", `{"code": "x", "model": "unknown"}`, http.StatusBadRequest, `"code":"unknown_model"`},
+		{"CreateUpstreamError", http.MethodPost, "/api/v1/reviews", `{"code": "fail"}`, http.StatusTooManyRequests, `{"code":"too_many_requests","message":"too many requests to the model provider"}`},
+		{"List", http.MethodGet, "/api/v1/reviews?limit=10", "", http.StatusOK, `"code":"x = 1"`},
+		{"ListInvalidLimit", http.MethodGet, "/api/v1/reviews?limit=0", "", http.StatusBadRequest, `"code":"invalid_parameter"`},
+		{"GetNotFound", http.MethodGet, "/api/v1/reviews/missing", "", http.StatusNotFound, `"code":"not_found"`},
+	}
+
+	// Subtests run sequentially since they share the store
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+
+			if w.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("Expected body to contain %s, got %s", tt.wantBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -2,6 +2,8 @@
 // It handles loading configuration from files and environment variables,
 package config
 
+import "time"
+
 // Config represents the complete application configuration.
 // It contains all settings needed for the application to run.
 type Config struct {
@@ -9,6 +11,10 @@ type Config struct {
 	Logging Logging `yaml:"logging"` // Logging configuration
 	Server  Server  `yaml:"server"`  // HTTP server configuration
 	LLM     LLM     `yaml:"llm"`     // Language model configuration
+	Review  Review  `yaml:"review"`  // Review persistence configuration
+	Agent   Agent   `yaml:"agent"`   // Agentic review configuration
+	Tracing Tracing `yaml:"tracing"` // OpenTelemetry tracing configuration
+	Budget  Budget  `yaml:"budget"`  // Spending limits on the language models
 }
 
 // Global contains application-wide settings.
@@ -36,16 +42,136 @@ type Logging struct {
 
 // Server configures the HTTP server.
 type Server struct {
-	Host           string   `yaml:"host" validate:"required"` // Server hostname or IP
-	Port           int      `yaml:"port" validate:"required"` // Server port
-	AllowedOrigins []string `yaml:"allowedOrigins"`           // CORS allowed origins
+	Host           string   `yaml:"host" validate:"required"`               // Server hostname or IP
+	Port           int      `yaml:"port" validate:"required"`               // Server port
+	AllowedOrigins []string `yaml:"allowedOrigins"`                         // CORS allowed origins
+	TrustedProxies []string `yaml:"trustedProxies" validate:"dive,ip|cidr"` // IPs or CIDRs of the reverse proxies whose X-Forwarded-For and X-Real-IP headers are trusted
+	MetricsAddr    string   `yaml:"metricsAddr"`                            // Address of the separate listener serving /metrics, e.g. 127.0.0.1:9090; metrics are not served when empty
+}
+
+// Tracing configures the export of OpenTelemetry traces.
+type Tracing struct {
+	Endpoint    string   `yaml:"endpoint"`                                     // OTLP/HTTP collector URL, e.g. http://localhost:4318; tracing is disabled when empty
+	ServiceName string   `yaml:"serviceName"`                                  // Service name of the spans (default: coda)
+	SampleRatio *float64 `yaml:"sampleRatio" validate:"omitempty,gte=0,lte=1"` // Share of the traces started by the application that are recorded, 0 records none (default: 1)
+}
+
+// IsConfigured checks if traces are exported.
+func (t *Tracing) IsConfigured() bool {
+	return t.Endpoint != ""
+}
+
+// Budget limits the cost and tokens spent on the language models over
+// rolling windows, by each caller and by the whole application.
+type Budget struct {
+	Limits        []BudgetLimit `yaml:"limits" validate:"dive"`                             // Spending limits, budgets are disabled when empty
+	Action        string        `yaml:"action" validate:"omitempty,oneof=reject downgrade"` // What happens to requests over a limit (default: reject)
+	FallbackModel string        `yaml:"fallbackModel"`                                      // Model completing the downgraded requests (default: first Ollama model)
+	APIKeys       []string      `yaml:"apiKeys"`                                            // Keys whose holders each have their own budget, other callers are counted by IP address
+}
+
+// BudgetLimit caps the cost and tokens spent over a rolling window.
+type BudgetLimit struct {
+	Scope     string        `yaml:"scope" validate:"oneof=caller global"` // Spending counted per caller or for the whole application
+	Window    time.Duration `yaml:"window" validate:"gt=0"`               // Rolling window, e.g. 1h or 24h
+	MaxCost   float64       `yaml:"maxCost" validate:"min=0"`             // Maximum cost in the currency of the model pricing, unlimited when zero
+	MaxTokens int           `yaml:"maxTokens" validate:"min=0"`           // Maximum tokens, unlimited when zero
+}
+
+// IsConfigured checks if spending limits are set.
+func (b *Budget) IsConfigured() bool {
+	return len(b.Limits) > 0
+}
+
+// Review configures how code reviews are persisted.
+type Review struct {
+	StorePath string `yaml:"storePath"` // Path to the review database file (default: data/reviews.db)
+}
+
+// Agent configures agentic reviews, in which the model reads a local repository
+// to look up the symbols referenced by the reviewed code.
+type Agent struct {
+	RepositoryPath string `yaml:"repositoryPath"`             // Repository read by the model, agentic reviews are disabled when empty
+	MaxSteps       int    `yaml:"maxSteps" validate:"min=0"`  // Maximum number of tool-calling steps (default: 8)
+	MaxTokens      int    `yaml:"maxTokens" validate:"min=0"` // Tokens after which the model must answer (default: 100000)
 }
 
 // LLM configures language model services.
 type LLM struct {
-	OpenAI   OpenAI   `yaml:"openai" validate:"required"`   // OpenAI API configuration
-	Ollama   Ollama   `yaml:"ollama" validate:"required"`   // Ollama API configuration
-	Langfuse Langfuse `yaml:"langfuse" validate:"required"` // Langfuse observability configuration
+	OpenAI    OpenAI     `yaml:"openai" validate:"required"`   // OpenAI API configuration
+	Ollama    Ollama     `yaml:"ollama" validate:"required"`   // Ollama API configuration
+	Anthropic Anthropic  `yaml:"anthropic"`                    // Anthropic API configuration
+	Azure     Azure      `yaml:"azure"`                        // Azure OpenAI Service configuration
+	Gemini    Gemini     `yaml:"gemini"`                       // Google Gemini API configuration
+	Langfuse  Langfuse   `yaml:"langfuse" validate:"required"` // Langfuse observability configuration
+	Endpoints []Endpoint `yaml:"endpoints" validate:"dive"`    // OpenAI-compatible servers
+	Models    []Model    `yaml:"models" validate:"dive"`       // Additional or overriding model definitions
+	Discovery Discovery  `yaml:"discovery"`                    // Model discovery from provider servers
+	Cache     Cache      `yaml:"cache"`                        // Cache of the completions
+}
+
+// Endpoint configures a server exposing an OpenAI-compatible chat completions API,
+// such as vLLM or llama.cpp.
+type Endpoint struct {
+	Name    string  `yaml:"name" validate:"required"`    // Unique endpoint name, shown next to its models
+	BaseURL string  `yaml:"baseURL" validate:"required"` // API base URL including the version, e.g. http://localhost:8000/v1
+	APIKey  string  `yaml:"apiKey"`                      // API key, sent only when set
+	Models  []Model `yaml:"models" validate:"dive"`      // Models served by the endpoint, discovered when empty
+}
+
+// Endpoint returns the endpoint with the given name.
+func (l *LLM) Endpoint(name string) (Endpoint, bool) {
+	for _, e := range l.Endpoints {
+		if e.Name == name {
+			return e, true
+		}
+	}
+	return Endpoint{}, false
+}
+
+// Discovery configures the discovery of installed models from provider servers.
+type Discovery struct {
+	Disabled        bool          `yaml:"disabled"`        // Disable model discovery
+	RefreshInterval time.Duration `yaml:"refreshInterval"` // Interval between discoveries (default: 5m)
+}
+
+// Cache configures the cache answering identical completion requests without
+// calling the model again.
+type Cache struct {
+	Disabled bool          `yaml:"disabled"`              // Disable the cache
+	Size     int           `yaml:"size" validate:"min=0"` // Completions kept in memory (default: 1000)
+	TTL      time.Duration `yaml:"ttl" validate:"min=0"`  // How long a completion is reused (default: 24h)
+	Path     string        `yaml:"path"`                  // Database file persisting the completions, kept in memory only when empty
+}
+
+// Model declares a language model available through a provider.
+// Models declared here are added to the built-in catalog, replacing any
+// built-in model with the same provider and name.
+type Model struct {
+	Provider      string            `yaml:"provider"`                       // Provider name (openai, ollama, anthropic, gemini), omitted for endpoint models and deployments
+	Name          string            `yaml:"name" validate:"required"`       // Model name as known by the provider
+	DisplayName   string            `yaml:"displayName"`                    // Human-readable name, defaults to Name
+	MaxTokens     int               `yaml:"maxTokens" validate:"min=0"`     // Maximum output tokens
+	ContextWindow int               `yaml:"contextWindow" validate:"min=0"` // Context window size in tokens
+	Version       string            `yaml:"version"`                        // Model version
+	Family        string            `yaml:"family"`                         // Model family
+	Pricing       *ModelPricing     `yaml:"pricing"`                        // Pricing, omitted for free models
+	Capabilities  ModelCapabilities `yaml:"capabilities"`                   // Supported features
+}
+
+// ModelPricing declares the per-token price of a model.
+type ModelPricing struct {
+	InputPerToken  float64 `yaml:"inputPerToken" validate:"min=0"`  // Price per input token
+	OutputPerToken float64 `yaml:"outputPerToken" validate:"min=0"` // Price per output token
+	Currency       string  `yaml:"currency"`                        // Currency code, defaults to USD
+}
+
+// ModelCapabilities declares the features a model supports.
+type ModelCapabilities struct {
+	Streaming bool `yaml:"streaming"` // Token streaming
+	Functions bool `yaml:"functions"` // Function/tool calling
+	Vision    bool `yaml:"vision"`    // Image input
+	JSON      bool `yaml:"json"`      // JSON output mode
 }
 
 // OpenAI configures the OpenAI API client.
@@ -53,10 +179,49 @@ type OpenAI struct {
 	APIKey string `yaml:"apiKey" validate:"required"` // OpenAI API key
 }
 
+// Anthropic configures the Anthropic API client.
+type Anthropic struct {
+	APIKey  string `yaml:"apiKey"`  // Anthropic API key, Anthropic models are unavailable when empty
+	BaseURL string `yaml:"baseURL"` // API base URL (default: https://api.anthropic.com)
+}
+
+// IsConfigured checks if the Anthropic API key is set.
+func (a *Anthropic) IsConfigured() bool {
+	return a.APIKey != ""
+}
+
+// Gemini configures the Google Gemini API client.
+type Gemini struct {
+	APIKey  string `yaml:"apiKey"`  // Gemini API key, Gemini models are unavailable when empty
+	BaseURL string `yaml:"baseURL"` // API base URL (default: https://generativelanguage.googleapis.com)
+}
+
+// IsConfigured checks if the Gemini API key is set.
+func (g *Gemini) IsConfigured() bool {
+	return g.APIKey != ""
+}
+
+// Azure configures the Azure OpenAI Service.
+type Azure struct {
+	Endpoint    string  `yaml:"endpoint"`                    // Resource endpoint, e.g. https://my-resource.openai.azure.com
+	APIVersion  string  `yaml:"apiVersion"`                  // API version (default: 2024-10-21)
+	APIKey      string  `yaml:"apiKey"`                      // API key
+	BearerToken string  `yaml:"bearerToken"`                 // Microsoft Entra ID access token, used instead of the API key
+	Deployments []Model `yaml:"deployments" validate:"dive"` // Model deployments, named after the deployment
+}
+
+// IsConfigured checks if the Azure endpoint and credentials are set.
+func (a *Azure) IsConfigured() bool {
+	return a.Endpoint != "" && (a.APIKey != "" || a.BearerToken != "")
+}
+
 // Langfuse configures the Langfuse observability platform.
 type Langfuse struct {
-	PrivateKey string `yaml:"privateKey"` // Langfuse private key
-	PublicKey  string `yaml:"publicKey"`  // Langfuse public key
+	PrivateKey    string        `yaml:"privateKey"`    // Langfuse private key
+	PublicKey     string        `yaml:"publicKey"`     // Langfuse public key
+	QueueSize     int           `yaml:"queueSize"`     // Events waiting to be sent, newer events are dropped when full (default: 1000)
+	BatchSize     int           `yaml:"batchSize"`     // Events sent per request (default: 100)
+	FlushInterval time.Duration `yaml:"flushInterval"` // Maximum time an event waits to be sent (default: 5s)
 }
 
 // IsConfigured checks if the Langfuse configuration is complete.
@@ -66,7 +231,9 @@ func (l *Langfuse) IsConfigured() bool {
 
 // Ollama configures the Ollama API client.
 type Ollama struct {
-	BaseURL string `yaml:"baseURL"` // Ollama API base URL
+	BaseURL   string         `yaml:"baseURL"`   // Ollama API base URL
+	Options   map[string]any `yaml:"options"`   // Model options sent with each request, e.g. num_ctx, top_k or repeat_penalty
+	KeepAlive *time.Duration `yaml:"keepAlive"` // How long the model stays loaded after a request, negative to keep it loaded (default: server setting)
 }
 
 func (o *Ollama) IsConfigured() bool {
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 )
 
@@ -60,6 +61,74 @@ llm:
 		}
 	})
 
+	// Model Catalog Tests
+	t.Run("Load_WithModels", func(t *testing.T) {
+		t.Parallel()
+
+		validConfig := `
+server:
+  host: 127.0.0.1
+  port: 8080
+llm:
+  openai:
+    apiKey: test-api-key
+  models:
+    - provider: ollama
+      name: qwen2.5-coder:7b
+      displayName: Qwen2.5 Coder 7B
+      contextWindow: 32768
+      pricing:
+        inputPerToken: 0.000001
+        outputPerToken: 0.000002
+      capabilities:
+        streaming: true
+        json: true
+`
+		tempDir := setupConfigDir(t, validConfig, "local.yaml")
+
+		cfg, err := Load(ENVLocal, tempDir)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(cfg.LLM.Models) != 1 {
+			t.Fatalf("Expected 1 model, got %d", len(cfg.LLM.Models))
+		}
+		model := cfg.LLM.Models[0]
+		if model.Provider != "ollama" || model.Name != "qwen2.5-coder:7b" {
+			t.Errorf("Expected ollama/qwen2.5-coder:7b, got %s/%s", model.Provider, model.Name)
+		}
+		if model.ContextWindow != 32768 {
+			t.Errorf("Expected context window to be 32768, got %d", model.ContextWindow)
+		}
+		if model.Pricing == nil || model.Pricing.OutputPerToken != 0.000002 {
+			t.Errorf("Expected output price to be 0.000002, got %+v", model.Pricing)
+		}
+		if !model.Capabilities.Streaming || !model.Capabilities.JSON || model.Capabilities.Functions {
+			t.Errorf("Unexpected capabilities: %+v", model.Capabilities)
+		}
+	})
+
+	t.Run("Load_WithInvalidModels", func(t *testing.T) {
+		t.Parallel()
+
+		invalidConfig := `
+server:
+  host: 127.0.0.1
+  port: 8080
+llm:
+  openai:
+    apiKey: test-api-key
+  models:
+    - provider: ollama
+`
+		tempDir := setupConfigDir(t, invalidConfig, "local.yaml")
+
+		if _, err := Load(ENVLocal, tempDir); err == nil {
+			t.Error("Expected error for model without a name, got nil")
+		}
+	})
+
 	// Error Handling Tests
 	t.Run("Load_Errors", func(t *testing.T) {
 		t.Parallel()
@@ -191,6 +260,57 @@ llm:
 			}
 		})
 
+		// Test with models declared in an environment variable
+		t.Run("ModelsOverride", func(t *testing.T) {
+			os.Setenv("LLM_MODELS", `[{"provider": "openai", "name": "gpt-4o", "contextWindow": 128000}]`)
+			t.Cleanup(func() {
+				os.Unsetenv("LLM_MODELS")
+			})
+
+			cfg, err := Load(ENVLocal, tempDir)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(cfg.LLM.Models) != 1 || cfg.LLM.Models[0].Name != "gpt-4o" {
+				t.Fatalf("Expected models to be [gpt-4o] (from env), got %+v", cfg.LLM.Models)
+			}
+			if cfg.LLM.Models[0].ContextWindow != 128000 {
+				t.Errorf("Expected context window to be 128000, got %d", cfg.LLM.Models[0].ContextWindow)
+			}
+		})
+
+		// Test with endpoints declared in an environment variable
+		t.Run("EndpointsOverride", func(t *testing.T) {
+			os.Setenv("LLM_ENDPOINTS", `[{"name": "vllm", "baseURL": "http://localhost:8000/v1", "models": [{"name": "qwen"}]}]`)
+			t.Cleanup(func() {
+				os.Unsetenv("LLM_ENDPOINTS")
+			})
+
+			cfg, err := Load(ENVLocal, tempDir)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			endpoint, ok := cfg.LLM.Endpoint("vllm")
+			if !ok {
+				t.Fatalf("Expected endpoint vllm (from env), got %+v", cfg.LLM.Endpoints)
+			}
+			if endpoint.BaseURL != "http://localhost:8000/v1" || len(endpoint.Models) != 1 {
+				t.Errorf("Expected endpoint with base URL and one model, got %+v", endpoint)
+			}
+		})
+
+		// Test with an endpoint missing its base URL
+		t.Run("InvalidEndpoint", func(t *testing.T) {
+			os.Setenv("LLM_ENDPOINTS", `[{"name": "vllm"}]`)
+			t.Cleanup(func() {
+				os.Unsetenv("LLM_ENDPOINTS")
+			})
+
+			if _, err := Load(ENVLocal, tempDir); err == nil {
+				t.Error("Expected error when endpoint has no base URL, got nil")
+			}
+		})
+
 		// Test with invalid environment variable
 		t.Run("InvalidOverride", func(t *testing.T) {
 			os.Setenv("PORT", "not-a-number")
@@ -242,6 +362,7 @@ llm:
 					"HOST":            "0.0.0.0",
 					"PORT":            "9090",
 					"ALLOWED_ORIGINS": "https://example.com,https://test.com",
+					"METRICS_ADDR":    "127.0.0.1:9090",
 					"OPENAI_API_KEY":  "env-api-key",
 				},
 				initialCfg: Config{
@@ -261,6 +382,7 @@ llm:
 						Host:           "0.0.0.0",
 						Port:           9090,
 						AllowedOrigins: []string{"https://example.com", "https://test.com"},
+						MetricsAddr:    "127.0.0.1:9090",
 					},
 					LLM: LLM{
 						OpenAI: OpenAI{
@@ -517,6 +639,27 @@ llm:
 	})
 }
 
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []string
+	}{
+		{name: "single", in: "a", want: []string{"a"}},
+		{name: "spaces", in: " a , b ", want: []string{"a", "b"}},
+		{name: "empty entries", in: "a,,b,", want: []string{"a", "b"}},
+		{name: "empty", in: " ", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitList(tt.in); !slices.Equal(got, tt.want) {
+				t.Errorf("splitList(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
 // Helper function to create a temporary directory with a config file
 func setupConfigDir(t *testing.T, content string, filename string) string {
 	t.Helper()
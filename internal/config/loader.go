@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator"
 	"gopkg.in/yaml.v2"
@@ -114,6 +115,12 @@ func LoadEnvVariables(cfg *Config) error {
 	if v, ok := os.LookupEnv("ALLOWED_ORIGINS"); ok {
 		cfg.Server.AllowedOrigins = strings.Split(v, ",")
 	}
+	if v, ok := os.LookupEnv("TRUSTED_PROXIES"); ok {
+		cfg.Server.TrustedProxies = splitList(v)
+	}
+	if v, ok := os.LookupEnv("METRICS_ADDR"); ok {
+		cfg.Server.MetricsAddr = v
+	}
 
 	// LLM configuration
 	if v, ok := os.LookupEnv("OPENAI_API_KEY"); ok {
@@ -122,16 +129,208 @@ func LoadEnvVariables(cfg *Config) error {
 	if v, ok := os.LookupEnv("OLLAMA_BASE_URL"); ok {
 		cfg.LLM.Ollama.BaseURL = v
 	}
+	if v, ok := os.LookupEnv("OLLAMA_OPTIONS"); ok {
+		// Accepts a YAML or JSON object of model options
+		var options map[string]any
+		if err := yaml.Unmarshal([]byte(v), &options); err != nil {
+			return fmt.Errorf("invalid Ollama options: %w", err)
+		}
+		cfg.LLM.Ollama.Options = options
+	}
+	if v, ok := os.LookupEnv("OLLAMA_KEEP_ALIVE"); ok {
+		keepAlive, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid Ollama keep alive: %w", err)
+		}
+		cfg.LLM.Ollama.KeepAlive = &keepAlive
+	}
+	if v, ok := os.LookupEnv("ANTHROPIC_API_KEY"); ok {
+		cfg.LLM.Anthropic.APIKey = v
+	}
+	if v, ok := os.LookupEnv("ANTHROPIC_BASE_URL"); ok {
+		cfg.LLM.Anthropic.BaseURL = v
+	}
+	if v, ok := os.LookupEnv("GEMINI_API_KEY"); ok {
+		cfg.LLM.Gemini.APIKey = v
+	}
+	if v, ok := os.LookupEnv("GEMINI_BASE_URL"); ok {
+		cfg.LLM.Gemini.BaseURL = v
+	}
+	if v, ok := os.LookupEnv("AZURE_OPENAI_ENDPOINT"); ok {
+		cfg.LLM.Azure.Endpoint = v
+	}
+	if v, ok := os.LookupEnv("AZURE_OPENAI_API_VERSION"); ok {
+		cfg.LLM.Azure.APIVersion = v
+	}
+	if v, ok := os.LookupEnv("AZURE_OPENAI_API_KEY"); ok {
+		cfg.LLM.Azure.APIKey = v
+	}
+	if v, ok := os.LookupEnv("AZURE_OPENAI_BEARER_TOKEN"); ok {
+		cfg.LLM.Azure.BearerToken = v
+	}
+	if v, ok := os.LookupEnv("AZURE_OPENAI_DEPLOYMENTS"); ok {
+		// Accepts a YAML or JSON list of deployment definitions
+		var deployments []Model
+		if err := yaml.Unmarshal([]byte(v), &deployments); err != nil {
+			return fmt.Errorf("invalid Azure deployments: %w", err)
+		}
+		cfg.LLM.Azure.Deployments = deployments
+	}
 	if v, ok := os.LookupEnv("LANGFUSE_PUBLIC_KEY"); ok {
 		cfg.LLM.Langfuse.PublicKey = v
 	}
 	if v, ok := os.LookupEnv("LANGFUSE_PRIVATE_KEY"); ok {
 		cfg.LLM.Langfuse.PrivateKey = v
 	}
+	if v, ok := os.LookupEnv("LANGFUSE_QUEUE_SIZE"); ok {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid Langfuse queue size: %w", err)
+		}
+		cfg.LLM.Langfuse.QueueSize = size
+	}
+	if v, ok := os.LookupEnv("LANGFUSE_BATCH_SIZE"); ok {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid Langfuse batch size: %w", err)
+		}
+		cfg.LLM.Langfuse.BatchSize = size
+	}
+	if v, ok := os.LookupEnv("LANGFUSE_FLUSH_INTERVAL"); ok {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid Langfuse flush interval: %w", err)
+		}
+		cfg.LLM.Langfuse.FlushInterval = interval
+	}
+	if v, ok := os.LookupEnv("LLM_DISCOVERY_DISABLED"); ok {
+		disabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid discovery disabled flag: %w", err)
+		}
+		cfg.LLM.Discovery.Disabled = disabled
+	}
+	if v, ok := os.LookupEnv("LLM_DISCOVERY_REFRESH_INTERVAL"); ok {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid discovery refresh interval: %w", err)
+		}
+		cfg.LLM.Discovery.RefreshInterval = interval
+	}
+	if v, ok := os.LookupEnv("LLM_CACHE_DISABLED"); ok {
+		disabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid cache disabled flag: %w", err)
+		}
+		cfg.LLM.Cache.Disabled = disabled
+	}
+	if v, ok := os.LookupEnv("LLM_CACHE_SIZE"); ok {
+		size, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid cache size: %w", err)
+		}
+		cfg.LLM.Cache.Size = size
+	}
+	if v, ok := os.LookupEnv("LLM_CACHE_TTL"); ok {
+		ttl, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("invalid cache TTL: %w", err)
+		}
+		cfg.LLM.Cache.TTL = ttl
+	}
+	if v, ok := os.LookupEnv("LLM_CACHE_PATH"); ok {
+		cfg.LLM.Cache.Path = v
+	}
+	if v, ok := os.LookupEnv("LLM_MODELS"); ok {
+		// Accepts a YAML or JSON list of model definitions
+		var models []Model
+		if err := yaml.Unmarshal([]byte(v), &models); err != nil {
+			return fmt.Errorf("invalid models: %w", err)
+		}
+		cfg.LLM.Models = models
+	}
+	if v, ok := os.LookupEnv("LLM_ENDPOINTS"); ok {
+		// Accepts a YAML or JSON list of endpoint definitions
+		var endpoints []Endpoint
+		if err := yaml.Unmarshal([]byte(v), &endpoints); err != nil {
+			return fmt.Errorf("invalid endpoints: %w", err)
+		}
+		cfg.LLM.Endpoints = endpoints
+	}
+
+	// Review configuration
+	if v, ok := os.LookupEnv("REVIEW_STORE_PATH"); ok {
+		cfg.Review.StorePath = v
+	}
+
+	// Agent configuration
+	if v, ok := os.LookupEnv("AGENT_REPOSITORY_PATH"); ok {
+		cfg.Agent.RepositoryPath = v
+	}
+	if v, ok := os.LookupEnv("AGENT_MAX_STEPS"); ok {
+		steps, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid agent max steps: %w", err)
+		}
+		cfg.Agent.MaxSteps = steps
+	}
+	if v, ok := os.LookupEnv("AGENT_MAX_TOKENS"); ok {
+		tokens, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("invalid agent max tokens: %w", err)
+		}
+		cfg.Agent.MaxTokens = tokens
+	}
+
+	// Tracing configuration
+	if v, ok := os.LookupEnv("OTEL_EXPORTER_OTLP_ENDPOINT"); ok {
+		cfg.Tracing.Endpoint = v
+	}
+	if v, ok := os.LookupEnv("OTEL_SERVICE_NAME"); ok {
+		cfg.Tracing.ServiceName = v
+	}
+	if v, ok := os.LookupEnv("TRACING_SAMPLE_RATIO"); ok {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("invalid tracing sample ratio: %w", err)
+		}
+		cfg.Tracing.SampleRatio = &ratio
+	}
+
+	// Budget configuration
+	if v, ok := os.LookupEnv("BUDGET_LIMITS"); ok {
+		// Accepts a YAML or JSON list of limits
+		var limits []BudgetLimit
+		if err := yaml.Unmarshal([]byte(v), &limits); err != nil {
+			return fmt.Errorf("invalid budget limits: %w", err)
+		}
+		cfg.Budget.Limits = limits
+	}
+	if v, ok := os.LookupEnv("BUDGET_ACTION"); ok {
+		cfg.Budget.Action = v
+	}
+	if v, ok := os.LookupEnv("BUDGET_FALLBACK_MODEL"); ok {
+		cfg.Budget.FallbackModel = v
+	}
+	if v, ok := os.LookupEnv("BUDGET_API_KEYS"); ok {
+		cfg.Budget.APIKeys = splitList(v)
+	}
 
 	return nil
 }
 
+// splitList splits a comma-separated list, trimming the entries and dropping
+// the empty ones.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // Options defines parameters for loading configuration.
 type Options struct {
 	Env       ENV    // Environment to load configuration for
@@ -9,14 +9,16 @@ import (
 // Frontend represents the web application that serves the user interface.
 // It coordinates the different handlers and components of the web interface.
 type Frontend struct {
-	index *IndexHandler
+	index   *IndexHandler
+	history *HistoryHandler
 }
 
 // NewFrontend creates a new Frontend instance with the provided handlers.
 // It follows the dependency injection pattern for better testability.
-func newFrontend(index *IndexHandler) *Frontend {
+func newFrontend(index *IndexHandler, history *HistoryHandler) *Frontend {
 	return &Frontend{
-		index: index,
+		index:   index,
+		history: history,
 	}
 }
 
@@ -34,6 +36,7 @@ func (f *Frontend) RegisterRoutes(r chi.Router) {
 	r.Route("/", func(r chi.Router) {
 		r.Group(func(r chi.Router) {
 			f.index.RegisterRoutes(r)
+			f.history.RegisterRoutes(r)
 		})
 	})
 }
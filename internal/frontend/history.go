@@ -0,0 +1,166 @@
+package frontend
+
+import (
+	"bytes"
+	"coda/internal/llm"
+	"coda/internal/logger"
+	"coda/internal/review"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// historyLimit is the number of reviews shown in the review history.
+const historyLimit = 10
+
+// historyPreviewLength is the number of code characters shown for each review in the history.
+const historyPreviewLength = 300
+
+// reviewLoadedEvent is the htmx event triggered when a stored review is displayed.
+// The review source is swapped out of band into #review-source beforehand, so
+// the page can restore the editor.
+const reviewLoadedEvent = "reviewLoaded"
+
+// HistoryHandler manages the review history.
+// It lists the stored reviews and displays or deletes a single review.
+type HistoryHandler struct {
+	templates *TemplateManager
+	reviews   *review.Service
+}
+
+// newHistory creates a new HistoryHandler with the given template manager and review service.
+func newHistory(tpl *TemplateManager, reviews *review.Service) *HistoryHandler {
+	return &HistoryHandler{
+		templates: tpl,
+		reviews:   reviews,
+	}
+}
+
+// RegisterRoutes registers the HTTP routes for the history handler.
+func (h *HistoryHandler) RegisterRoutes(r chi.Router) {
+	r.Get("/reviews", h.listReviews)
+	r.Get("/reviews/{id}", h.getReview)
+	r.Delete("/reviews/{id}", h.deleteReview)
+}
+
+// reviewSource is the code and settings of a stored review, rendered as JSON
+// so the page can restore the editor.
+type reviewSource struct {
+	Code        string `json:"code"`
+	Language    string `json:"language"`
+	DetailLevel string `json:"detailLevel"`
+	Strictness  string `json:"strictness"`
+	Model       string `json:"model"`
+}
+
+// historyItem is a review summary shown in the review history.
+type historyItem struct {
+	ID          string
+	Language    string
+	Model       string
+	CodePreview string
+	Cost        *llm.Cost
+	CreatedAt   string
+}
+
+// listReviews renders the most recent reviews.
+func (h *HistoryHandler) listReviews(w http.ResponseWriter, r *http.Request) {
+	h.renderHistory(w, r)
+}
+
+// getReview renders the result of a stored review and triggers the reviewLoaded
+// event so the page can restore the code and settings of the review.
+func (h *HistoryHandler) getReview(w http.ResponseWriter, r *http.Request) {
+	rv, err := h.reviews.Get(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		h.handleStoreError(w, r, err)
+		return
+	}
+
+	// The source travels in the body: headers are limited in size and decoded as latin1
+	var buf bytes.Buffer
+	if err := h.templates.ExecuteComponent(&buf, "components/results", newResultsData(rv)); err != nil {
+		http.Error(w, "Failed to execute template", http.StatusInternalServerError)
+		logger.Error(r.Context(), "Failed to execute template", "err", err)
+		return
+	}
+	source := reviewSource{
+		Code:        rv.Source(),
+		Language:    rv.Language,
+		DetailLevel: rv.DetailLevel,
+		Strictness:  rv.Strictness,
+		Model:       rv.Model,
+	}
+	if err := h.templates.ExecuteComponent(&buf, "components/review-source", source); err != nil {
+		http.Error(w, "Failed to execute template", http.StatusInternalServerError)
+		logger.Error(r.Context(), "Failed to execute template", "err", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("HX-Trigger-After-Swap", reviewLoadedEvent)
+	_, _ = buf.WriteTo(w)
+}
+
+// deleteReview deletes a stored review and renders the updated history.
+func (h *HistoryHandler) deleteReview(w http.ResponseWriter, r *http.Request) {
+	if err := h.reviews.Delete(r.Context(), chi.URLParam(r, "id")); err != nil {
+		h.handleStoreError(w, r, err)
+		return
+	}
+
+	h.renderHistory(w, r)
+}
+
+// renderHistory renders the history component with the most recent reviews.
+func (h *HistoryHandler) renderHistory(w http.ResponseWriter, r *http.Request) {
+	reviews, err := h.reviews.List(r.Context(), historyLimit)
+	if err != nil {
+		h.handleStoreError(w, r, err)
+		return
+	}
+
+	items := make([]historyItem, 0, len(reviews))
+	for _, rv := range reviews {
+		items = append(items, historyItem{
+			ID:          rv.ID,
+			Language:    rv.Language,
+			Model:       rv.Model,
+			CodePreview: truncateText(rv.Source(), historyPreviewLength),
+			Cost:        rv.Cost,
+			CreatedAt:   rv.CreatedAt.Local().Format("2006/01/02 15:04"),
+		})
+	}
+
+	h.templates.RenderComponent(w, r, "components/history", struct {
+		Reviews []historyItem
+	}{
+		Reviews: items,
+	})
+}
+
+// handleStoreError renders an error message for a failed store operation.
+func (h *HistoryHandler) handleStoreError(w http.ResponseWriter, r *http.Request, err error) {
+	message := "エラーが発生しました。"
+	if errors.Is(err, review.ErrNotFound) {
+		message = "レビューが見つかりません。"
+	} else {
+		logger.Error(r.Context(), "review store error", "err", err)
+	}
+
+	h.templates.RenderComponent(w, r, "components/error", struct {
+		Message string
+	}{
+		Message: message,
+	})
+}
+
+// truncateText shortens text to at most n characters, appending an ellipsis when truncated.
+func truncateText(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n]) + "..."
+}
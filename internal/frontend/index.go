@@ -1,37 +1,32 @@
 package frontend
 
 import (
+	"bytes"
 	"coda/internal/llm"
-	"coda/internal/llm/openai"
 	"coda/internal/logger"
 	"coda/internal/review"
 	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
 
-// Default values for code review parameters
-const (
-	defaultLanguage    = "python"
-	defaultCode        = "print('Hello, World!')"
-	defaultDetailLevel = "medium"
-	defaultStrictness  = "medium"
-)
-
 // IndexHandler manages the index page and code review functionality.
 // It handles rendering the main page, processing code review requests,
 // and displaying results.
 type IndexHandler struct {
 	templates *TemplateManager
-	completer llm.Completer
+	reviews   *review.Service
 }
 
-// newIndex creates a new IndexHandler with the given template manager and completer.
-func newIndex(tpl *TemplateManager, completer llm.Completer) *IndexHandler {
+// newIndex creates a new IndexHandler with the given template manager and review service.
+func newIndex(tpl *TemplateManager, reviews *review.Service) *IndexHandler {
 	return &IndexHandler{
 		templates: tpl,
-		completer: completer,
+		reviews:   reviews,
 	}
 }
 
@@ -40,11 +35,12 @@ func (h *IndexHandler) RegisterRoutes(r chi.Router) {
 	r.Get("/", h.getIndex)
 	r.Get("/result", h.getResult)
 	r.Post("/review", h.postReview)
+	r.Post("/review/stream", h.postReviewStream)
 }
 
 // getIndex renders the index page.
 func (h *IndexHandler) getIndex(w http.ResponseWriter, r *http.Request) {
-	availableModels := h.completer.GetAvailableModels()
+	availableModels := h.reviews.Models()
 	var modelNames []string
 	for _, model := range availableModels {
 		modelNames = append(modelNames, model.DisplayName)
@@ -78,137 +74,249 @@ AIがコードを解析して、以下の観点からレビューを行います
 
 	const sampleCode = "```python\ndef calculate_sum(numbers):\n    total = 0\n    for num in numbers:\n        total += num\n    return total\n```"
 
-	h.templates.RenderComponent(w, r, "components/results", struct {
-		Result   string
-		ReviewID string
-	}{
+	h.templates.RenderComponent(w, r, "components/results", resultsData{
 		Result: sampleInstructions + sampleCode,
 	})
 }
 
+// resultsData is the data passed to the results component.
+type resultsData struct {
+	Result    string
+	Findings  []review.FindingGroup
+	Manifest  *review.Manifest // Files of a module review
+	Cost      *llm.Cost        // Estimated cost of the review, nil for unpriced models
+	ReviewID  string
+	Streaming bool
+	Progress  string // Progress of a streaming review before its content arrives
+}
+
+// streamRenderInterval limits how often partial results are re-rendered
+// and pushed to the browser while a review is streaming.
+const streamRenderInterval = 100 * time.Millisecond
+
+// reviewSavedEvent is the htmx event triggered when a review is added to the history.
+const reviewSavedEvent = "reviewSaved"
+
 // postReview handles the code review form submission.
 func (h *IndexHandler) postReview(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		h.handleError(w, r, http.StatusBadRequest, "フォームデータの解析に失敗しました。")
+	req, msg := h.parseReviewForm(w, r)
+	if msg != "" {
+		h.handleError(w, r, http.StatusBadRequest, msg)
 		return
 	}
 
-	// Extract form values with defaults
-	code := getFormValueWithDefault(r, "code", "")
-	language := getFormValueWithDefault(r, "language", defaultLanguage)
-	detailLevel := getFormValueWithDefault(r, "detailLevel", defaultDetailLevel)
-	strictness := getFormValueWithDefault(r, "strictness", defaultStrictness)
-	modelName := getFormValueWithDefault(r, "model", "")
+	// Call the AI service
+	reviewObj, err := h.reviews.Review(r.Context(), *req)
+	if err != nil {
+		h.handleError(w, r, reviewErrorCode(err), err)
+		return
+	}
+
+	// Let the page refresh the review history
+	w.Header().Set("HX-Trigger", reviewSavedEvent)
+
+	// Render the results
+	h.templates.RenderComponent(w, r, "components/results", newResultsData(reviewObj))
+}
 
-	if code == "" {
-		h.handleError(w, r, http.StatusBadRequest, "コードが入力されていません。")
+// postReviewStream handles the code review form submission and streams the
+// result to the browser as Server-Sent Events while it is being generated.
+// Each event carries the results component rendered from the markdown received so far.
+// Invalid forms are answered with the error component rather than a stream.
+func (h *IndexHandler) postReviewStream(w http.ResponseWriter, r *http.Request) {
+	// The form must be read before the stream starts: once the response header
+	// is flushed, the unread request body is discarded
+	req, msg := h.parseReviewForm(w, r)
+	if msg != "" {
+		h.handleError(w, r, http.StatusBadRequest, msg)
 		return
 	}
 
-	if len(code) > 50_000 { // Limit input length to 50_000 characters for now
-		h.handleError(w, r, http.StatusBadRequest, "入力が長すぎます。短縮して再試行してください。")
+	sse, err := newSSEWriter(w)
+	if err != nil {
+		h.handleError(w, r, http.StatusInternalServerError, err)
 		return
 	}
 
-	// Get the selected model or use default
-	var selectedModel llm.Model
-	if modelName != "" {
-		// Find the model by name
-		availableModels := h.completer.GetAvailableModels()
-		for _, model := range availableModels {
-			if model.DisplayName == modelName {
-				selectedModel = model
-				break
-			}
+	var lastRender time.Time
+	reviewObj, err := h.reviews.StreamReview(r.Context(), *req, func(update review.StreamUpdate) error {
+		// The chunks of large reviews are reported as they are reviewed
+		if update.Parts > 0 {
+			return h.streamResult(sse, sseEventChunk, resultsData{
+				Progress:  fmt.Sprintf("パート %d / %d のレビューが完了しました。", update.Part, update.Parts),
+				Streaming: true,
+			})
+		}
+
+		// Throttle re-rendering of the accumulated markdown
+		if time.Since(lastRender) < streamRenderInterval {
+			return nil
 		}
+		lastRender = time.Now()
+
+		return h.streamResult(sse, sseEventChunk, resultsData{
+			Result:    update.Content,
+			Streaming: true,
+		})
+	})
+	if err != nil {
+		h.streamError(sse, r, reviewErrorCode(err), err)
+		return
 	}
 
-	// If no model was selected or found, use the default
-	if selectedModel.Name == "" {
-		selectedModel = openai.ModelGPT4o
-		logger.Info(r.Context(), "using default model", "model", selectedModel.Name)
+	if err := h.streamResult(sse, sseEventDone, newResultsData(reviewObj)); err != nil {
+		logger.Error(r.Context(), "failed to stream review result", "err", err)
 	}
+}
 
-	// Build the custom prompt for the AI
-	customPrompt := buildCustomPrompt(language, detailLevel, strictness)
+// reviewErrorCode returns the status code of an error returned by the review service.
+func reviewErrorCode(err error) int {
+	if errors.Is(err, review.ErrInvalidDiff) || errors.Is(err, review.ErrTooManyFiles) {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, llm.ErrBudgetExceeded) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
 
-	// Call the AI service
-	ret, err := h.completer.Complete(r.Context(), llm.CompleteParams{
-		Messages: []llm.Message{
-			{
-				Role:    llm.RoleSystem,
-				Content: customPrompt,
-			},
-			{
-				Role:    llm.RoleUser,
-				Content: code,
-			},
-		},
-	}, selectedModel)
-	if err != nil {
-		h.handleError(w, r, http.StatusInternalServerError, err)
-		return
+// newResultsData returns the results component data for a review.
+func newResultsData(rv *review.Review) resultsData {
+	return resultsData{
+		Result:   rv.Result,
+		Findings: review.GroupBySeverity(rv.Findings),
+		Manifest: rv.Manifest,
+		Cost:     rv.Cost,
+		ReviewID: rv.ID,
 	}
+}
 
-	// Create a review object for persistence
-	reviewObj := review.NewReview(code, language, detailLevel, strictness, ret.Messages[0].Content)
+// streamResult renders the results component and sends it as an event.
+func (h *IndexHandler) streamResult(sse *sseWriter, event string, data resultsData) error {
+	var buf bytes.Buffer
+	if err := h.templates.ExecuteComponent(&buf, "components/results", data); err != nil {
+		return fmt.Errorf("rendering results: %w", err)
+	}
+	return sse.Event(event, buf.String())
+}
 
-	// Render the results
-	h.templates.RenderComponent(w, r, "components/results", struct {
-		Result   string
-		ReviewID string
+// streamError renders an error message and sends it as an error event.
+func (h *IndexHandler) streamError(sse *sseWriter, r *http.Request, code int, err any) {
+	message := determineErrorMessage(code, err)
+
+	if code == http.StatusInternalServerError {
+		// Log internal server errors
+		logger.Error(r.Context(), "internal server error", "err", err)
+	}
+
+	var buf bytes.Buffer
+	if err := h.templates.ExecuteComponent(&buf, "components/error", struct {
+		Message string
 	}{
-		Result:   ret.Messages[0].Content,
-		ReviewID: reviewObj.ID,
-	})
-}
+		Message: message,
+	}); err != nil {
+		logger.Error(r.Context(), "Failed to execute template", "err", err)
+		return
+	}
 
-// getFormValueWithDefault retrieves a form value or returns the default if empty.
-func getFormValueWithDefault(r *http.Request, key, defaultValue string) string {
-	value := r.FormValue(key)
-	if value == "" {
-		return defaultValue
+	if err := sse.Event(sseEventError, buf.String()); err != nil {
+		logger.Error(r.Context(), "failed to stream error", "err", err)
 	}
-	return value
 }
 
-// buildCustomPrompt constructs the AI prompt based on the review parameters.
-func buildCustomPrompt(language, detailLevel, strictness string) string {
-	// Base prompt with language specification
-	customPrompt := systemPrompt + "\nprogramming language: " + language + "\n" +
-		"Format your response in Markdown. Use headings, lists, code blocks, etc. to make your review clear and readable.\n\n"
+// uploadMemory is the maximum size of uploaded files kept in memory while parsing
+// a review form. Larger uploads are buffered to temporary files.
+const uploadMemory = 32 << 20
+
+// parseReviewForm extracts and validates the review parameters from the request.
+// Files uploaded with a multipart form are reviewed as a module instead of the code.
+// It returns a user-facing message when the request is invalid.
+func (h *IndexHandler) parseReviewForm(w http.ResponseWriter, r *http.Request) (*review.Request, string) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		r.Body = http.MaxBytesReader(w, r.Body, review.MaxUploadSize+review.MaxCodeLength)
+		if err := r.ParseMultipartForm(uploadMemory); err != nil {
+			return nil, "アップロードされたファイルが大きすぎるか、フォームデータの解析に失敗しました。"
+		}
+	} else if err := r.ParseForm(); err != nil {
+		return nil, "フォームデータの解析に失敗しました。"
+	}
 
-	// Add detail level instructions
-	customPrompt += getDetailLevelInstructions(detailLevel)
+	// Extract form values with defaults
+	req := &review.Request{
+		Code:        getFormValueWithDefault(r, "code", ""),
+		Language:    getFormValueWithDefault(r, "language", review.DefaultLanguage),
+		DetailLevel: getFormValueWithDefault(r, "detailLevel", review.DefaultDetailLevel),
+		Strictness:  getFormValueWithDefault(r, "strictness", review.DefaultStrictness),
+		NoCache:     getFormValueWithDefault(r, "noCache", "") == "true",
+	}
+	modelName := getFormValueWithDefault(r, "model", "")
 
-	// Add strictness instructions
-	customPrompt += getStrictnessInstructions(strictness)
+	files, msg := readUploadedFiles(r)
+	if msg != "" {
+		return nil, msg
+	}
+	if len(files) > 0 {
+		req.Code, req.Files, req.Language = "", files, ""
+	}
 
-	return customPrompt
+	if req.Code == "" && len(req.Files) == 0 {
+		return nil, "コードが入力されていません。"
+	}
+
+	if len(req.Code) > review.MaxCodeLength {
+		return nil, "入力が長すぎます。短縮して再試行してください。"
+	}
+
+	// Get the selected model or use the default if it is not available
+	model, err := h.reviews.ResolveModel(modelName)
+	if err != nil {
+		model = review.DefaultModel
+		logger.Info(r.Context(), "using default model", "model", model.Name, "err", err)
+	}
+	req.Model = model
+
+	return req, ""
 }
 
-// getDetailLevelInstructions returns the prompt text for the specified detail level.
-func getDetailLevelInstructions(detailLevel string) string {
-	switch detailLevel {
-	case "low":
-		return "Detail level: Low - Provide a concise overview with only the most important points. Focus on major issues and skip minor details.\n"
-	case "high":
-		return "Detail level: High - Provide an in-depth analysis with detailed explanations and specific improvement suggestions for each issue found.\n"
-	default: // medium
-		return "Detail level: Medium - Provide a balanced review with reasonable detail on important issues.\n"
+// readUploadedFiles reads the files uploaded with the review form, expanding archives.
+// It returns a user-facing message when the files cannot be read.
+func readUploadedFiles(r *http.Request) ([]review.File, string) {
+	if r.MultipartForm == nil {
+		return nil, ""
+	}
+
+	var files []review.File
+	for _, header := range r.MultipartForm.File["files"] {
+		f, err := header.Open()
+		if err != nil {
+			logger.Error(r.Context(), "failed to open uploaded file", "file", header.Filename, "err", err)
+			return nil, "アップロードされたファイルを読み込めませんでした。"
+		}
+		uploaded, err := review.ReadUpload(header.Filename, f)
+		f.Close()
+		if err != nil {
+			logger.Info(r.Context(), "invalid upload", "file", header.Filename, "err", err)
+			return nil, fmt.Sprintf("%s を読み込めませんでした。対応している形式は zip, tar, tar.gz とテキストファイルです。", header.Filename)
+		}
+		files = append(files, uploaded...)
 	}
+
+	if len(r.MultipartForm.File["files"]) > 0 && len(files) == 0 {
+		return nil, "アップロードされたファイルにレビュー可能なソースコードが含まれていません。"
+	}
+	if len(files) > review.MaxFiles {
+		return nil, fmt.Sprintf("ファイルが多すぎます。%d ファイル以下にしてください。", review.MaxFiles)
+	}
+	return files, ""
 }
 
-// getStrictnessInstructions returns the prompt text for the specified strictness level.
-func getStrictnessInstructions(strictness string) string {
-	switch strictness {
-	case "low":
-		return "Strictness: Low - Focus only on critical issues like bugs, security problems, and major performance concerns. Ignore minor style issues.\n"
-	case "high":
-		return "Strictness: High - Apply strict best practices and standards. Point out all issues including minor style concerns, potential edge cases, and optimization opportunities.\n"
-	default: // medium
-		return "Strictness: Medium - Apply reasonable standards focusing on important issues while mentioning some style and optimization concerns.\n"
+// getFormValueWithDefault retrieves a form value or returns the default if empty.
+func getFormValueWithDefault(r *http.Request, key, defaultValue string) string {
+	value := r.FormValue(key)
+	if value == "" {
+		return defaultValue
 	}
+	return value
 }
 
 // handleError renders an error message to the user.
@@ -237,12 +345,18 @@ func determineErrorMessage(code int, err any) string {
 	// Handle Go errors with specific error types
 	if goErr, ok := err.(error); ok {
 		switch {
+		case errors.Is(goErr, review.ErrTooManyFiles):
+			return fmt.Sprintf("ファイルが多すぎます。%d ファイル以下にしてください。", review.MaxFiles)
+		case errors.Is(goErr, review.ErrInvalidDiff):
+			return "差分を解析できませんでした。unified diff 形式で入力してください。"
 		case errors.Is(goErr, llm.ErrContextLengthExceeded):
 			return "入力が長すぎます。短縮して再試行してください。"
 		case errors.Is(goErr, llm.ErrServiceUnavailable):
 			return "AIサービスが利用できません。しばらくしてから再試行してください。"
 		case errors.Is(goErr, llm.ErrTooManyRequests):
 			return "リクエストが多すぎます。しばらくしてから再試行してください。"
+		case errors.Is(goErr, llm.ErrBudgetExceeded):
+			return "利用上限に達しました。しばらくしてから再試行してください。"
 		}
 	}
 
@@ -253,33 +367,3 @@ func determineErrorMessage(code int, err any) string {
 
 	return "無効なリクエストです。"
 }
-
-// systemPrompt is the base prompt for the AI code review system.
-var systemPrompt = `あなたはプログラミングとソフトウェア開発に特化したAIアシスタントです。
-
-提供された入力に対して、以下のように応答してください：
-
-1. コードが提示された場合は、以下の観点からコードレビューを行います：
-   - バグやエラーの可能性
-   - セキュリティ上の問題点
-   - パフォーマンスの最適化
-   - コーディング規約への準拠
-   - 可読性と保守性の向上
-   - ベストプラクティスの適用
-
-2. プログラミングに関する質問の場合は、以下の点を意識して回答します：
-   - 正確で最新の情報
-   - わかりやすい説明と具体例
-   - 複雑な概念の段階的な解説
-   - 適切なコードサンプル（必要に応じて）
-
-常に簡潔かつ具体的に回答し、余計な会話履歴を表示せず、常に新しい質問に対して直接的に応答してください。
-回答はMarkdown形式で提供し、必要に応じてコードブロックやリストを使用して情報を整理してください。
-
-[Important Note]
-1. コードレビュー結果は必ず日本語で返してください
-2. コードが提供されなかった場合は、コードレビューを行わずにエラーメッセージを返してください
-3. コードレビュアーとしての役割に徹し、無関係な情報を提供しないようにしてください
-
-[Settings]
-`
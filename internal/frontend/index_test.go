@@ -0,0 +1,87 @@
+package frontend
+
+import (
+	"coda/internal/config"
+	"coda/internal/llm"
+	"coda/internal/review"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// fakeCompleter streams a canned structured review in one chunk.
+type fakeCompleter struct {
+	llm.Completer
+}
+
+func (fakeCompleter) GetAvailableModels() []llm.Model {
+	return []llm.Model{review.DefaultModel}
+}
+
+func (fakeCompleter) Stream(_ context.Context, _ llm.CompleteParams, _ llm.Model, fn llm.StreamFunc) (*llm.CompleteResponse, error) {
+	content := `{"summary": "ok", "findings": [{"severity": "major", "category": "bug", "startLine": 1, "message": "m"}]}`
+	if err := fn(llm.StreamChunk{Delta: content}); err != nil {
+		return nil, err
+	}
+	return &llm.CompleteResponse{Messages: []llm.Message{{Content: content}}}, nil
+}
+
+func TestPostReviewStream(t *testing.T) {
+	tm, err := newTemplateManager(&config.Config{})
+	if err != nil {
+		t.Fatalf("newTemplateManager() error = %v", err)
+	}
+	r := chi.NewMux()
+	newIndex(tm, review.NewService(fakeCompleter{}, review.NewMemoryStore(), nil)).RegisterRoutes(r)
+
+	// A real server discards the unread request body once the response starts
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name            string
+		form            url.Values
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "review",
+			form:            url.Values{"code": {"x = 1"}, "language": {"python"}},
+			wantContentType: "text/event-stream",
+			wantBody:        "event: done\n",
+		},
+		{
+			name:            "missing code",
+			form:            url.Values{"language": {"python"}},
+			wantContentType: "text/html",
+			wantBody:        "コードが入力されていません。",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := http.PostForm(srv.URL+"/review/stream", tt.form)
+			if err != nil {
+				t.Fatalf("POST error = %v", err)
+			}
+			defer res.Body.Close()
+			body, err := io.ReadAll(res.Body)
+			if err != nil {
+				t.Fatalf("reading body: %v", err)
+			}
+
+			if ct := res.Header.Get("Content-Type"); !strings.HasPrefix(ct, tt.wantContentType) {
+				t.Errorf("Content-Type = %q, want %s", ct, tt.wantContentType)
+			}
+			if !strings.Contains(string(body), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %q", body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -13,6 +13,7 @@ var Module = fx.Module("frontend",
 	fx.Provide(newFrontend),          // Provides the main Frontend instance
 	fx.Provide(newTemplateManager),   // Provides the template manager
 	fx.Provide(newIndex),             // Provides the index page handler
+	fx.Provide(newHistory),           // Provides the review history handler
 	fx.Invoke(registerLifetimeHooks), // Registers lifecycle hooks
 )
 
@@ -0,0 +1,60 @@
+package frontend
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Server-Sent Event names used by the streaming review endpoint
+const (
+	sseEventChunk = "chunk" // Progressively rendered result
+	sseEventDone  = "done"  // Final rendered result
+	sseEventError = "error" // Rendered error message
+)
+
+// errStreamingUnsupported is returned when the response writer cannot be flushed.
+var errStreamingUnsupported = errors.New("streaming unsupported")
+
+// sseWriter writes Server-Sent Events to an HTTP response.
+// See: https://html.spec.whatwg.org/multipage/server-sent-events.html
+type sseWriter struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+// newSSEWriter prepares the response for streaming and returns a writer for it.
+func newSSEWriter(w http.ResponseWriter) (*sseWriter, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errStreamingUnsupported
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// Disable response buffering in reverse proxies
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	return &sseWriter{w: w, flusher: flusher}, nil
+}
+
+// Event writes a single event and flushes it to the client.
+// Multi-line data is split across several data fields as required by the spec.
+func (s *sseWriter) Event(name, data string) error {
+	var b strings.Builder
+	b.WriteString("event: " + name + "\n")
+	for _, line := range strings.Split(data, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	if _, err := fmt.Fprint(s.w, b.String()); err != nil {
+		return fmt.Errorf("writing event: %w", err)
+	}
+	s.flusher.Flush()
+	return nil
+}
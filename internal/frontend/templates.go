@@ -2,11 +2,14 @@ package frontend
 
 import (
 	"coda/internal/config"
+	"coda/internal/llm"
 	"coda/internal/logger"
+	"coda/internal/review"
 	"context"
 	"embed"
 	"fmt"
 	"html/template"
+	"io"
 	"io/fs"
 	"log"
 	"net/http"
@@ -88,6 +91,46 @@ func renderMarkdown(md string) template.HTML {
 	return template.HTML(safeHTML) //nolint:gosec
 }
 
+// severityLabel returns the display label of a finding severity.
+func severityLabel(severity review.Severity) string {
+	switch severity {
+	case review.SeverityCritical:
+		return "重大"
+	case review.SeverityMajor:
+		return "高"
+	case review.SeverityMinor:
+		return "中"
+	default:
+		return "情報"
+	}
+}
+
+// categoryLabel returns the display label of a finding category.
+func categoryLabel(category review.Category) string {
+	switch category {
+	case review.CategoryBug:
+		return "バグ"
+	case review.CategorySecurity:
+		return "セキュリティ"
+	case review.CategoryPerformance:
+		return "パフォーマンス"
+	case review.CategoryStyle:
+		return "スタイル"
+	case review.CategoryMaintainability:
+		return "保守性"
+	default:
+		return "その他"
+	}
+}
+
+// formatCost returns the display label of the estimated cost of a review.
+func formatCost(cost *llm.Cost) string {
+	if cost.Currency == "USD" {
+		return fmt.Sprintf("$%.4f", cost.Total)
+	}
+	return fmt.Sprintf("%.4f %s", cost.Total, cost.Currency)
+}
+
 // newTemplateManager creates a new TemplateManager with the given configuration.
 // It initializes the template cache, sets up template functions, and loads templates.
 func newTemplateManager(cfg *config.Config) (*TemplateManager, error) {
@@ -96,7 +139,10 @@ func newTemplateManager(cfg *config.Config) (*TemplateManager, error) {
 		"appEnv": func() string {
 			return string(cfg.Global.Env)
 		},
-		"markdown": renderMarkdown,
+		"markdown":      renderMarkdown,
+		"severityLabel": severityLabel,
+		"categoryLabel": categoryLabel,
+		"formatCost":    formatCost,
 	}
 
 	// Create template manager with default settings
@@ -252,6 +298,13 @@ func (tm *TemplateManager) RenderComponent(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+// ExecuteComponent executes a component template into the given writer.
+// Unlike RenderComponent, it leaves error handling to the caller, which makes it
+// suitable for rendering fragments that are not written directly to a response.
+func (tm *TemplateManager) ExecuteComponent(w io.Writer, name string, data any) error {
+	return tm.components.ExecuteTemplate(w, name, data)
+}
+
 // Render renders a full page template with the given data.
 // It sets appropriate headers and handles errors.
 func (tm *TemplateManager) Render(w http.ResponseWriter, r *http.Request, name string, data any) {
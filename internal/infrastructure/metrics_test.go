@@ -0,0 +1,45 @@
+package infrastructure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHTTPMetrics(t *testing.T) {
+	m, err := newHTTPMetrics(prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("newHTTPMetrics() error = %v", err)
+	}
+
+	r := chi.NewMux()
+	r.Use(m.middleware)
+	r.Get("/api/v1/reviews/{id}", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	r.Get("/health", func(http.ResponseWriter, *http.Request) {})
+
+	for _, path := range []string{"/api/v1/reviews/1", "/api/v1/reviews/2", "/health", "/unknown"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	tests := []struct {
+		route  string
+		status string
+		want   float64
+	}{
+		{route: "/api/v1/reviews/{id}", status: "404", want: 2},
+		{route: "/health", status: "200", want: 1},
+		{route: unmatchedRoute, status: "404", want: 1},
+	}
+	for _, tt := range tests {
+		got := testutil.ToFloat64(m.requests.WithLabelValues(http.MethodGet, tt.route, tt.status))
+		if got != tt.want {
+			t.Errorf("requests{route=%q, status=%s} = %v, want %v", tt.route, tt.status, got, tt.want)
+		}
+	}
+}
@@ -1,21 +1,170 @@
 package infrastructure
 
 import (
+	"coda/internal/llm"
 	"coda/internal/logger"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net"
 	"net/http"
 	"runtime/debug"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// withTracing is a middleware that traces each request as a span, named
+// after the matched route once the router has found it. The trace context
+// sent by the caller is continued.
+func withTracing(next http.Handler) http.Handler {
+	route := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+
+		rctx := chi.RouteContext(r.Context())
+		if rctx == nil || rctx.RoutePattern() == "" {
+			return
+		}
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(r.Method + " " + rctx.RoutePattern())
+		span.SetAttributes(attribute.String("http.route", rctx.RoutePattern()))
+	})
+
+	return otelhttp.NewHandler(route, "http",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method
+		}),
+	)
+}
+
+// withRealIP is a middleware that sets the remote address of the requests
+// forwarded by a trusted proxy to the client address reported in their
+// X-Forwarded-For or X-Real-IP header. The headers sent by other peers are
+// ignored, since clients can set them to anything.
+func withRealIP(trustedProxies []*net.IPNet) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ip := clientIP(r, trustedProxies); ip != "" {
+				r.RemoteAddr = ip
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// clientIP returns the client address reported by the trusted proxy
+// forwarding the request, or "" when the peer is not a trusted proxy.
+// X-Forwarded-For is read from the right, skipping the trusted proxies, as the
+// leftmost entries are set by the client.
+func clientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !isTrustedProxy(net.ParseIP(host), trustedProxies) {
+		return ""
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := net.ParseIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				break
+			}
+			if i == 0 || !isTrustedProxy(ip, trustedProxies) {
+				return ip.String()
+			}
+		}
+	}
+	if ip := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// isTrustedProxy reports whether the IP belongs to one of the trusted proxies.
+func isTrustedProxy(ip net.IP, trustedProxies []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses the IPs and CIDRs of the trusted proxies.
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		if ip := net.ParseIP(p); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", p, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// apiKeyHeader is the header carrying the API key of a caller.
+const apiKeyHeader = "X-API-Key"
+
+// withCaller is a middleware that identifies the caller of the request for its
+// spending budget: by API key when it sends one of the given keys, otherwise
+// by IP address. Keys are hashed so that they do not leak into the logs.
+func withCaller(apiKeys []string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := llm.WithCaller(r.Context(), callerOf(r, apiKeys))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// callerOf returns the identity of the caller of the request.
+func callerOf(r *http.Request, apiKeys []string) string {
+	if key := r.Header.Get(apiKeyHeader); key != "" {
+		for _, k := range apiKeys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+				sum := sha256.Sum256([]byte(key))
+				return "key:" + hex.EncodeToString(sum[:8])
+			}
+		}
+	}
+
+	// withRealIP leaves the port when the request was not forwarded by a trusted proxy
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
 // withLogger is a middleware that logs the request details.
 func withLogger(lg logger.Logger) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			lg.Info("Request started", "method", r.Method, "path", r.URL.Path, "host", r.Host, "url", r.URL.String(),
+			ctx := logger.WithLogger(r.Context(), lg)
+			logger.Info(ctx, "Request started", "method", r.Method, "path", r.URL.Path, "host", r.Host, "url", r.URL.String(),
 				"remote", r.RemoteAddr, "user_agent", r.UserAgent(),
 				"referer", r.Referer(), "proto", r.Proto, "content_length", r.ContentLength, "request_uri", r.RequestURI,
 			)
 
-			ctx := logger.WithLogger(r.Context(), lg)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
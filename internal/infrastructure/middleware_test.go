@@ -0,0 +1,115 @@
+package infrastructure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWithTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	r := chi.NewMux()
+	r.Use(withTracing)
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Get("/reviews/{id}", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reviews/42", nil)
+	req.Header.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("spans = %d, want 1", len(spans))
+	}
+	span := spans[0]
+	if span.Name != "GET /api/v1/reviews/{id}" {
+		t.Errorf("span name = %q, want GET /api/v1/reviews/{id}", span.Name)
+	}
+	if got := span.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID = %s, want the caller's", got)
+	}
+}
+
+func TestCallerOf(t *testing.T) {
+	apiKeys := []string{"secret"}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		apiKey     string
+		want       string
+	}{
+		{name: "direct", remoteAddr: "192.0.2.1:54321", want: "ip:192.0.2.1"},
+		{name: "behind a proxy", remoteAddr: "203.0.113.7", want: "ip:203.0.113.7"},
+		{name: "IPv6", remoteAddr: "[2001:db8::1]:443", want: "ip:2001:db8::1"},
+		{name: "API key", remoteAddr: "192.0.2.1:54321", apiKey: "secret", want: "key:2bb80d537b1da3e3"},
+		{name: "unknown API key", remoteAddr: "192.0.2.1:54321", apiKey: "guess", want: "ip:192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/reviews", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.apiKey != "" {
+				req.Header.Set(apiKeyHeader, tt.apiKey)
+			}
+
+			if got := callerOf(req, apiKeys); got != tt.want {
+				t.Errorf("callerOf() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.10"})
+	if err != nil {
+		t.Fatalf("parseTrustedProxies() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		realIP       string
+		want         string
+	}{
+		{name: "direct", remoteAddr: "198.51.100.1:54321", want: ""},
+		{name: "spoofed by a client", remoteAddr: "198.51.100.1:54321", forwardedFor: "203.0.113.7", realIP: "203.0.113.8", want: ""},
+		{name: "trusted proxy", remoteAddr: "10.1.2.3:54321", forwardedFor: "203.0.113.7", want: "203.0.113.7"},
+		{name: "trusted proxy IP", remoteAddr: "192.0.2.10:54321", forwardedFor: "203.0.113.7", want: "203.0.113.7"},
+		{name: "spoofed behind a proxy", remoteAddr: "10.1.2.3:54321", forwardedFor: "1.2.3.4, 203.0.113.7, 10.4.5.6", want: "203.0.113.7"},
+		{name: "only proxies", remoteAddr: "10.1.2.3:54321", forwardedFor: "10.7.8.9, 10.4.5.6", want: "10.7.8.9"},
+		{name: "real IP", remoteAddr: "10.1.2.3:54321", realIP: "203.0.113.7", want: "203.0.113.7"},
+		{name: "invalid header", remoteAddr: "10.1.2.3:54321", forwardedFor: "unknown", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/reviews", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			if got := clientIP(req, proxies); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
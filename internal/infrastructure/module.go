@@ -1,8 +1,10 @@
 package infrastructure
 
 import (
+	"coda/internal/api"
 	"coda/internal/frontend"
 	"coda/internal/logger"
+	"coda/internal/metrics"
 
 	"go.uber.org/fx"
 )
@@ -10,5 +12,7 @@ import (
 var Module = fx.Module("infrastructure",
 	fx.Provide(NewServer),
 	frontend.Module,
+	api.Module,
 	logger.Module,
+	metrics.Module,
 )
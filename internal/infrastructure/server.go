@@ -12,14 +12,17 @@ import (
 	"syscall"
 	"time"
 
+	"coda/internal/api"
 	"coda/internal/config"
 	"coda/internal/frontend"
 	"coda/internal/logger"
+	"coda/internal/metrics"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/go-chi/httplog/v2"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/fx"
 )
 
@@ -28,27 +31,46 @@ type ServerConfig struct {
 }
 
 type Server struct {
-	config     ServerConfig
-	appConfig  *config.Config
-	httpServer *http.Server
-	logger     logger.Logger
-	frontend   *frontend.Frontend
+	config      ServerConfig
+	appConfig   *config.Config
+	httpServer  *http.Server
+	metrics     *http.Server // Listener of /metrics, nil when metrics are not served
+	logger      logger.Logger
+	frontend    *frontend.Frontend
+	api         *api.API
+	registry    *prometheus.Registry
+	httpMetrics *httpMetrics
+	proxies     []*net.IPNet // Trusted reverse proxies
 }
 
 func NewServer(
 	logger logger.Logger,
 	config *config.Config,
 	frontend *frontend.Frontend,
-) *Server {
+	api *api.API,
+	registry *prometheus.Registry,
+) (*Server, error) {
 	serverCfg := ServerConfig{
 		ShutdownTimeout: 5 * time.Second,
 	}
-	return &Server{
-		config:    serverCfg,
-		appConfig: config,
-		logger:    logger,
-		frontend:  frontend,
+	httpMetrics, err := newHTTPMetrics(registry)
+	if err != nil {
+		return nil, err
+	}
+	proxies, err := parseTrustedProxies(config.Server.TrustedProxies)
+	if err != nil {
+		return nil, err
 	}
+	return &Server{
+		config:      serverCfg,
+		appConfig:   config,
+		logger:      logger,
+		frontend:    frontend,
+		api:         api,
+		registry:    registry,
+		httpMetrics: httpMetrics,
+		proxies:     proxies,
+	}, nil
 }
 
 func (srv *Server) Serve(ctx context.Context) error {
@@ -64,11 +86,14 @@ func (srv *Server) Serve(ctx context.Context) error {
 	// create a type that satisfies the `api.ServerInterface`, which contains
 	// an implementation of every operation from the generated code
 	r := chi.NewMux()
-	r.Use(middleware.RealIP)
+	r.Use(withRealIP(srv.proxies))
+	r.Use(withTracing)
+	r.Use(srv.httpMetrics.middleware)
 	r.Use(middleware.Compress(5))
 	r.Use(httplog.RequestLogger(requestLogger))
 	r.Use(withLogger(srv.logger))
 	r.Use(withRecoverer)
+	r.Use(withCaller(srv.appConfig.Budget.APIKeys))
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   srv.appConfig.Server.AllowedOrigins,
 		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
@@ -77,6 +102,7 @@ func (srv *Server) Serve(ctx context.Context) error {
 	}))
 
 	frontend.ConfigureRoutes(srv.frontend, r)
+	api.ConfigureRoutes(srv.api, r)
 
 	addr := net.JoinHostPort(srv.appConfig.Server.Host, strconv.Itoa(srv.appConfig.Server.Port))
 	srv.httpServer = &http.Server{
@@ -92,11 +118,43 @@ func (srv *Server) Serve(ctx context.Context) error {
 		}
 	}()
 
+	srv.serveMetrics()
+
 	srv.gracefulShutdown(ctx)
 	return nil
 }
 
+// serveMetrics serves /metrics on its own listener, kept apart from the public
+// port since the metrics are not authenticated. Nothing is served when no
+// address is configured.
+func (srv *Server) serveMetrics() {
+	addr := srv.appConfig.Server.MetricsAddr
+	if addr == "" {
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler(srv.registry))
+	srv.metrics = &http.Server{
+		Handler:           mux,
+		Addr:              addr,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		srv.logger.Info("Metrics server is starting", "addr", addr)
+		if err := srv.metrics.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			srv.logger.Error("ListenAndServe() of metrics", "err", err)
+		}
+	}()
+}
+
 func (srv *Server) Shutdown(ctx context.Context) error {
+	if srv.metrics != nil {
+		if err := srv.metrics.Shutdown(ctx); err != nil {
+			srv.logger.Error("Could not shutdown the metrics server", "err", err)
+		}
+	}
 	if srv.httpServer != nil {
 		srv.logger.Info("Server is shutting down")
 		return srv.httpServer.Shutdown(ctx)
@@ -118,6 +176,11 @@ func (srv *Server) gracefulShutdown(ctx context.Context) {
 	if err := srv.httpServer.Shutdown(ctx); err != nil {
 		srv.logger.Error("Could not gracefully shutdown the server", "err", err)
 	}
+	if srv.metrics != nil {
+		if err := srv.metrics.Shutdown(ctx); err != nil {
+			srv.logger.Error("Could not gracefully shutdown the metrics server", "err", err)
+		}
+	}
 
 	srv.logger.Info("Server stopped")
 }
@@ -0,0 +1,525 @@
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"coda/internal/llm"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Supported models
+// https://docs.anthropic.com/en/docs/about-claude/models
+var (
+	ModelClaudeSonnet = llm.Model{
+		Name:          "claude-3-7-sonnet-latest",
+		DisplayName:   "Anthropic Claude 3.7 Sonnet",
+		Provider:      llm.Anthropic,
+		MaxToken:      8192,
+		ContextWindow: 200_000,
+		PDFSupported:  true,
+		Version:       "2025-02-19",
+		Family:        "Claude",
+		Pricing: &llm.ModelPricing{
+			InputPerToken:  0.000003,
+			OutputPerToken: 0.000015,
+			Currency:       "USD",
+		},
+		Capabilities: llm.ModelCapabilities{
+			SupportsStreaming: true,
+			SupportsFunctions: true,
+			SupportsVision:    true,
+			SupportsJSON:      true,
+		},
+	}
+
+	ModelClaudeHaiku = llm.Model{
+		Name:          "claude-3-5-haiku-latest",
+		DisplayName:   "Anthropic Claude 3.5 Haiku",
+		Provider:      llm.Anthropic,
+		MaxToken:      8192,
+		ContextWindow: 200_000,
+		PDFSupported:  false,
+		Version:       "2024-10-22",
+		Family:        "Claude",
+		Pricing: &llm.ModelPricing{
+			InputPerToken:  0.0000008,
+			OutputPerToken: 0.000004,
+			Currency:       "USD",
+		},
+		Capabilities: llm.ModelCapabilities{
+			SupportsStreaming: true,
+			SupportsFunctions: true,
+			SupportsVision:    false,
+			SupportsJSON:      true,
+		},
+	}
+)
+
+// API defaults
+const (
+	DefaultBaseURL   = "https://api.anthropic.com"
+	APIVersion       = "2023-06-01"
+	DefaultMaxTokens = 4096 // Used when neither the request nor the model sets a limit
+)
+
+// Error types from the Anthropic API
+const (
+	ErrTypeInvalidRequest  = "invalid_request_error"
+	ErrTypeAuthentication  = "authentication_error"
+	ErrTypePermission      = "permission_error"
+	ErrTypeNotFound        = "not_found_error"
+	ErrTypeRequestTooLarge = "request_too_large"
+	ErrTypeRateLimit       = "rate_limit_error"
+	ErrTypeAPI             = "api_error"
+	ErrTypeOverloaded      = "overloaded_error"
+)
+
+// statusOverloaded is the HTTP status code of overloaded errors.
+const statusOverloaded = 529
+
+// Ensure Client implements the LLM interface
+var _ llm.LLM = (*Client)(nil)
+
+// Client is an Anthropic Messages API client that implements the LLM interface.
+type Client struct {
+	cfg        llm.Config
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New creates a new Anthropic client.
+func New(cfg llm.Config) (llm.LLM, error) {
+	if cfg.APIKeyFunc == nil {
+		return nil, fmt.Errorf("API key function is required")
+	}
+
+	baseURL := cfg.LLMConfig.Anthropic.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	return &Client{
+		cfg:        cfg,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: llm.NewHTTPClient(cfg.Timeout),
+	}, nil
+}
+
+// Complete processes the given parameters and returns a completion response.
+func (c *Client) Complete(
+	ctx context.Context,
+	params llm.CompleteParams,
+) (*llm.CompleteResponse, error) {
+	startTime := time.Now()
+
+	req, err := c.buildRequest(params)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var res messagesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, llm.NewLLMError(fmt.Errorf("decoding response: %w", err), string(llm.Anthropic), c.cfg.Model.Name)
+	}
+
+	// Convert response to our format
+	var (
+		content strings.Builder
+		calls   []*llm.FunctionCall
+	)
+	if prefill(params) {
+		content.WriteString("{")
+	}
+	for _, block := range res.Content {
+		switch block.Type {
+		case blockText:
+			content.WriteString(block.Text)
+		case blockToolUse:
+			calls = append(calls, &llm.FunctionCall{ID: block.ID, Name: block.Name, Arguments: string(block.Input)})
+		}
+	}
+
+	msgs, err := toMessages(content.String(), res.StopReason, calls, params.Functions)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.response(msgs, res.ID, res.StopReason, res.Usage, startTime), nil
+}
+
+// Stream processes the given parameters, delivering each generated delta to fn,
+// and returns the aggregated completion response.
+func (c *Client) Stream(
+	ctx context.Context,
+	params llm.CompleteParams,
+	fn llm.StreamFunc,
+) (*llm.CompleteResponse, error) {
+	startTime := time.Now()
+
+	req, err := c.buildRequest(params)
+	if err != nil {
+		return nil, err
+	}
+	req.Stream = true
+
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Accumulate the deltas while forwarding the text to the caller.
+	// Tool use blocks are accumulated by index until the end of the stream.
+	var (
+		content    strings.Builder
+		toolUses   = map[int]*llm.FunctionCall{}
+		messageID  string
+		stopReason string
+		tokens     usage
+	)
+	if prefill(params) {
+		content.WriteString("{")
+		if err := fn(llm.StreamChunk{Delta: "{"}); err != nil {
+			return nil, err
+		}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+
+		var event streamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return nil, llm.NewLLMError(fmt.Errorf("decoding stream event: %w", err), string(llm.Anthropic), c.cfg.Model.Name)
+		}
+
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				messageID = event.Message.ID
+				tokens.InputTokens = event.Message.Usage.InputTokens
+			}
+		case "content_block_start":
+			if event.ContentBlock != nil && event.ContentBlock.Type == blockToolUse {
+				toolUses[event.Index] = &llm.FunctionCall{ID: event.ContentBlock.ID, Name: event.ContentBlock.Name}
+			}
+		case "content_block_delta":
+			if event.Delta == nil {
+				continue
+			}
+			if call, ok := toolUses[event.Index]; ok {
+				call.Arguments += event.Delta.PartialJSON
+				continue
+			}
+			if event.Delta.Text == "" {
+				continue
+			}
+			content.WriteString(event.Delta.Text)
+			if err := fn(llm.StreamChunk{Delta: event.Delta.Text}); err != nil {
+				return nil, err
+			}
+		case "message_delta":
+			if event.Usage != nil {
+				tokens.OutputTokens = event.Usage.OutputTokens
+			}
+			if event.Delta != nil && event.Delta.StopReason != "" {
+				stopReason = event.Delta.StopReason
+				if err := fn(llm.StreamChunk{FinishReason: stopReason}); err != nil {
+					return nil, err
+				}
+			}
+		case "error":
+			if event.Error != nil {
+				return nil, c.apiError(0, event.Error.Type, event.Error.Message, "")
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, llm.NewLLMError(err, string(llm.Anthropic), c.cfg.Model.Name)
+	}
+
+	// Tool calls in the order of their content blocks
+	calls := make([]*llm.FunctionCall, 0, len(toolUses))
+	for _, i := range slices.Sorted(maps.Keys(toolUses)) {
+		calls = append(calls, toolUses[i])
+	}
+
+	msgs, err := toMessages(content.String(), stopReason, calls, params.Functions)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.response(msgs, messageID, stopReason, tokens, startTime), nil
+}
+
+// response builds the completion response.
+func (c *Client) response(msgs []llm.Message, id, stopReason string, tokens usage, startTime time.Time) *llm.CompleteResponse {
+	return &llm.CompleteResponse{
+		Messages: msgs,
+		Usage: &llm.Usage{
+			Unit:             "tokens",
+			PromptTokens:     tokens.InputTokens,
+			CompletionTokens: tokens.OutputTokens,
+			TotalTokens:      tokens.InputTokens + tokens.OutputTokens,
+		},
+		Metadata: llm.CompletionMetadata{
+			ModelName:     c.cfg.Model.Name,
+			FinishReason:  stopReason,
+			CompletionID:  id,
+			LatencyMs:     time.Since(startTime).Milliseconds(),
+			ProcessedAt:   time.Now().UTC(),
+			RequestTokens: tokens.InputTokens,
+		},
+	}
+}
+
+// send sends a Messages API request and returns the response, or the API error.
+func (c *Client) send(ctx context.Context, req messagesRequest) (*http.Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encoding request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-API-Key", c.cfg.APIKeyFunc())
+	httpReq.Header.Set("Anthropic-Version", APIVersion)
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, c.handleError(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+
+		var errResp errorResponse
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err := json.Unmarshal(data, &errResp); err != nil || errResp.Error.Message == "" {
+			errResp.Error.Message = strings.TrimSpace(string(data))
+		}
+		return nil, c.apiError(resp.StatusCode, errResp.Error.Type, errResp.Error.Message, resp.Header.Get("Request-Id"))
+	}
+
+	return resp, nil
+}
+
+// buildRequest converts the given parameters to a Messages API request.
+// System messages are sent as the system prompt, function calls as tool use
+// blocks and function results as tool result blocks of user messages.
+func (c *Client) buildRequest(params llm.CompleteParams) (messagesRequest, error) {
+	req := messagesRequest{
+		Model:       c.cfg.Model.Name,
+		MaxTokens:   DefaultMaxTokens,
+		Temperature: params.Temperature,
+		TopP:        params.TopP,
+	}
+	if c.cfg.Model.MaxToken > 0 {
+		req.MaxTokens = c.cfg.Model.MaxToken
+	}
+	if params.MaxTokens != nil {
+		req.MaxTokens = *params.MaxTokens
+	}
+
+	var (
+		system  []string
+		pending = map[string][]string{} // IDs of the unanswered calls of each function
+	)
+	for i, m := range params.Messages {
+		var (
+			role  string
+			block contentBlock
+		)
+		switch m.Role {
+		case llm.RoleSystem:
+			system = append(system, m.Content)
+			continue
+		case llm.RoleUser:
+			role, block = "user", contentBlock{Type: blockText, Text: m.Content}
+		case llm.RoleAssistant:
+			role, block = "assistant", contentBlock{Type: blockText, Text: m.Content}
+			if m.FunctionCall != nil {
+				// Tool use needs an ID to be answered. Calls from providers
+				// without IDs are given one from their position.
+				id := m.FunctionCall.ID
+				if id == "" {
+					id = fmt.Sprintf("call_%d", i)
+				}
+				pending[m.FunctionCall.Name] = append(pending[m.FunctionCall.Name], id)
+
+				input := json.RawMessage(m.FunctionCall.Arguments)
+				if strings.TrimSpace(m.FunctionCall.Arguments) == "" {
+					input = json.RawMessage("{}")
+				}
+				if !json.Valid(input) {
+					return messagesRequest{}, fmt.Errorf("%w: arguments of %s are not valid JSON", llm.ErrInvalidFunctionCall, m.FunctionCall.Name)
+				}
+				req.Messages = appendBlock(req.Messages, role, contentBlock{Type: blockText, Text: m.Content})
+				block = contentBlock{Type: blockToolUse, ID: id, Name: m.FunctionCall.Name, Input: input}
+			}
+		case llm.RoleFunction:
+			// Results without a call ID answer the oldest call of the function
+			id := m.CallID
+			if ids := pending[m.Name]; id == "" && len(ids) > 0 {
+				id = ids[0]
+			}
+			pending[m.Name] = slices.DeleteFunc(pending[m.Name], func(p string) bool { return p == id })
+			if id == "" {
+				return messagesRequest{}, fmt.Errorf("%w: no call of %s to answer", llm.ErrInvalidFunctionCall, m.Name)
+			}
+			role, block = "user", contentBlock{Type: blockToolResult, ToolUseID: id, Content: m.Content}
+		default:
+			return messagesRequest{}, fmt.Errorf("unsupported role: %s", m.Role)
+		}
+		req.Messages = appendBlock(req.Messages, role, block)
+	}
+	req.System = strings.Join(system, "\n\n")
+
+	for _, f := range params.Functions {
+		schema := f.Parameters
+		if schema == nil {
+			schema = map[string]any{"type": "object", "properties": map[string]any{}}
+		}
+		req.Tools = append(req.Tools, tool{Name: f.Name, Description: f.Description, InputSchema: schema})
+	}
+
+	// The Messages API has no JSON mode: starting the answer with a brace
+	// makes the model continue with a JSON object
+	if prefill(params) {
+		req.Messages = appendBlock(req.Messages, "assistant", contentBlock{Type: blockText, Text: "{"})
+	}
+
+	if len(req.Messages) == 0 {
+		return messagesRequest{}, fmt.Errorf("%w: no user message", llm.ErrInvalidArguments)
+	}
+	return req, nil
+}
+
+// appendBlock appends a content block to the conversation. The Messages API
+// requires alternating roles, so a block with the role of the last message is
+// added to it. Empty text blocks are not allowed and are skipped.
+func appendBlock(messages []message, role string, block contentBlock) []message {
+	if block.Type == blockText && strings.TrimSpace(block.Text) == "" {
+		return messages
+	}
+	if n := len(messages); n > 0 && messages[n-1].Role == role {
+		messages[n-1].Content = append(messages[n-1].Content, block)
+		return messages
+	}
+	return append(messages, message{Role: role, Content: []contentBlock{block}})
+}
+
+// prefill reports whether the answer is prefilled with an opening brace to get
+// a JSON object. Answers that may use tools are not prefilled.
+func prefill(params llm.CompleteParams) bool {
+	return params.JSONMode && len(params.Functions) == 0
+}
+
+// toMessages converts the content and tool use blocks of a response to
+// messages. Each tool use becomes an assistant message with a function call,
+// the first one carrying the content. The calls are validated against the
+// offered functions.
+func toMessages(content, stopReason string, calls []*llm.FunctionCall, functions []llm.FunctionDefinition) ([]llm.Message, error) {
+	msg := llm.Message{
+		Role:         llm.RoleAssistant,
+		Content:      content,
+		FinishReason: stopReason,
+		Completed:    true,
+	}
+	if len(calls) == 0 {
+		return []llm.Message{msg}, nil
+	}
+
+	msgs := make([]llm.Message, 0, len(calls))
+	for i, call := range calls {
+		if err := llm.ValidateFunctionCall(call, functions); err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			msg.Content = ""
+		}
+		msg.FunctionCall = call
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// apiError converts an error returned by the API to our error types.
+// The status code is 0 for errors sent in a stream.
+func (c *Client) apiError(statusCode int, errType, message, requestID string) error {
+	llmErr := llm.NewLLMError(errors.New(message), string(llm.Anthropic), c.cfg.Model.Name).
+		WithStatusCode(statusCode).
+		WithErrorCode(errType).
+		WithErrorMessage(message).
+		WithRequestID(requestID)
+
+	switch {
+	case errType == ErrTypeOverloaded || statusCode == statusOverloaded:
+		llmErr.Err = llm.ErrModelOverloaded
+		llmErr.Retryable = true
+	case errType == ErrTypeRateLimit || statusCode == http.StatusTooManyRequests:
+		llmErr.Err = llm.ErrRateLimited
+		llmErr.Retryable = true
+	case errType == ErrTypeAuthentication || statusCode == http.StatusUnauthorized:
+		llmErr.Err = llm.ErrInvalidAPIKey
+	case errType == ErrTypePermission || statusCode == http.StatusForbidden:
+		llmErr.Err = llm.ErrAuthenticationFailed
+	case errType == ErrTypeNotFound || statusCode == http.StatusNotFound:
+		llmErr.Err = llm.ErrModelNotFound
+	case errType == ErrTypeRequestTooLarge || statusCode == http.StatusRequestEntityTooLarge:
+		llmErr.Err = llm.ErrContextLengthExceeded
+	case errType == ErrTypeInvalidRequest && strings.Contains(message, "prompt is too long"):
+		llmErr.Err = llm.ErrContextLengthExceeded
+	case errType == ErrTypeInvalidRequest:
+		llmErr.Err = fmt.Errorf("%w: %s", llm.ErrInvalidArguments, message)
+	case errType == ErrTypeAPI || statusCode >= 500:
+		llmErr.Err = llm.ErrServiceUnavailable
+		llmErr.Retryable = true
+	}
+
+	return llmErr
+}
+
+// handleError converts transport errors to our error types.
+func (c *Client) handleError(err error) error {
+	llmErr := llm.NewLLMError(err, string(llm.Anthropic), c.cfg.Model.Name)
+
+	var netErr interface{ Timeout() bool }
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		llmErr.Err = fmt.Errorf("%w: %w", llm.ErrTimeout, err)
+		llmErr.Retryable = true
+	}
+	return llmErr
+}
+
+func init() {
+	llm.RegisterLLM(New, []llm.Model{
+		ModelClaudeSonnet,
+		ModelClaudeHaiku,
+	})
+}
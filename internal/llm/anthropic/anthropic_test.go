@@ -0,0 +1,331 @@
+package anthropic
+
+import (
+	"coda/internal/config"
+	"coda/internal/llm"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestClient returns a client of an httptest stand-in of the Messages API
+// answering with handler, and the requests it received.
+func newTestClient(t *testing.T, handler http.HandlerFunc) (llm.LLM, *[]messagesRequest) {
+	t.Helper()
+
+	var requests []messagesRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" || r.Header.Get("X-API-Key") != "test-key" || r.Header.Get("Anthropic-Version") != APIVersion {
+			t.Errorf("unexpected request %s %s with headers %v", r.Method, r.URL.Path, r.Header)
+		}
+
+		var req messagesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		requests = append(requests, req)
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(llm.Config{
+		Model:      ModelClaudeHaiku,
+		APIKeyFunc: func() string { return "test-key" },
+		LLMConfig:  config.LLM{Anthropic: config.Anthropic{APIKey: "test-key", BaseURL: srv.URL}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return client, &requests
+}
+
+// respond returns a handler writing the body with the status code.
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Request-Id", "req_1")
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	}
+}
+
+// sse returns a handler streaming the events.
+func sse(events ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			var typ struct {
+				Type string `json:"type"`
+			}
+			_ = json.Unmarshal([]byte(e), &typ)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", typ.Type, e)
+		}
+	}
+}
+
+var readFile = llm.FunctionDefinition{
+	Name:        "read_file",
+	Description: "Read a file",
+	Parameters:  map[string]any{"type": "object", "properties": map[string]any{"path": map[string]any{"type": "string"}}},
+}
+
+func TestComplete(t *testing.T) {
+	client, requests := newTestClient(t, respond(http.StatusOK, `{
+		"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-3-5-haiku-latest",
+		"content": [{"type": "text", "text": "Hello"}],
+		"stop_reason": "end_turn",
+		"usage": {"input_tokens": 10, "output_tokens": 2}
+	}`))
+
+	maxTokens := 100
+	res, err := client.Complete(context.Background(), llm.CompleteParams{
+		Messages: []llm.Message{
+			llm.NewSystemMessage("Be brief."),
+			llm.NewUserMessage("Hi"),
+		},
+		MaxTokens: &maxTokens,
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	req := (*requests)[0]
+	if req.System != "Be brief." || req.MaxTokens != 100 || req.Model != ModelClaudeHaiku.Name {
+		t.Errorf("request = %+v", req)
+	}
+	if len(req.Messages) != 1 || req.Messages[0].Role != "user" || req.Messages[0].Content[0].Text != "Hi" {
+		t.Errorf("messages = %+v", req.Messages)
+	}
+
+	if got := res.Messages[0]; got.Content != "Hello" || got.Role != llm.RoleAssistant || got.FinishReason != "end_turn" {
+		t.Errorf("message = %+v", got)
+	}
+	if res.Usage.PromptTokens != 10 || res.Usage.CompletionTokens != 2 || res.Usage.TotalTokens != 12 {
+		t.Errorf("usage = %+v", res.Usage)
+	}
+	if res.Metadata.CompletionID != "msg_1" {
+		t.Errorf("completion ID = %q, want msg_1", res.Metadata.CompletionID)
+	}
+}
+
+func TestCompleteToolUse(t *testing.T) {
+	client, requests := newTestClient(t, respond(http.StatusOK, `{
+		"id": "msg_2", "type": "message", "role": "assistant",
+		"content": [
+			{"type": "text", "text": "Reading both files."},
+			{"type": "tool_use", "id": "toolu_3", "name": "read_file", "input": {"path": "a.go"}},
+			{"type": "tool_use", "id": "toolu_4", "name": "read_file", "input": {"path": "b.go"}}
+		],
+		"stop_reason": "tool_use",
+		"usage": {"input_tokens": 30, "output_tokens": 20}
+	}`))
+
+	call := &llm.FunctionCall{ID: "toolu_1", Name: "read_file", Arguments: `{"path":"main.go"}`}
+	res, err := client.Complete(context.Background(), llm.CompleteParams{
+		Messages: []llm.Message{
+			llm.NewUserMessage("Review main.go"),
+			{Role: llm.RoleAssistant, FunctionCall: call},
+			{Role: llm.RoleAssistant, FunctionCall: &llm.FunctionCall{Name: "read_file", Arguments: `{"path":"go.mod"}`}},
+			llm.NewFunctionResultMessage(call, "package main"),
+			llm.NewFunctionMessage("read_file", "module coda"),
+		},
+		Functions: []llm.FunctionDefinition{readFile},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	// Consecutive tool uses and results are merged into alternating messages
+	want := []message{
+		{Role: "user", Content: []contentBlock{{Type: blockText, Text: "Review main.go"}}},
+		{Role: "assistant", Content: []contentBlock{
+			{Type: blockToolUse, ID: "toolu_1", Name: "read_file", Input: json.RawMessage(`{"path":"main.go"}`)},
+			{Type: blockToolUse, ID: "call_2", Name: "read_file", Input: json.RawMessage(`{"path":"go.mod"}`)},
+		}},
+		{Role: "user", Content: []contentBlock{
+			{Type: blockToolResult, ToolUseID: "toolu_1", Content: "package main"},
+			{Type: blockToolResult, ToolUseID: "call_2", Content: "module coda"},
+		}},
+	}
+	req := (*requests)[0]
+	if !reflect.DeepEqual(req.Messages, want) {
+		t.Errorf("messages = %+v, want %+v", req.Messages, want)
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Name != "read_file" || req.Tools[0].InputSchema == nil {
+		t.Errorf("tools = %+v", req.Tools)
+	}
+
+	calls := res.FunctionCalls()
+	if len(res.Messages) != 2 || len(calls) != 2 {
+		t.Fatalf("got %d messages with %d calls, want 2 and 2", len(res.Messages), len(calls))
+	}
+	if res.Messages[0].Content != "Reading both files." || calls[0].ID != "toolu_3" || calls[1].Arguments != `{"path": "b.go"}` {
+		t.Errorf("messages = %+v", res.Messages)
+	}
+}
+
+func TestCompleteUnknownTool(t *testing.T) {
+	client, _ := newTestClient(t, respond(http.StatusOK, `{
+		"id": "msg_3", "type": "message", "role": "assistant",
+		"content": [{"type": "tool_use", "id": "toolu_1", "name": "delete_file", "input": {}}],
+		"stop_reason": "tool_use"
+	}`))
+
+	_, err := client.Complete(context.Background(), llm.CompleteParams{
+		Messages:  []llm.Message{llm.NewUserMessage("Hi")},
+		Functions: []llm.FunctionDefinition{readFile},
+	})
+	if !errors.Is(err, llm.ErrFunctionNotFound) {
+		t.Errorf("Complete() error = %v, want %v", err, llm.ErrFunctionNotFound)
+	}
+}
+
+func TestCompleteJSONMode(t *testing.T) {
+	client, requests := newTestClient(t, respond(http.StatusOK, `{
+		"id": "msg_4", "type": "message", "role": "assistant",
+		"content": [{"type": "text", "text": "\"summary\": \"ok\"}"}],
+		"stop_reason": "end_turn"
+	}`))
+
+	res, err := client.Complete(context.Background(), llm.CompleteParams{
+		Messages: []llm.Message{llm.NewUserMessage("Answer in JSON")},
+		JSONMode: true,
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	msgs := (*requests)[0].Messages
+	if last := msgs[len(msgs)-1]; last.Role != "assistant" || last.Content[0].Text != "{" {
+		t.Errorf("last message = %+v, want the prefilled brace", last)
+	}
+	if got := res.Messages[0].Content; got != `{"summary": "ok"}` {
+		t.Errorf("content = %q", got)
+	}
+}
+
+func TestStream(t *testing.T) {
+	client, requests := newTestClient(t, sse(
+		`{"type": "message_start", "message": {"id": "msg_5", "usage": {"input_tokens": 12, "output_tokens": 1}}}`,
+		`{"type": "content_block_start", "index": 0, "content_block": {"type": "text", "text": ""}}`,
+		`{"type": "ping"}`,
+		`{"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": "Let me "}}`,
+		`{"type": "content_block_delta", "index": 0, "delta": {"type": "text_delta", "text": "check."}}`,
+		`{"type": "content_block_stop", "index": 0}`,
+		`{"type": "content_block_start", "index": 1, "content_block": {"type": "tool_use", "id": "toolu_1", "name": "read_file", "input": {}}}`,
+		`{"type": "content_block_delta", "index": 1, "delta": {"type": "input_json_delta", "partial_json": "{\"path\": "}}`,
+		`{"type": "content_block_delta", "index": 1, "delta": {"type": "input_json_delta", "partial_json": "\"main.go\"}"}}`,
+		`{"type": "content_block_stop", "index": 1}`,
+		`{"type": "message_delta", "delta": {"stop_reason": "tool_use"}, "usage": {"output_tokens": 25}}`,
+		`{"type": "message_stop"}`,
+	))
+
+	var chunks []llm.StreamChunk
+	res, err := client.Stream(context.Background(), llm.CompleteParams{
+		Messages:  []llm.Message{llm.NewUserMessage("Review main.go")},
+		Functions: []llm.FunctionDefinition{readFile},
+	}, func(chunk llm.StreamChunk) error {
+		chunks = append(chunks, chunk)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+
+	if !(*requests)[0].Stream {
+		t.Error("request is not streamed")
+	}
+	want := []llm.StreamChunk{{Delta: "Let me "}, {Delta: "check."}, {FinishReason: "tool_use"}}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Errorf("chunks = %+v, want %+v", chunks, want)
+	}
+
+	msg := res.Messages[0]
+	if msg.Content != "Let me check." || msg.FunctionCall == nil || msg.FunctionCall.ID != "toolu_1" || msg.FunctionCall.Arguments != `{"path": "main.go"}` {
+		t.Errorf("message = %+v, call = %+v", msg, msg.FunctionCall)
+	}
+	if res.Usage.PromptTokens != 12 || res.Usage.CompletionTokens != 25 {
+		t.Errorf("usage = %+v", res.Usage)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		handler       http.HandlerFunc
+		stream        bool
+		wantErr       error
+		wantRetryable bool
+	}{
+		{
+			name:          "Overloaded",
+			handler:       respond(529, `{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`),
+			wantErr:       llm.ErrModelOverloaded,
+			wantRetryable: true,
+		},
+		{
+			name:          "RateLimited",
+			handler:       respond(http.StatusTooManyRequests, `{"type": "error", "error": {"type": "rate_limit_error", "message": "Rate limited"}}`),
+			wantErr:       llm.ErrRateLimited,
+			wantRetryable: true,
+		},
+		{
+			name:    "InvalidAPIKey",
+			handler: respond(http.StatusUnauthorized, `{"type": "error", "error": {"type": "authentication_error", "message": "invalid x-api-key"}}`),
+			wantErr: llm.ErrInvalidAPIKey,
+		},
+		{
+			name:    "PromptTooLong",
+			handler: respond(http.StatusBadRequest, `{"type": "error", "error": {"type": "invalid_request_error", "message": "prompt is too long: 210000 tokens > 200000 maximum"}}`),
+			wantErr: llm.ErrContextLengthExceeded,
+		},
+		{
+			name:          "ServerError",
+			handler:       respond(http.StatusBadGateway, `upstream error`),
+			wantErr:       llm.ErrServiceUnavailable,
+			wantRetryable: true,
+		},
+		{
+			name:          "StreamError",
+			handler:       sse(`{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`),
+			stream:        true,
+			wantErr:       llm.ErrModelOverloaded,
+			wantRetryable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newTestClient(t, tt.handler)
+			params := llm.CompleteParams{Messages: []llm.Message{llm.NewUserMessage("Hi")}}
+
+			var err error
+			if tt.stream {
+				_, err = client.Stream(context.Background(), params, func(llm.StreamChunk) error { return nil })
+			} else {
+				_, err = client.Complete(context.Background(), params)
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			var llmErr *llm.LLMError
+			if !errors.As(err, &llmErr) || llmErr.Provider != string(llm.Anthropic) {
+				t.Fatalf("error = %#v, want an Anthropic LLMError", err)
+			}
+			if llm.IsRetryable(err) != tt.wantRetryable {
+				t.Errorf("IsRetryable() = %v, want %v", llm.IsRetryable(err), tt.wantRetryable)
+			}
+			if !tt.stream && !strings.HasPrefix(llmErr.RequestID, "req_") {
+				t.Errorf("request ID = %q, want req_1", llmErr.RequestID)
+			}
+		})
+	}
+}
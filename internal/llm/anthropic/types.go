@@ -0,0 +1,108 @@
+package anthropic
+
+import "encoding/json"
+
+// Wire types of the Messages API
+// https://docs.anthropic.com/en/api/messages
+
+// messagesRequest is the body of a Messages API request.
+type messagesRequest struct {
+	Model       string    `json:"model"`
+	MaxTokens   int       `json:"max_tokens"`
+	System      string    `json:"system,omitempty"`
+	Messages    []message `json:"messages"`
+	Temperature *float32  `json:"temperature,omitempty"`
+	TopP        *float32  `json:"top_p,omitempty"`
+	Tools       []tool    `json:"tools,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+// message is a user or assistant turn of the conversation.
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+// Content block types
+const (
+	blockText       = "text"
+	blockToolUse    = "tool_use"
+	blockToolResult = "tool_result"
+)
+
+// contentBlock is a block of the content of a message. The fields set depend on its type.
+type contentBlock struct {
+	Type string `json:"type"`
+
+	// Text blocks, and the content of tool results
+	Text string `json:"text,omitempty"`
+
+	// Tool use blocks
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// Tool result blocks
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+}
+
+// tool is a tool the model can use.
+type tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
+}
+
+// messagesResponse is the response of a Messages API request.
+type messagesResponse struct {
+	ID         string         `json:"id"`
+	Type       string         `json:"type"`
+	Role       string         `json:"role"`
+	Content    []contentBlock `json:"content"`
+	Model      string         `json:"model"`
+	StopReason string         `json:"stop_reason"`
+	Usage      usage          `json:"usage"`
+}
+
+// usage is the token usage of a request.
+type usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// errorResponse is the body of an error response, also sent as a stream event.
+type errorResponse struct {
+	Type  string `json:"type"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// streamEvent is an event of a streamed response. The fields set depend on its type.
+type streamEvent struct {
+	Type string `json:"type"`
+
+	// message_start
+	Message *messagesResponse `json:"message,omitempty"`
+
+	// content_block_start, content_block_delta and content_block_stop
+	Index        int           `json:"index"`
+	ContentBlock *contentBlock `json:"content_block,omitempty"`
+
+	// content_block_delta and message_delta
+	Delta *struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		StopReason  string `json:"stop_reason"`
+	} `json:"delta,omitempty"`
+	Usage *usage `json:"usage,omitempty"`
+
+	// error
+	Error *struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
@@ -0,0 +1,304 @@
+package llm
+
+import (
+	"coda/internal/config"
+	"coda/internal/logger"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Budget actions
+const (
+	BudgetReject    = "reject"    // Requests over a limit fail with ErrBudgetExceeded
+	BudgetDowngrade = "downgrade" // Requests over a limit are completed by the fallback model
+)
+
+// globalLedger is the ledger of the spending of the whole application.
+const globalLedger = "global"
+
+// maxBudgetCallers is the number of callers whose spending is tracked at once.
+// Beyond it, the least recently active caller is forgotten.
+const maxBudgetCallers = 10000
+
+// Budget tracks the cost and tokens spent by each caller and by the whole
+// application over rolling windows, and reports the limits they reach.
+// The spending of the completions in progress is estimated and reserved when
+// they are admitted, then settled against their actual usage.
+// A nil Budget allows everything.
+type Budget struct {
+	limits        []config.BudgetLimit
+	action        string
+	fallbackModel string
+	retention     time.Duration // Longest window, older spending is forgotten
+	maxCallers    int
+	now           func() time.Time
+
+	mu              sync.Mutex
+	ledgers         map[string][]spending // By caller, and globalLedger
+	lastSweep       time.Time
+	nextReservation uint64
+}
+
+// spending is the usage of a completion, or the estimated usage reserved for
+// a completion in progress.
+type spending struct {
+	at          time.Time
+	cost        float64
+	tokens      int
+	reservation uint64 // ID of the reservation, 0 for actual usage
+}
+
+// NewBudget creates the budget of the configured limits, or returns nil when
+// no limit is configured.
+func NewBudget(cfg *config.Config) *Budget {
+	if !cfg.Budget.IsConfigured() {
+		return nil
+	}
+
+	b := &Budget{
+		limits:        cfg.Budget.Limits,
+		action:        cfg.Budget.Action,
+		fallbackModel: cfg.Budget.FallbackModel,
+		maxCallers:    maxBudgetCallers,
+		now:           time.Now,
+		ledgers:       make(map[string][]spending),
+	}
+	if b.action == "" {
+		b.action = BudgetReject
+	}
+	for _, l := range b.limits {
+		b.retention = max(b.retention, l.Window)
+	}
+	return b
+}
+
+// reserve admits a completion of the caller and reserves its estimated
+// spending until it is settled. It returns the ID of the reservation, or the
+// first limit reached by the caller or the whole application, counting the
+// reservations of the completions in progress. Callers without identity are
+// only subject to the global limits.
+func (b *Budget) reserve(caller string, estimate spending) (uint64, *config.BudgetLimit) {
+	if b == nil {
+		return 0, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	for i, l := range b.limits {
+		ledger := globalLedger
+		if l.Scope == "caller" {
+			if caller == "" {
+				continue
+			}
+			ledger = "caller:" + caller
+		}
+
+		var cost float64
+		var tokens int
+		for _, s := range b.ledgers[ledger] {
+			if now.Sub(s.at) < l.Window {
+				cost += s.cost
+				tokens += s.tokens
+			}
+		}
+		if (l.MaxCost > 0 && cost >= l.MaxCost) || (l.MaxTokens > 0 && tokens >= l.MaxTokens) {
+			return 0, &b.limits[i]
+		}
+	}
+
+	b.nextReservation++
+	estimate.at, estimate.reservation = now, b.nextReservation
+	b.add(caller, estimate)
+	return estimate.reservation, nil
+}
+
+// settle replaces the reservation of a completion of the caller with its
+// actual usage. A nil response releases the reservation of a failed
+// completion; a zero reservation only records the usage.
+func (b *Budget) settle(caller string, reservation uint64, res *CompleteResponse) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if reservation != 0 {
+		b.release(globalLedger, reservation)
+		if caller != "" {
+			b.release("caller:"+caller, reservation)
+		}
+	}
+	if res == nil || res.Usage == nil {
+		return
+	}
+
+	s := spending{at: b.now(), tokens: res.Usage.TotalTokens}
+	if s.tokens == 0 {
+		s.tokens = res.Usage.PromptTokens + res.Usage.CompletionTokens
+	}
+	if res.Metadata.Cost != nil {
+		s.cost = res.Metadata.Cost.Total
+	}
+	b.add(caller, s)
+}
+
+// add adds the spending to the ledgers of the caller and of the whole
+// application. b.mu must be held.
+func (b *Budget) add(caller string, s spending) {
+	b.ledgers[globalLedger] = append(b.ledgers[globalLedger], s)
+	if caller != "" {
+		key := "caller:" + caller
+		if _, ok := b.ledgers[key]; !ok {
+			b.makeRoom(s.at)
+		}
+		b.ledgers[key] = append(b.ledgers[key], s)
+	}
+
+	// Forget the spending outside of every window, at most once per window
+	if s.at.Sub(b.lastSweep) >= b.retention {
+		b.sweep(s.at)
+	}
+}
+
+// release removes a reservation from a ledger. b.mu must be held.
+func (b *Budget) release(key string, reservation uint64) {
+	ledger := b.ledgers[key]
+	for i, s := range ledger {
+		if s.reservation == reservation {
+			ledger = append(ledger[:i], ledger[i+1:]...)
+			break
+		}
+	}
+	if len(ledger) == 0 {
+		delete(b.ledgers, key)
+		return
+	}
+	b.ledgers[key] = ledger
+}
+
+// sweep removes the spending older than the longest window, and the ledgers
+// left empty. b.mu must be held.
+func (b *Budget) sweep(now time.Time) {
+	b.lastSweep = now
+	for key, ledger := range b.ledgers {
+		i := 0
+		for i < len(ledger) && now.Sub(ledger[i].at) >= b.retention {
+			i++
+		}
+		if i == len(ledger) {
+			delete(b.ledgers, key)
+			continue
+		}
+		b.ledgers[key] = ledger[i:]
+	}
+}
+
+// makeRoom forgets the least recently active caller when maxCallers callers
+// are tracked already, so that callers cannot exhaust the memory by changing
+// their identity. b.mu must be held, and the global ledger must exist.
+func (b *Budget) makeRoom(now time.Time) {
+	if len(b.ledgers)-1 < b.maxCallers {
+		return
+	}
+	b.sweep(now)
+	if len(b.ledgers)-1 < b.maxCallers {
+		return
+	}
+
+	var oldest string
+	var oldestAt time.Time
+	for key, ledger := range b.ledgers {
+		if key == globalLedger {
+			continue
+		}
+		if at := ledger[len(ledger)-1].at; oldest == "" || at.Before(oldestAt) {
+			oldest, oldestAt = key, at
+		}
+	}
+	delete(b.ledgers, oldest)
+}
+
+// applyBudget admits a request and returns the model completing it, with the
+// reservation of its estimated spending to settle once completed: the
+// requested model while within budget, the fallback model of the budget when
+// downgrading a request over a limit, or ErrBudgetExceeded.
+func (c *completer) applyBudget(ctx context.Context, params CompleteParams, model Model) (Model, uint64, error) {
+	reservation, limit := c.budget.reserve(CallerFromContext(ctx), estimateSpending(params, model))
+	if limit == nil {
+		return model, reservation, nil
+	}
+
+	if c.budget.action == BudgetDowngrade {
+		fallback, ok := c.budgetFallback(params)
+		if ok && fallback.Provider == model.Provider && fallback.Name == model.Name {
+			return model, 0, nil
+		}
+		if ok {
+			logger.Info(ctx, "budget exceeded, downgrading model",
+				"model", model.Name,
+				"fallback_model", fallback.Name,
+				"scope", limit.Scope,
+				"window", limit.Window)
+			c.metrics.observeBudgetExceeded(model, limit.Scope, BudgetDowngrade)
+			return fallback, 0, nil
+		}
+	}
+
+	c.metrics.observeBudgetExceeded(model, limit.Scope, BudgetReject)
+	return Model{}, 0, fmt.Errorf("%w: %s limit over %s reached", ErrBudgetExceeded, limit.Scope, limit.Window)
+}
+
+// estimateSpending returns the spending reserved for a request before it is
+// completed: its estimated prompt and its longest allowed completion.
+func estimateSpending(params CompleteParams, model Model) spending {
+	usage := &Usage{PromptTokens: EstimateMessageTokens(params.Messages), CompletionTokens: model.MaxToken}
+	if params.MaxTokens != nil {
+		usage.CompletionTokens = *params.MaxTokens
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+
+	s := spending{tokens: usage.TotalTokens}
+	if cost := model.Pricing.Cost(usage); cost != nil {
+		s.cost = cost.Total
+	}
+	return s
+}
+
+// budgetFallback returns the model completing the requests over budget: the
+// configured fallback model, or the first available Ollama model. The model
+// must support the function calls of the request.
+func (c *completer) budgetFallback(params CompleteParams) (Model, bool) {
+	for _, m := range c.GetAvailableModels() {
+		if c.budget.fallbackModel != "" && m.Name != c.budget.fallbackModel {
+			continue
+		}
+		if c.budget.fallbackModel == "" && m.Provider != Ollama {
+			continue
+		}
+		if len(params.Functions) > 0 && !m.Capabilities.SupportsFunctions {
+			continue
+		}
+		return m, true
+	}
+	return Model{}, false
+}
+
+// callerKey is the context key of the caller of the completions.
+type callerKey struct{}
+
+// WithCaller returns a context whose completions are counted in the budget of
+// the given caller, e.g. an API key or an IP address.
+func WithCaller(ctx context.Context, caller string) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+// CallerFromContext returns the caller set by WithCaller, or "" when unknown.
+func CallerFromContext(ctx context.Context) string {
+	caller, _ := ctx.Value(callerKey{}).(string)
+	return caller
+}
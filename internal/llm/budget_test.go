@@ -0,0 +1,177 @@
+package llm
+
+import (
+	"coda/internal/config"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBudgetExceeded(t *testing.T) {
+	callerLimit := config.BudgetLimit{Scope: "caller", Window: time.Hour, MaxCost: 1}
+	globalLimit := config.BudgetLimit{Scope: "global", Window: 24 * time.Hour, MaxTokens: 100}
+	start := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	type spent struct {
+		caller string
+		cost   float64
+		tokens int
+	}
+	tests := []struct {
+		name    string
+		spent   []spent
+		caller  string
+		elapsed time.Duration
+		want    *config.BudgetLimit
+	}{
+		{name: "within budget", spent: []spent{{"a", 0.5, 10}}, caller: "a"},
+		{name: "caller cost", spent: []spent{{"a", 0.6, 10}, {"a", 0.4, 10}}, caller: "a", want: &callerLimit},
+		{name: "other caller", spent: []spent{{"a", 1, 10}}, caller: "b"},
+		{name: "global tokens", spent: []spent{{"a", 0, 60}, {"b", 0, 40}}, caller: "c", want: &globalLimit},
+		{name: "window elapsed", spent: []spent{{"a", 1, 10}}, caller: "a", elapsed: time.Hour},
+		{name: "anonymous caller", spent: []spent{{"a", 1, 10}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBudget(&config.Config{Budget: config.Budget{
+				Limits: []config.BudgetLimit{callerLimit, globalLimit},
+			}})
+			now := start
+			b.now = func() time.Time { return now }
+
+			for _, s := range tt.spent {
+				b.settle(s.caller, 0, &CompleteResponse{
+					Usage:    &Usage{TotalTokens: s.tokens},
+					Metadata: CompletionMetadata{Cost: &Cost{Total: s.cost, Currency: "USD"}},
+				})
+			}
+			now = now.Add(tt.elapsed)
+
+			_, got := b.reserve(tt.caller, spending{})
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("reserve() limit = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBudgetMaxCallers(t *testing.T) {
+	b := NewBudget(&config.Config{Budget: config.Budget{
+		Limits: []config.BudgetLimit{{Scope: "caller", Window: time.Hour, MaxTokens: 10}},
+	}})
+	b.maxCallers = 2
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	b.now = func() time.Time { return now }
+
+	for _, caller := range []string{"a", "b", "a", "c"} {
+		b.settle(caller, 0, &CompleteResponse{Usage: &Usage{TotalTokens: 10}})
+		now = now.Add(time.Minute)
+	}
+
+	if got := len(b.ledgers) - 1; got != 2 {
+		t.Errorf("tracked callers = %d, want 2", got)
+	}
+	if _, ok := b.ledgers["caller:b"]; ok {
+		t.Error("caller b tracked, want the least recently active caller forgotten")
+	}
+}
+
+func TestBudgetReservations(t *testing.T) {
+	b := NewBudget(&config.Config{Budget: config.Budget{
+		Limits: []config.BudgetLimit{{Scope: "caller", Window: time.Hour, MaxTokens: 100}},
+	}})
+
+	// Concurrent completions are counted as soon as they are admitted
+	first, limit := b.reserve("a", spending{tokens: 60})
+	if limit != nil {
+		t.Fatalf("reserve() limit = %+v, want nil", limit)
+	}
+	second, limit := b.reserve("a", spending{tokens: 60})
+	if limit != nil {
+		t.Fatalf("reserve() limit = %+v, want nil", limit)
+	}
+	if _, limit := b.reserve("a", spending{tokens: 60}); limit == nil {
+		t.Fatal("reserve() limit = nil, want the reservations counted")
+	}
+
+	// Settling replaces the estimates with the actual usage
+	b.settle("a", first, &CompleteResponse{Usage: &Usage{TotalTokens: 10}})
+	b.settle("a", second, nil)
+	reservation, limit := b.reserve("a", spending{tokens: 60})
+	if limit != nil {
+		t.Fatalf("reserve() limit = %+v, want nil after settling", limit)
+	}
+	b.settle("a", reservation, nil)
+
+	var tokens int
+	for _, s := range b.ledgers["caller:a"] {
+		tokens += s.tokens
+	}
+	if tokens != 10 {
+		t.Errorf("spent tokens = %d, want 10", tokens)
+	}
+}
+
+func TestCompleterBudget(t *testing.T) {
+	// The Ollama client is not linked in the tests of this package
+	var called string
+	RegisterProvider(Ollama, func(cfg Config) (LLM, error) {
+		called = cfg.Model.Name
+		return &flakyLLM{}, nil
+	})
+
+	cfg := &config.Config{LLM: config.LLM{
+		Ollama: config.Ollama{BaseURL: "http://localhost:11434"},
+		Models: []config.Model{{Provider: string(Ollama), Name: "llama3"}},
+	}}
+	registry, err := NewRegistry(cfg)
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+	priced := Model{Name: "gpt-4o", Provider: OpenAI}
+	params := CompleteParams{Messages: []Message{NewUserMessage("Hi")}}
+
+	tests := []struct {
+		name      string
+		action    string
+		params    CompleteParams
+		wantModel string
+		wantErr   error
+	}{
+		{name: "reject", action: BudgetReject, params: params, wantErr: ErrBudgetExceeded},
+		{name: "downgrade", action: BudgetDowngrade, params: params, wantModel: "llama3"},
+		{
+			name:   "no fallback for function calls",
+			action: BudgetDowngrade,
+			params: CompleteParams{
+				Messages:  params.Messages,
+				Functions: []FunctionDefinition{{Name: "read_file", Parameters: map[string]any{"type": "object"}}},
+			},
+			wantErr: ErrBudgetExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			called = ""
+			cfg.Budget = config.Budget{
+				Limits: []config.BudgetLimit{{Scope: "caller", Window: time.Hour, MaxTokens: 10}},
+				Action: tt.action,
+			}
+			budget := NewBudget(cfg)
+			budget.settle("ip:192.0.2.1", 0, &CompleteResponse{Usage: &Usage{TotalTokens: 10}})
+
+			c := NewCompleter(cfg, registry, WithCompleterBudget(budget))
+			ctx := WithCaller(context.Background(), "ip:192.0.2.1")
+			_, err := c.Complete(ctx, tt.params, priced)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Complete() error = %v, want %v", err, tt.wantErr)
+			}
+			if called != tt.wantModel {
+				t.Errorf("completed by %q, want %q", called, tt.wantModel)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// completionsBucket holds the values keyed by cache key, each prefixed with
+// its expiry in Unix nanoseconds.
+var completionsBucket = []byte("completions")
+
+// Bolt is a Store backed by an embedded bbolt database file, so that cached
+// values survive restarts. Expired values are removed when the store is
+// opened and when they are read.
+type Bolt struct {
+	db  *bolt.DB
+	now func() time.Time
+}
+
+var _ Store = (*Bolt)(nil)
+
+// NewBolt opens (or creates) the cache database at the given path and removes
+// its expired values.
+func NewBolt(path string) (*Bolt, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+
+	// Fail instead of blocking forever when another process holds the file lock
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening cache database: %w", err)
+	}
+
+	b := &Bolt{db: db, now: time.Now}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(completionsBucket)
+		if err != nil {
+			return err
+		}
+		return b.removeExpired(bucket)
+	}); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("preparing cache database: %w", err)
+	}
+
+	return b, nil
+}
+
+// Get returns the value stored under the key, or false when it is missing or expired.
+func (b *Bolt) Get(key string) ([]byte, bool, error) {
+	var (
+		value   []byte
+		expired bool
+	)
+	if err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(completionsBucket).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		if b.expired(data) {
+			expired = true
+			return nil
+		}
+		// The data is only valid during the transaction
+		value = append([]byte(nil), data[8:]...)
+		return nil
+	}); err != nil {
+		return nil, false, fmt.Errorf("reading cache: %w", err)
+	}
+
+	if expired {
+		if err := b.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(completionsBucket).Delete([]byte(key))
+		}); err != nil {
+			return nil, false, fmt.Errorf("removing expired cache value: %w", err)
+		}
+	}
+	return value, value != nil, nil
+}
+
+// Set stores the value under the key for the given TTL.
+func (b *Bolt) Set(key string, value []byte, ttl time.Duration) error {
+	data := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(data, uint64(b.now().Add(ttl).UnixNano()))
+	data = append(data, value...)
+
+	if err := b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(completionsBucket).Put([]byte(key), data)
+	}); err != nil {
+		return fmt.Errorf("writing cache: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database.
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
+
+// expired reports whether the stored data has expired.
+func (b *Bolt) expired(data []byte) bool {
+	if len(data) < 8 {
+		return true
+	}
+	expires := time.Unix(0, int64(binary.BigEndian.Uint64(data)))
+	return !b.now().Before(expires)
+}
+
+// removeExpired removes the expired values of the bucket.
+func (b *Bolt) removeExpired(bucket *bolt.Bucket) error {
+	var keys [][]byte
+	if err := bucket.ForEach(func(k, v []byte) error {
+		if b.expired(v) {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := bucket.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBolt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache", "completions.db")
+	b, err := NewBolt(path)
+	if err != nil {
+		t.Fatalf("NewBolt() error = %v", err)
+	}
+	if err := b.Set("kept", []byte("review"), time.Hour); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := b.Set("expired", []byte("review"), -time.Second); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// Values survive reopening the database, until they expire
+	b, err = NewBolt(path)
+	if err != nil {
+		t.Fatalf("NewBolt() error = %v", err)
+	}
+	defer b.Close()
+
+	tests := []struct {
+		key       string
+		wantValue string
+		wantOK    bool
+	}{
+		{key: "kept", wantValue: "review", wantOK: true},
+		{key: "expired"},
+		{key: "missing"},
+	}
+	for _, tt := range tests {
+		value, ok, err := b.Get(tt.key)
+		if err != nil {
+			t.Fatalf("Get(%q) error = %v", tt.key, err)
+		}
+		if ok != tt.wantOK || string(value) != tt.wantValue {
+			t.Errorf("Get(%q) = %q, %v, want %q, %v", tt.key, value, ok, tt.wantValue, tt.wantOK)
+		}
+	}
+}
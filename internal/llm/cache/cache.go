@@ -0,0 +1,76 @@
+// Package cache provides the stores of the completion cache: an in-memory
+// LRU, an on-disk bbolt database, and a tiered store combining them.
+// Stores map keys to opaque values that expire after their TTL.
+package cache
+
+import "time"
+
+// Store keeps values under keys until they expire.
+type Store interface {
+	// Get returns the value stored under the key, or false when it is missing
+	// or expired.
+	Get(key string) ([]byte, bool, error)
+	// Set stores the value under the key for the given TTL.
+	Set(key string, value []byte, ttl time.Duration) error
+	// Close releases the resources of the store.
+	Close() error
+}
+
+// Tiered is a Store checking its stores in order, typically a fast in-memory
+// store in front of a persistent one. Values found in a later store are
+// copied to the earlier ones, and values are set in every store.
+type Tiered struct {
+	stores []Store
+}
+
+var _ Store = (*Tiered)(nil)
+
+// NewTiered creates a store checking the given stores in order.
+func NewTiered(stores ...Store) *Tiered {
+	return &Tiered{stores: stores}
+}
+
+// Get returns the value from the first store holding it.
+func (t *Tiered) Get(key string) ([]byte, bool, error) {
+	for i, s := range t.stores {
+		value, ok, err := s.Get(key)
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			continue
+		}
+
+		// The expiry is unknown, the copies live for the TTL of the fastest store
+		for _, earlier := range t.stores[:i] {
+			_ = earlier.Set(key, value, promotedTTL)
+		}
+		return value, true, nil
+	}
+	return nil, false, nil
+}
+
+// promotedTTL is the TTL of the values copied to an earlier store, short so
+// that they do not outlive the original much.
+const promotedTTL = 5 * time.Minute
+
+// Set stores the value in every store.
+func (t *Tiered) Set(key string, value []byte, ttl time.Duration) error {
+	for _, s := range t.stores {
+		if err := s.Set(key, value, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes every store.
+func (t *Tiered) Close() error {
+	var firstErr error
+	for _, s := range t.stores {
+		if err := s.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
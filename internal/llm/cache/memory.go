@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Memory is an in-memory Store evicting the least recently used values once
+// it holds its maximum number of values.
+type Memory struct {
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // Most recently used first
+}
+
+var _ Store = (*Memory)(nil)
+
+// memoryEntry is a value of the memory store.
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemory creates a memory store holding up to size values.
+func NewMemory(size int) *Memory {
+	return &Memory{
+		size:    size,
+		now:     time.Now,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Get returns the value stored under the key, or false when it is missing or expired.
+func (m *Memory) Get(key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*memoryEntry)
+	if !m.now().Before(entry.expires) {
+		m.remove(el)
+		return nil, false, nil
+	}
+
+	m.lru.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+// Set stores the value under the key for the given TTL, evicting the least
+// recently used value when the store is full.
+func (m *Memory) Set(key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expires := m.now().Add(ttl)
+	if el, ok := m.entries[key]; ok {
+		entry := el.Value.(*memoryEntry)
+		entry.value, entry.expires = value, expires
+		m.lru.MoveToFront(el)
+		return nil
+	}
+
+	m.entries[key] = m.lru.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+	for m.lru.Len() > m.size {
+		m.remove(m.lru.Back())
+	}
+	return nil
+}
+
+// Len returns the number of values in the store, expired ones included.
+func (m *Memory) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.lru.Len()
+}
+
+// Close does nothing, the memory store holds no resources.
+func (m *Memory) Close() error {
+	return nil
+}
+
+// remove removes an entry. m.mu must be held.
+func (m *Memory) remove(el *list.Element) {
+	m.lru.Remove(el)
+	delete(m.entries, el.Value.(*memoryEntry).key)
+}
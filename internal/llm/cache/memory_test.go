@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemory(t *testing.T) {
+	start := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		ops     []string // Keys read in order, or set with a one hour TTL when prefixed with +
+		elapsed time.Duration
+		want    map[string]bool
+	}{
+		{name: "hit", ops: []string{"+a"}, want: map[string]bool{"a": true, "b": false}},
+		{name: "least recently used evicted", ops: []string{"+a", "+b", "+c"}, want: map[string]bool{"a": false, "b": true, "c": true}},
+		{name: "read keeps a value", ops: []string{"+a", "+b", "a", "+c"}, want: map[string]bool{"a": true, "b": false}},
+		{name: "expired", ops: []string{"+a"}, elapsed: time.Hour, want: map[string]bool{"a": false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMemory(2)
+			now := start
+			m.now = func() time.Time { return now }
+
+			for _, op := range tt.ops {
+				if key, ok := strings.CutPrefix(op, "+"); ok {
+					if err := m.Set(key, []byte(key), time.Hour); err != nil {
+						t.Fatalf("Set() error = %v", err)
+					}
+					continue
+				}
+				if _, _, err := m.Get(op); err != nil {
+					t.Fatalf("Get() error = %v", err)
+				}
+			}
+			now = now.Add(tt.elapsed)
+
+			for key, want := range tt.want {
+				value, ok, err := m.Get(key)
+				if err != nil {
+					t.Fatalf("Get(%q) error = %v", key, err)
+				}
+				if ok != want || (ok && string(value) != key) {
+					t.Errorf("Get(%q) = %q, %v, want found = %v", key, value, ok, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package llm
+
+import (
+	"coda/internal/llm/cache"
+	"coda/internal/logger"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// Cache defaults
+const (
+	DefaultCacheSize = 1000
+	DefaultCacheTTL  = 24 * time.Hour
+)
+
+// cachingCompleter answers the completion requests identical to a previous
+// one from a cache. Completions with fallback models are not cached.
+type cachingCompleter struct {
+	Completer
+	store cache.Store
+	ttl   time.Duration
+}
+
+var _ Completer = (*cachingCompleter)(nil)
+
+// NewCachingCompleter wraps next so that the completions of identical requests
+// are answered from the store until their TTL elapses. Cached completions are
+// flagged with CacheHit and have no cost.
+func NewCachingCompleter(next Completer, store cache.Store, ttl time.Duration) Completer {
+	return &cachingCompleter{Completer: next, store: store, ttl: ttl}
+}
+
+// Complete returns the cached completion of the request, or completes it with
+// the next completer and caches it.
+func (c *cachingCompleter) Complete(
+	ctx context.Context,
+	params CompleteParams,
+	model Model,
+) (*CompleteResponse, error) {
+	key := cacheKey(params, model)
+	if res := c.lookup(ctx, key); res != nil {
+		return res, nil
+	}
+
+	res, err := c.Completer.Complete(ctx, params, model)
+	if err != nil {
+		return nil, err
+	}
+	c.save(ctx, key, model, res)
+	return res, nil
+}
+
+// Stream delivers the cached completion of the request as a single chunk, or
+// streams it from the next completer and caches it.
+func (c *cachingCompleter) Stream(
+	ctx context.Context,
+	params CompleteParams,
+	model Model,
+	fn StreamFunc,
+) (*CompleteResponse, error) {
+	key := cacheKey(params, model)
+	if res := c.lookup(ctx, key); res != nil {
+		if err := fn(StreamChunk{
+			Delta:        res.Messages[0].Content,
+			FinishReason: res.Messages[0].FinishReason,
+		}); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+
+	res, err := c.Completer.Stream(ctx, params, model, fn)
+	if err != nil {
+		return nil, err
+	}
+	c.save(ctx, key, model, res)
+	return res, nil
+}
+
+// lookup returns the cached completion of the key, or nil when there is none
+// or the cache is bypassed. Cache failures are logged and treated as misses.
+func (c *cachingCompleter) lookup(ctx context.Context, key string) *CompleteResponse {
+	if key == "" || cacheBypassed(ctx) {
+		return nil
+	}
+
+	data, ok, err := c.store.Get(key)
+	if err != nil {
+		logger.Warn(ctx, "failed to read completion cache", "err", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+
+	var res CompleteResponse
+	if err := json.Unmarshal(data, &res); err != nil || len(res.Messages) == 0 {
+		logger.Warn(ctx, "ignoring invalid cached completion", "err", err)
+		return nil
+	}
+	res.Metadata.CacheHit = true
+	res.Metadata.Cost = nil
+	logger.Debug(ctx, "completion answered from the cache", "model", res.Metadata.ModelName)
+	return &res
+}
+
+// save caches the completion of the key. Completions by another model than
+// the requested one, such as those downgraded by the budget, are not cached.
+func (c *cachingCompleter) save(ctx context.Context, key string, model Model, res *CompleteResponse) {
+	if key == "" || (res.Metadata.ModelName != "" && res.Metadata.ModelName != model.Name) {
+		return
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		logger.Warn(ctx, "failed to encode completion for the cache", "err", err)
+		return
+	}
+	if err := c.store.Set(key, data, c.ttl); err != nil {
+		logger.Warn(ctx, "failed to write completion cache", "err", err)
+	}
+}
+
+// cacheKeyMessage is the part of a message identifying a request. IDs and
+// timestamps differ between identical requests.
+type cacheKeyMessage struct {
+	Role         Role          `json:"role"`
+	Content      string        `json:"content"`
+	Name         string        `json:"name,omitempty"`
+	FunctionCall *FunctionCall `json:"function_call,omitempty"`
+	CallID       string        `json:"call_id,omitempty"`
+}
+
+// cacheKey returns the hash identifying the completion of the request by the
+// model, or "" when the request cannot be hashed. Streaming does not change
+// the completion, so streamed and non-streamed requests share their key.
+func cacheKey(params CompleteParams, model Model) string {
+	messages := make([]cacheKeyMessage, len(params.Messages))
+	for i, m := range params.Messages {
+		messages[i] = cacheKeyMessage{
+			Role:         m.Role,
+			Content:      m.Content,
+			Name:         m.Name,
+			FunctionCall: m.FunctionCall,
+			CallID:       m.CallID,
+		}
+	}
+
+	data, err := json.Marshal(struct {
+		Provider       Provider             `json:"provider"`
+		Endpoint       string               `json:"endpoint,omitempty"`
+		Model          string               `json:"model"`
+		Messages       []cacheKeyMessage    `json:"messages"`
+		MaxTokens      *int                 `json:"max_tokens,omitempty"`
+		Temperature    *float32             `json:"temperature,omitempty"`
+		TopP           *float32             `json:"top_p,omitempty"`
+		N              *int                 `json:"n,omitempty"`
+		Functions      []FunctionDefinition `json:"functions,omitempty"`
+		JSONMode       bool                 `json:"json_mode,omitempty"`
+		ResponseSchema any                  `json:"response_schema,omitempty"`
+	}{
+		Provider:       model.Provider,
+		Endpoint:       model.Endpoint,
+		Model:          model.Name,
+		Messages:       messages,
+		MaxTokens:      params.MaxTokens,
+		Temperature:    params.Temperature,
+		TopP:           params.TopP,
+		N:              params.N,
+		Functions:      params.Functions,
+		JSONMode:       params.JSONMode,
+		ResponseSchema: params.ResponseSchema,
+	})
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheBypassKey is the context key of the requests bypassing the cache.
+type cacheBypassKey struct{}
+
+// WithoutCache returns a context whose completions are not answered from the
+// cache. Their results are still cached, replacing the previous ones.
+func WithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+// cacheBypassed reports whether the completions of the context bypass the cache.
+func cacheBypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(cacheBypassKey{}).(bool)
+	return bypass
+}
@@ -0,0 +1,94 @@
+package llm
+
+import (
+	"coda/internal/llm/cache"
+	"context"
+	"testing"
+	"time"
+)
+
+// countingCompleter answers every request with a new completion and counts them.
+type countingCompleter struct {
+	Completer
+	calls int
+}
+
+func (c *countingCompleter) Complete(_ context.Context, _ CompleteParams, model Model) (*CompleteResponse, error) {
+	c.calls++
+	return &CompleteResponse{
+		Messages: []Message{NewAssistantMessage("Review")},
+		Usage:    &Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12},
+		Metadata: CompletionMetadata{ModelName: model.Name, Cost: &Cost{Total: 1, Currency: "USD"}},
+	}, nil
+}
+
+func (c *countingCompleter) Stream(ctx context.Context, params CompleteParams, model Model, fn StreamFunc) (*CompleteResponse, error) {
+	res, _ := c.Complete(ctx, params, model)
+	return res, fn(StreamChunk{Delta: res.Messages[0].Content})
+}
+
+func TestCachingCompleter(t *testing.T) {
+	model := Model{Name: "gpt-4o", Provider: OpenAI}
+	temperature := float32(0.2)
+
+	// Messages are built anew for each request, with new timestamps
+	request := func() CompleteParams {
+		return CompleteParams{Messages: []Message{NewSystemMessage("Review the code"), NewUserMessage("func main() {}")}}
+	}
+	warmer := request()
+	warmer.Temperature = &temperature
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		params    CompleteParams
+		stream    bool
+		wantCalls int
+		wantHit   bool
+	}{
+		{name: "identical request", ctx: context.Background(), params: request(), wantCalls: 1, wantHit: true},
+		{name: "identical request streamed", ctx: context.Background(), params: request(), stream: true, wantCalls: 1, wantHit: true},
+		{name: "other sampling parameters", ctx: context.Background(), params: warmer, wantCalls: 2},
+		{name: "bypassed", ctx: WithoutCache(context.Background()), params: request(), wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &countingCompleter{}
+			c := NewCachingCompleter(next, cache.NewMemory(10), time.Hour)
+			if _, err := c.Complete(context.Background(), request(), model); err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+
+			var (
+				res      *CompleteResponse
+				err      error
+				streamed string
+			)
+			if tt.stream {
+				res, err = c.Stream(tt.ctx, tt.params, model, func(chunk StreamChunk) error {
+					streamed += chunk.Delta
+					return nil
+				})
+			} else {
+				res, err = c.Complete(tt.ctx, tt.params, model)
+			}
+			if err != nil {
+				t.Fatalf("completion error = %v", err)
+			}
+
+			if next.calls != tt.wantCalls {
+				t.Errorf("model calls = %d, want %d", next.calls, tt.wantCalls)
+			}
+			if res.Metadata.CacheHit != tt.wantHit {
+				t.Errorf("cache hit = %v, want %v", res.Metadata.CacheHit, tt.wantHit)
+			}
+			if tt.wantHit && res.Metadata.Cost != nil {
+				t.Errorf("cost of a cached completion = %+v, want nil", res.Metadata.Cost)
+			}
+			if tt.stream && streamed != "Review" {
+				t.Errorf("streamed = %q, want Review", streamed)
+			}
+		})
+	}
+}
@@ -10,7 +10,8 @@ import (
 	"time"
 
 	"github.com/gofrs/uuid/v5"
-	"golang.org/x/sync/errgroup"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // RetryConfig defines the configuration for retry logic.
@@ -47,6 +48,15 @@ type Completer interface {
 		fallbackModels ...Model,
 	) (*CompleteResponse, error)
 
+	// Stream completes the prompt set, delivering incremental deltas to fn
+	// as they are generated, and returns the aggregated result.
+	Stream(
+		ctx context.Context,
+		params CompleteParams,
+		model Model,
+		fn StreamFunc,
+	) (*CompleteResponse, error)
+
 	// GetAvailableModels returns a list of available models.
 	GetAvailableModels() []Model
 }
@@ -56,7 +66,9 @@ var _ Completer = (*completer)(nil)
 
 type completer struct {
 	cfg         *config.Config
-	langfuse    *langfuse.Client
+	langfuse    *langfuse.Exporter
+	metrics     *Metrics
+	budget      *Budget
 	retryConfig RetryConfig
 	registry    *Registry
 }
@@ -71,7 +83,29 @@ func WithCompleterRetryConfig(rc RetryConfig) CompleterOption {
 	}
 }
 
+// WithCompleterLangfuse sets the exporter sending the completions to Langfuse.
+func WithCompleterLangfuse(e *langfuse.Exporter) CompleterOption {
+	return func(c *completer) {
+		c.langfuse = e
+	}
+}
+
+// WithCompleterMetrics sets the Prometheus metrics recording the completions.
+func WithCompleterMetrics(m *Metrics) CompleterOption {
+	return func(c *completer) {
+		c.metrics = m
+	}
+}
+
+// WithCompleterBudget sets the budget limiting the spending of the callers.
+func WithCompleterBudget(b *Budget) CompleterOption {
+	return func(c *completer) {
+		c.budget = b
+	}
+}
+
 // NewCompleter creates a new Completer with the given options.
+// Completions are sent to Langfuse only with WithCompleterLangfuse.
 func NewCompleter(cfg *config.Config, registry *Registry, opts ...CompleterOption) Completer {
 	c := &completer{
 		cfg:         cfg,
@@ -79,10 +113,6 @@ func NewCompleter(cfg *config.Config, registry *Registry, opts ...CompleterOptio
 		registry:    registry,
 	}
 
-	if cfg.LLM.Langfuse.IsConfigured() {
-		c.langfuse = langfuse.NewClient(cfg)
-	}
-
 	// Apply options
 	for _, opt := range opts {
 		opt(c)
@@ -93,7 +123,7 @@ func NewCompleter(cfg *config.Config, registry *Registry, opts ...CompleterOptio
 
 // GetAvailableModels returns a list of available models.
 func (c *completer) GetAvailableModels() []Model {
-	return c.registry.models
+	return c.registry.Models()
 }
 
 // Complete completes the prompt set and returns the result with retry logic.
@@ -101,14 +131,117 @@ func (c *completer) Complete(
 	ctx context.Context,
 	params CompleteParams,
 	model Model,
+) (*CompleteResponse, error) {
+	model, reservation, err := c.applyBudget(ctx, params, model)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.complete(ctx, params, model)
+	c.budget.settle(CallerFromContext(ctx), reservation, res)
+	return res, err
+}
+
+// complete completes the prompt set with the model, without streaming.
+func (c *completer) complete(ctx context.Context, params CompleteParams, model Model) (*CompleteResponse, error) {
+	ctx, span := tracer.Start(ctx, "llm.complete", trace.WithAttributes(modelAttributes(model)...))
+	res, err := c.execute(ctx, params, model, func(ctx context.Context, llm LLM, _ *attempt) (*CompleteResponse, error) {
+		return llm.Complete(ctx, params)
+	})
+	endSpan(span, res, err)
+	return res, err
+}
+
+// Stream completes the prompt set, delivering incremental deltas to fn as they
+// are generated. Models without streaming support are completed in one shot
+// and delivered as a single chunk.
+func (c *completer) Stream(
+	ctx context.Context,
+	params CompleteParams,
+	model Model,
+	fn StreamFunc,
+) (*CompleteResponse, error) {
+	model, reservation, err := c.applyBudget(ctx, params, model)
+	if err != nil {
+		return nil, err
+	}
+
+	if !model.Capabilities.SupportsStreaming {
+		res, err := c.complete(ctx, params, model)
+		c.budget.settle(CallerFromContext(ctx), reservation, res)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := fn(StreamChunk{
+			Delta:        res.Messages[0].Content,
+			FinishReason: res.Messages[0].FinishReason,
+		}); err != nil {
+			return nil, err
+		}
+		return res, nil
+	}
+
+	params.Stream = true
+
+	ctx, span := tracer.Start(ctx, "llm.stream", trace.WithAttributes(modelAttributes(model)...))
+
+	// Track whether any chunk has reached the caller so that a failure
+	// after that point is not retried
+	started := false
+
+	res, err := c.execute(ctx, params, model, func(ctx context.Context, llm LLM, a *attempt) (*CompleteResponse, error) {
+		res, err := llm.Stream(ctx, params, func(chunk StreamChunk) error {
+			if a.firstToken.IsZero() {
+				a.firstToken = time.Now()
+			}
+			started = true
+			return fn(chunk)
+		})
+		if err != nil && started {
+			return nil, fmt.Errorf("%w: %w", ErrStreamInterrupted, err)
+		}
+		return res, err
+	})
+	endSpan(span, res, err)
+	c.budget.settle(CallerFromContext(ctx), reservation, res)
+	return res, err
+}
+
+// attempt is a call of a model, recorded as a Langfuse generation.
+type attempt struct {
+	model      Model
+	number     int       // Attempt number for the model, starting at 1
+	start      time.Time // Start of the request
+	firstToken time.Time // First streamed chunk, zero when not streamed
+	end        time.Time // End of the request
+	res        *CompleteResponse
+	err        error
+}
+
+// execute runs call against a client for the given model with retry logic.
+// Each attempt is traced as a span and sent to Langfuse.
+func (c *completer) execute(
+	ctx context.Context,
+	params CompleteParams,
+	model Model,
+	call func(ctx context.Context, llm LLM, a *attempt) (*CompleteResponse, error),
 ) (*CompleteResponse, error) {
 	var (
 		res *CompleteResponse
 		err error
 	)
 
+	// Reject function calling the model cannot do before calling it
+	if len(params.Functions) > 0 && !model.Capabilities.SupportsFunctions {
+		return nil, fmt.Errorf("%w: model %s does not support function calling", ErrInvalidArguments, model.Name)
+	}
+	if err := ValidateFunctions(params); err != nil {
+		return nil, err
+	}
+
 	// Get API key function for the provider
-	apiKeyFunc, err := c.getAPIKeyFunc(model.Provider)
+	apiKeyFunc, err := c.getAPIKeyFunc(model)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get API key: %w", err)
 	}
@@ -125,19 +258,22 @@ func (c *completer) Complete(
 	}
 
 	// Implement retry logic with exponential backoff
-	var lastErr error
+	var (
+		lastErr  error
+		attempts []*attempt
+	)
 	wait := c.retryConfig.InitialWait
 
-	for attempt := 0; attempt < c.retryConfig.MaxAttempts; attempt++ {
+	for i := 0; i < c.retryConfig.MaxAttempts; i++ {
 		// Check if context is canceled before making the attempt
 		if ctx.Err() != nil {
 			return nil, ctx.Err()
 		}
 
 		// If this is a retry, log the attempt
-		if attempt > 0 {
+		if i > 0 {
 			logger.Info(ctx, "retrying LLM request",
-				"attempt", attempt+1,
+				"attempt", i+1,
 				"model", model.Name,
 				"previous_error", lastErr)
 
@@ -155,7 +291,15 @@ func (c *completer) Complete(
 		}
 
 		// Attempt to complete
-		res, err = llm.Complete(ctx, params)
+		a := &attempt{model: model, number: i + 1, start: time.Now()}
+		attemptCtx, span := tracer.Start(ctx, "llm.attempt", trace.WithAttributes(attribute.Int("llm.attempt", a.number)))
+		res, err = call(attemptCtx, llm, a)
+		if err == nil {
+			res.Metadata.Cost = model.Pricing.Cost(res.Usage)
+		}
+		a.end, a.res, a.err = time.Now(), res, err
+		attempts = append(attempts, a)
+		endSpan(span, res, err)
 
 		// If successful or if error is not retryable, break the loop
 		if err == nil {
@@ -171,6 +315,19 @@ func (c *completer) Complete(
 		}
 	}
 
+	// An empty response is a failed attempt
+	if err == nil && len(res.Messages) == 0 {
+		attempts[len(attempts)-1].err = ErrNoMessages
+	}
+
+	// Record the attempts in the metrics
+	for _, a := range attempts {
+		c.metrics.observeAttempt(a)
+	}
+
+	// Queue the attempts to be sent to Langfuse in the background
+	c.sendTraceEvents(ctx, traceIDFromContext(ctx), params, attempts)
+
 	// If all attempts failed, return the last error
 	if err != nil {
 		return nil, fmt.Errorf("all completion attempts failed: %w", lastErr)
@@ -181,28 +338,6 @@ func (c *completer) Complete(
 		return nil, ErrNoMessages
 	}
 
-	// Send trace events to Langfuse asynchronously
-	go func() {
-		// Recover from any panics
-		defer func() {
-			if r := recover(); r != nil {
-				var err error
-				if e, ok := r.(error); ok {
-					err = e
-				} else {
-					err = fmt.Errorf("%v", r)
-				}
-				logger.Error(ctx, "panic while sending trace events", "error", err)
-			}
-		}()
-
-		// Create a new context for the background operation
-		bgCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
-		c.sendTraceEvents(bgCtx, model, params, res)
-	}()
-
 	return res, nil
 }
 
@@ -213,9 +348,15 @@ func (c *completer) CompleteWithFallback(
 	params CompleteParams,
 	primaryModel Model,
 	fallbackModels ...Model,
-) (*CompleteResponse, error) {
+) (res *CompleteResponse, err error) {
+	ctx, span := tracer.Start(ctx, "llm.complete_with_fallback", trace.WithAttributes(modelAttributes(primaryModel)...))
+	defer func() { endSpan(span, res, err) }()
+
+	// Record the attempts of every model in one trace
+	ctx = withTraceID(ctx, genUUID())
+
 	// Try primary model first
-	res, err := c.Complete(ctx, params, primaryModel)
+	res, err = c.Complete(ctx, params, primaryModel)
 	if err == nil {
 		return res, nil
 	}
@@ -236,6 +377,7 @@ func (c *completer) CompleteWithFallback(
 			"fallback_model", fallbackModel.Name,
 			"fallback_index", i+1)
 
+		c.metrics.observeFallback(fallbackModel)
 		res, err = c.Complete(ctx, params, fallbackModel)
 		if err == nil {
 			return res, nil
@@ -255,55 +397,65 @@ var emptyAPIKeyFunc = func() string {
 	return ""
 }
 
-// getAPIKeyFunc returns the appropriate API key function for the given provider.
-func (c *completer) getAPIKeyFunc(provider Provider) (APIKeyFunc, error) {
-	switch provider {
+// getAPIKeyFunc returns the appropriate API key function for the provider of the given model.
+func (c *completer) getAPIKeyFunc(model Model) (APIKeyFunc, error) {
+	switch model.Provider {
 	case OpenAI:
 		return func() string {
 			return c.cfg.LLM.OpenAI.APIKey
 		}, nil
 	case Ollama:
 		return emptyAPIKeyFunc, nil
+	case Anthropic:
+		return func() string {
+			return c.cfg.LLM.Anthropic.APIKey
+		}, nil
+	case Azure:
+		return func() string {
+			return c.cfg.LLM.Azure.APIKey
+		}, nil
+	case Gemini:
+		return func() string {
+			return c.cfg.LLM.Gemini.APIKey
+		}, nil
+	case OpenAICompatible:
+		endpoint, ok := c.cfg.LLM.Endpoint(model.Endpoint)
+		if !ok {
+			return nil, fmt.Errorf("endpoint %q is not configured", model.Endpoint)
+		}
+		return func() string {
+			return endpoint.APIKey
+		}, nil
 	default:
-		return nil, fmt.Errorf("provider %q is not supported", provider)
+		return nil, fmt.Errorf("provider %q is not supported", model.Provider)
 	}
 }
 
 // isRetryableError determines if an error should trigger a retry.
 func isRetryableError(err error) bool {
+	if errors.Is(err, ErrStreamInterrupted) {
+		return false
+	}
 	return errors.Is(err, ErrServiceUnavailable) ||
 		errors.Is(err, ErrTooManyRequests) ||
+		errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, ErrModelOverloaded) ||
 		errors.Is(err, context.DeadlineExceeded)
 }
 
-// sendTraceEvents sends telemetry data to Langfuse for observability.
-func (c *completer) sendTraceEvents(ctx context.Context, model Model, params CompleteParams, res *CompleteResponse) {
+// sendTraceEvents queues telemetry data to be sent to Langfuse for observability.
+// Each attempt is recorded as a generation of the trace, failed ones with
+// the ERROR level. An empty trace ID starts a new trace.
+func (c *completer) sendTraceEvents(ctx context.Context, traceID string, params CompleteParams, attempts []*attempt) {
 	// Skip if Langfuse is not configured
-	if c.langfuse == nil {
+	if c.langfuse == nil || len(attempts) == 0 {
 		return
 	}
 
-	genUUID := func() string {
-		u, err := uuid.NewV7()
-		if err != nil {
-			// Fallback to V4 if V7 fails
-			u, _ = uuid.NewV4()
-		}
-		return u.String()
+	if traceID == "" {
+		traceID = genUUID()
 	}
 
-	// Create a unique trace ID for this interaction
-	traceID := genUUID()
-	generationID := genUUID()
-
-	// Calculate timestamps
-	now := time.Now().UTC()
-	startTime := now.Format(time.RFC3339Nano)
-
-	// Use actual timestamps if available, otherwise estimate
-	completionStartTime := now.Add(500 * time.Millisecond).Format(time.RFC3339Nano)
-	endTime := now.Add(1200 * time.Millisecond).Format(time.RFC3339Nano)
-
 	// Extract user ID from context if available, otherwise generate one
 	userID := getUserIDFromContext(ctx)
 	if userID == "" {
@@ -313,72 +465,114 @@ func (c *completer) sendTraceEvents(ctx context.Context, model Model, params Com
 	// Extract model parameters from the request
 	modelParams := extractModelParameters(params)
 
-	generationBody := langfuse.GenerationBody{
-		ID:                  generationID,
-		TraceID:             traceID,
-		Name:                "Model Response",
-		StartTime:           startTime,
-		CompletionStartTime: completionStartTime,
-		EndTime:             endTime,
-		Model:               model.Name,
-		ModelParameters:     modelParams,
-		Input:               params.Messages,
-		Output:              res.Messages,
-		Level:               "DEFAULT",
+	model := attempts[0].model
+	trace := langfuse.TraceBody{
+		ID:          traceID,
+		Name:        "Model Interaction",
+		UserID:      userID,
+		Input:       getLastUserMessage(params.Messages),
+		Timestamp:   formatTime(attempts[0].start),
+		Environment: getEnvironment(c.cfg),
+		Tags:        []string{model.Name, string(model.Provider)},
+	}
+	if last := attempts[len(attempts)-1]; last.err == nil {
+		trace.Output = last.res.Messages[0].Content
+	}
+
+	// Create a batch of events: the trace of this interaction and
+	// the model usage of each attempt
+	batch := []langfuse.Event{langfuse.CreateTrace(genUUID(), trace)}
+	for _, a := range attempts {
+		batch = append(batch, langfuse.CreateGeneration(genUUID(), generationBody(traceID, modelParams, params, a)))
 	}
 
+	c.langfuse.Enqueue(batch...)
+}
+
+// generationBody builds the Langfuse generation of an attempt.
+func generationBody(traceID string, modelParams map[string]any, params CompleteParams, a *attempt) langfuse.GenerationBody {
+	body := langfuse.GenerationBody{
+		ID:              genUUID(),
+		TraceID:         traceID,
+		Name:            "Model Response",
+		StartTime:       formatTime(a.start),
+		EndTime:         formatTime(a.end),
+		Model:           a.model.Name,
+		ModelParameters: modelParams,
+		Input:           params.Messages,
+		Level:           "DEFAULT",
+	}
+	metadata := map[string]any{"attempt": a.number, "provider": a.model.Provider}
+	body.Metadata = metadata
+	if !a.firstToken.IsZero() {
+		body.CompletionStartTime = formatTime(a.firstToken)
+	}
+
+	if a.err != nil {
+		body.Level = "ERROR"
+		body.StatusMessage = a.err.Error()
+		return body
+	}
+
+	res := a.res
+	body.Output = res.Messages
+
 	if res.Usage != nil {
 		// Track token usage for cost calculation
-		generationBody.UsageDetails = map[string]int{
+		body.UsageDetails = map[string]int{
 			"prompt_tokens":     res.Usage.PromptTokens,
 			"completion_tokens": res.Usage.CompletionTokens,
 			"total_tokens":      res.Usage.TotalTokens,
 		}
 	}
 
-	// Create a batch of events
-	batch := []langfuse.Event{
-		// Create a trace for this interaction
-		langfuse.CreateTrace(
-			genUUID(),
-			langfuse.TraceBody{
-				ID:          traceID,
-				Name:        "Model Interaction",
-				UserID:      userID,
-				Input:       getLastUserMessage(params.Messages),
-				Output:      res.Messages[0].Content,
-				Timestamp:   startTime,
-				Environment: getEnvironment(c.cfg),
-				Tags:        []string{model.Name, string(model.Provider)},
-			},
-		),
-
-		// Track the model usage
-		langfuse.CreateGeneration(
-			genUUID(),
-			generationBody,
-		),
-	}
-
-	// Send the batch to Langfuse with a timeout
-	g, gCtx := errgroup.WithContext(ctx)
-	g.Go(func() error {
-		resp, err := c.langfuse.Ingest(batch)
-		if err != nil {
-			logger.Error(gCtx, "failed to send trace events to Langfuse", "err", err)
-			return err
+	if cost := res.Metadata.Cost; cost != nil {
+		body.CostDetails = map[string]float64{
+			"input":  cost.Input,
+			"output": cost.Output,
+			"total":  cost.Total,
 		}
+	}
 
-		if len(resp.Errors) > 0 {
-			logger.Error(gCtx, "failed to ingest some events", "errors", resp.Errors)
-		}
-		return nil
-	})
+	if t := res.Metadata.Timings; t != nil {
+		// Throughput of self-hosted models, to compare the hardware running them
+		metadata["load_ms"] = t.Load.Milliseconds()
+		metadata["prompt_eval_ms"] = t.PromptEval.Milliseconds()
+		metadata["eval_ms"] = t.Eval.Milliseconds()
+		metadata["prompt_tokens_per_second"] = t.PromptTokensPerSecond
+		metadata["tokens_per_second"] = t.TokensPerSecond
+	}
+
+	return body
+}
+
+// traceIDKey is the context key of the Langfuse trace of the completions.
+type traceIDKey struct{}
 
-	// Wait with timeout
-	if err := g.Wait(); err != nil {
-		logger.Error(ctx, "error sending telemetry", "err", err)
+// withTraceID returns a context whose completions are recorded in the trace with the given ID.
+func withTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, traceID)
+}
+
+// traceIDFromContext returns the ID of the trace of the completions, or an empty string.
+func traceIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// genUUID generates a time-ordered ID for Langfuse events.
+func genUUID() string {
+	u, err := uuid.NewV7()
+	if err != nil {
+		// Fallback to V4 if V7 fails
+		u, _ = uuid.NewV4()
 	}
+	return u.String()
+}
+
+// formatTime formats a timestamp for Langfuse.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
 }
 
 // Helper functions
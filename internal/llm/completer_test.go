@@ -0,0 +1,144 @@
+package llm
+
+import (
+	"coda/internal/config"
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// flakyLLM fails with the errors in turn, then answers.
+type flakyLLM struct {
+	errs []error
+}
+
+func (f *flakyLLM) Complete(context.Context, CompleteParams) (*CompleteResponse, error) {
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	return &CompleteResponse{
+		Messages: []Message{NewAssistantMessage("Hello")},
+		Usage:    &Usage{PromptTokens: 10, CompletionTokens: 2, TotalTokens: 12},
+	}, nil
+}
+
+func (f *flakyLLM) Stream(ctx context.Context, params CompleteParams, _ StreamFunc) (*CompleteResponse, error) {
+	return f.Complete(ctx, params)
+}
+
+func TestCompleteSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	// The Ollama client is not linked in the tests of this package
+	flaky := &flakyLLM{errs: []error{ErrServiceUnavailable}}
+	RegisterProvider(Ollama, func(Config) (LLM, error) { return flaky, nil })
+
+	c := NewCompleter(&config.Config{}, nil, WithCompleterRetryConfig(RetryConfig{
+		MaxAttempts: 3, InitialWait: time.Millisecond, MaxWait: time.Millisecond, Factor: 1,
+	}))
+	model := Model{Name: "llama3", Provider: Ollama}
+	if _, err := c.CompleteWithFallback(context.Background(), CompleteParams{
+		Messages: []Message{NewUserMessage("Hi")},
+	}, model); err != nil {
+		t.Fatalf("CompleteWithFallback() error = %v", err)
+	}
+
+	spans := recorder.Ended()
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.Name())
+	}
+	want := []string{"llm.attempt", "llm.attempt", "llm.complete", "llm.complete_with_fallback"}
+	if len(names) != len(want) {
+		t.Fatalf("spans = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("spans = %v, want %v", names, want)
+		}
+	}
+
+	failed, succeeded, complete, fallback := spans[0], spans[1], spans[2], spans[3]
+	if failed.Status().Code != codes.Error || succeeded.Status().Code == codes.Error {
+		t.Errorf("attempt statuses = %v, %v, want the first one only failed", failed.Status(), succeeded.Status())
+	}
+	if failed.Parent().SpanID() != complete.SpanContext().SpanID() || complete.Parent().SpanID() != fallback.SpanContext().SpanID() {
+		t.Error("attempt spans are not children of the completion span, itself child of the fallback span")
+	}
+	for _, kv := range succeeded.Attributes() {
+		if kv.Key == "gen_ai.usage.output_tokens" && kv.Value.AsInt64() != 2 {
+			t.Errorf("output tokens = %d, want 2", kv.Value.AsInt64())
+		}
+	}
+	if events := failed.Events(); len(events) == 0 || events[0].Name != "exception" {
+		t.Errorf("failed attempt events = %v, want the exception", events)
+	}
+}
+
+func TestGenerationBody(t *testing.T) {
+	start := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	model := Model{Name: "gpt-4o", Provider: OpenAI}
+
+	tests := []struct {
+		name                string
+		attempt             attempt
+		wantLevel           string
+		wantCompletionStart string
+	}{
+		{
+			name: "Success",
+			attempt: attempt{
+				model: model, number: 1, start: start, end: start.Add(2 * time.Second),
+				res: &CompleteResponse{Messages: []Message{NewAssistantMessage("Hello")}},
+			},
+			wantLevel: "DEFAULT",
+		},
+		{
+			name: "Streamed",
+			attempt: attempt{
+				model: model, number: 1, start: start, firstToken: start.Add(300 * time.Millisecond), end: start.Add(2 * time.Second),
+				res: &CompleteResponse{Messages: []Message{NewAssistantMessage("Hello")}},
+			},
+			wantLevel:           "DEFAULT",
+			wantCompletionStart: "2025-01-02T03:04:05.3Z",
+		},
+		{
+			name:      "Failure",
+			attempt:   attempt{model: model, number: 2, start: start, end: start.Add(2 * time.Second), err: ErrRateLimited},
+			wantLevel: "ERROR",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := generationBody("trace", nil, CompleteParams{}, &tt.attempt)
+
+			if body.TraceID != "trace" || body.Model != "gpt-4o" {
+				t.Errorf("trace ID = %q, model = %q", body.TraceID, body.Model)
+			}
+			if body.StartTime != "2025-01-02T03:04:05Z" || body.EndTime != "2025-01-02T03:04:07Z" {
+				t.Errorf("start = %s, end = %s", body.StartTime, body.EndTime)
+			}
+			if body.CompletionStartTime != tt.wantCompletionStart {
+				t.Errorf("completion start = %q, want %q", body.CompletionStartTime, tt.wantCompletionStart)
+			}
+			if body.Level != tt.wantLevel {
+				t.Errorf("level = %q, want %q", body.Level, tt.wantLevel)
+			}
+			if tt.attempt.err != nil && body.StatusMessage != tt.attempt.err.Error() {
+				t.Errorf("status message = %q, want %q", body.StatusMessage, tt.attempt.err.Error())
+			}
+			if metadata := body.Metadata.(map[string]any); metadata["attempt"] != tt.attempt.number {
+				t.Errorf("attempt = %v, want %d", metadata["attempt"], tt.attempt.number)
+			}
+		})
+	}
+}
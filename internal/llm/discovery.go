@@ -0,0 +1,141 @@
+package llm
+
+import (
+	"coda/internal/config"
+	"coda/internal/logger"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultDiscoveryRefreshInterval is how often models are rediscovered
+// when no interval is configured.
+const DefaultDiscoveryRefreshInterval = 5 * time.Minute
+
+// discoveryTimeout bounds a single discovery run across all providers.
+const discoveryTimeout = 30 * time.Second
+
+// DiscoverFunc lists the models currently available from a provider's server.
+// A provider with several servers may return the models it found along with an error.
+type DiscoverFunc func(ctx context.Context, cfg config.LLM) ([]Model, error)
+
+// Registry of model discoverers
+var discoverers = map[Provider]DiscoverFunc{}
+
+// RegisterDiscoverer registers a function that discovers the models of a provider.
+func RegisterDiscoverer(provider Provider, fn DiscoverFunc) {
+	modelRegistryMu.Lock()
+	defer modelRegistryMu.Unlock()
+
+	discoverers[provider] = fn
+}
+
+// Refresh discovers the models of every configured provider and updates the catalog.
+// The previously discovered models of a provider are kept when its discovery fails
+// without finding any model.
+func (r *Registry) Refresh(ctx context.Context) error {
+	modelRegistryMu.RLock()
+	fns := make(map[Provider]DiscoverFunc, len(discoverers))
+	for provider, fn := range discoverers {
+		if isProviderConfigured(r.cfg, provider) {
+			fns[provider] = fn
+		}
+	}
+	modelRegistryMu.RUnlock()
+
+	var errs []error
+	discovered := make(map[Provider][]Model, len(fns))
+	for provider, fn := range fns {
+		models, err := fn(ctx, r.cfg.LLM)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("discovering %s models: %w", provider, err))
+			if len(models) == 0 {
+				continue
+			}
+		}
+		discovered[provider] = models
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for provider, models := range discovered {
+		r.discovered[provider] = models
+	}
+	r.rebuild()
+
+	return errors.Join(errs...)
+}
+
+// Start discovers models in the background and keeps refreshing them
+// on the configured interval until Stop is called.
+func (r *Registry) Start() {
+	if r.cfg.LLM.Discovery.Disabled || r.cancel != nil {
+		return
+	}
+
+	interval := r.cfg.LLM.Discovery.RefreshInterval
+	if interval <= 0 {
+		interval = DefaultDiscoveryRefreshInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			r.refreshWithTimeout(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop stops the background discovery started by Start.
+func (r *Registry) Stop() {
+	if r.cancel == nil {
+		return
+	}
+
+	r.cancel()
+	<-r.done
+	r.cancel = nil
+}
+
+// refreshWithTimeout runs a single bounded discovery and logs its outcome.
+func (r *Registry) refreshWithTimeout(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, discoveryTimeout)
+	defer cancel()
+
+	if err := r.Refresh(ctx); err != nil {
+		logger.Warn(ctx, "model discovery failed", "err", err)
+		return
+	}
+	logger.Debug(ctx, "model discovery completed", "models", len(r.Models()))
+}
+
+// mergeDiscoveredModel refines a known model with the metadata reported by its server.
+// Curated fields such as the display name, pricing and capabilities are kept.
+func mergeDiscoveredModel(known, discovered Model) Model {
+	if discovered.ContextWindow > 0 {
+		known.ContextWindow = discovered.ContextWindow
+	}
+	if discovered.Family != "" {
+		known.Family = discovered.Family
+	}
+	if discovered.Version != "" {
+		known.Version = discovered.Version
+	}
+	return known
+}
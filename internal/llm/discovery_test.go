@@ -0,0 +1,155 @@
+package llm
+
+import (
+	"coda/internal/config"
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDiscoverer returns the models of a server that can go down.
+type fakeDiscoverer struct {
+	mu     sync.Mutex
+	models []Model
+	err    error
+	calls  chan struct{}
+}
+
+func (d *fakeDiscoverer) set(models []Model, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.models, d.err = models, err
+}
+
+func (d *fakeDiscoverer) discover(context.Context, config.LLM) ([]Model, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.calls != nil {
+		select {
+		case d.calls <- struct{}{}:
+		default:
+		}
+	}
+	return d.models, d.err
+}
+
+// registerFakeDiscoverer registers a fake discoverer of Ollama models for the test.
+func registerFakeDiscoverer(t *testing.T) *fakeDiscoverer {
+	t.Helper()
+
+	d := &fakeDiscoverer{}
+	RegisterDiscoverer(Ollama, d.discover)
+	t.Cleanup(func() {
+		modelRegistryMu.Lock()
+		defer modelRegistryMu.Unlock()
+		delete(discoverers, Ollama)
+	})
+	return d
+}
+
+// ollamaModels returns the names of the Ollama models of the registry.
+func ollamaModels(r *Registry) []string {
+	var names []string
+	for _, m := range r.Models() {
+		if m.Provider == Ollama {
+			names = append(names, m.Name)
+		}
+	}
+	return names
+}
+
+func TestRegistryRefresh(t *testing.T) {
+	d := registerFakeDiscoverer(t)
+	r, err := NewRegistry(&config.Config{LLM: config.LLM{Ollama: config.Ollama{BaseURL: "http://127.0.0.1:11434"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errDown := errors.New("connection refused")
+	steps := []struct {
+		name    string
+		models  []string
+		err     error
+		want    []string
+		wantErr bool
+	}{
+		{name: "Up", models: []string{"qwen2.5-coder", "llama3.2"}, want: []string{"qwen2.5-coder", "llama3.2"}},
+		// The last known models are kept while the server is down
+		{name: "Down", err: errDown, want: []string{"qwen2.5-coder", "llama3.2"}, wantErr: true},
+		// Models found despite an error replace the previous ones
+		{name: "Partial", models: []string{"llama3.2"}, err: errDown, want: []string{"llama3.2"}, wantErr: true},
+		{name: "Removed", models: []string{}, want: nil},
+	}
+
+	for _, step := range steps {
+		var models []Model
+		for _, name := range step.models {
+			models = append(models, Model{Provider: Ollama, Name: name})
+		}
+		d.set(models, step.err)
+
+		err := r.Refresh(context.Background())
+		if (err != nil) != step.wantErr || (err != nil && !errors.Is(err, errDown)) {
+			t.Errorf("%s: Refresh() error = %v, wantErr %v", step.name, err, step.wantErr)
+		}
+		if got := ollamaModels(r); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: models = %q, want %q", step.name, got, step.want)
+		}
+	}
+}
+
+func TestRegistryRefreshUnconfigured(t *testing.T) {
+	d := registerFakeDiscoverer(t)
+	d.set([]Model{{Provider: Ollama, Name: "qwen2.5-coder"}}, nil)
+
+	// Providers without configuration are not discovered
+	r, err := NewRegistry(&config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Refresh(context.Background()); err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if got := ollamaModels(r); len(got) != 0 {
+		t.Errorf("models = %q, want none", got)
+	}
+}
+
+func TestRegistryStartStop(t *testing.T) {
+	d := registerFakeDiscoverer(t)
+	d.calls = make(chan struct{})
+	d.set([]Model{{Provider: Ollama, Name: "qwen2.5-coder"}}, nil)
+
+	r, err := NewRegistry(&config.Config{LLM: config.LLM{
+		Ollama:    config.Ollama{BaseURL: "http://127.0.0.1:11434"},
+		Discovery: config.Discovery{RefreshInterval: time.Millisecond},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Start()
+	r.Start() // Started once
+	// Discovery runs at start, then on every interval
+	for range 2 {
+		select {
+		case <-d.calls:
+		case <-time.After(5 * time.Second):
+			t.Fatal("discovery did not run")
+		}
+	}
+	r.Stop()
+	r.Stop()
+
+	if got := ollamaModels(r); !reflect.DeepEqual(got, []string{"qwen2.5-coder"}) {
+		t.Errorf("models = %q, want the discovered model", got)
+	}
+	select {
+	case <-d.calls:
+		t.Error("discovery ran after Stop")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
@@ -22,6 +22,10 @@ var (
 	ErrTooManyRequests    = errors.New("too many requests")
 	ErrTimeout            = errors.New("request timed out")
 	ErrRateLimited        = errors.New("rate limited")
+	ErrBudgetExceeded     = errors.New("spending budget exceeded")
+
+	// Streaming errors
+	ErrStreamInterrupted = errors.New("stream interrupted")
 
 	// Content errors
 	ErrContentFiltered   = errors.New("content filtered by safety system")
@@ -130,6 +134,11 @@ func (e *LLMError) WithRetryable(retryable bool) *LLMError {
 
 // IsRetryable returns true if the error is retryable.
 func IsRetryable(err error) bool {
+	// Retrying a partially delivered stream would duplicate output
+	if errors.Is(err, ErrStreamInterrupted) {
+		return false
+	}
+
 	var llmErr *LLMError
 	if errors.As(err, &llmErr) {
 		return llmErr.Retryable
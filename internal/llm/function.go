@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ValidateFunctions checks the function definitions and the function calls and
+// results of the conversation before they are sent to a provider.
+func ValidateFunctions(params CompleteParams) error {
+	names := make(map[string]bool, len(params.Functions))
+	for _, f := range params.Functions {
+		if f.Name == "" {
+			return fmt.Errorf("%w: function without a name", ErrInvalidArguments)
+		}
+		if names[f.Name] {
+			return fmt.Errorf("%w: function %s is defined twice", ErrInvalidArguments, f.Name)
+		}
+		names[f.Name] = true
+	}
+
+	for i, m := range params.Messages {
+		switch {
+		case m.Role == RoleFunction && m.Name == "":
+			return fmt.Errorf("%w: function message %d has no function name", ErrInvalidFunctionCall, i)
+		case m.FunctionCall != nil && m.Role != RoleAssistant:
+			return fmt.Errorf("%w: message %d with role %s has a function call", ErrInvalidFunctionCall, i, m.Role)
+		case m.FunctionCall != nil && m.FunctionCall.Name == "":
+			return fmt.Errorf("%w: function call of message %d has no name", ErrInvalidFunctionCall, i)
+		}
+	}
+
+	return nil
+}
+
+// ValidateFunctionCall checks that a function call made by a model refers to one
+// of the functions offered to it and that its arguments are a JSON object.
+// Empty or null arguments are normalized to an empty object.
+func ValidateFunctionCall(call *FunctionCall, functions []FunctionDefinition) error {
+	if call.Name == "" {
+		return fmt.Errorf("%w: missing function name", ErrInvalidFunctionCall)
+	}
+
+	found := false
+	for _, f := range functions {
+		if f.Name == call.Name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", ErrFunctionNotFound, call.Name)
+	}
+
+	if args := strings.TrimSpace(call.Arguments); args == "" || args == "null" {
+		call.Arguments = "{}"
+	}
+	var args map[string]any
+	if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+		return fmt.Errorf("%w: arguments of %s are not a JSON object: %w", ErrInvalidFunctionCall, call.Name, err)
+	}
+
+	return nil
+}
+
+// FunctionCalls returns the function calls of the messages of a response.
+func (r *CompleteResponse) FunctionCalls() []*FunctionCall {
+	var calls []*FunctionCall
+	for _, m := range r.Messages {
+		if m.FunctionCall != nil {
+			calls = append(calls, m.FunctionCall)
+		}
+	}
+	return calls
+}
@@ -0,0 +1,107 @@
+package llm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateFunctionCall(t *testing.T) {
+	functions := []FunctionDefinition{{Name: "read_file"}}
+
+	tests := []struct {
+		name    string
+		call    FunctionCall
+		want    string
+		wantErr error
+	}{
+		{
+			name: "Valid",
+			call: FunctionCall{Name: "read_file", Arguments: `{"path": "main.go"}`},
+			want: `{"path": "main.go"}`,
+		},
+		{
+			name: "EmptyArguments",
+			call: FunctionCall{Name: "read_file"},
+			want: "{}",
+		},
+		{
+			name: "NullArguments",
+			call: FunctionCall{Name: "read_file", Arguments: "null"},
+			want: "{}",
+		},
+		{
+			name:    "UnknownFunction",
+			call:    FunctionCall{Name: "write_file", Arguments: "{}"},
+			wantErr: ErrFunctionNotFound,
+		},
+		{
+			name:    "MissingName",
+			call:    FunctionCall{Arguments: "{}"},
+			wantErr: ErrInvalidFunctionCall,
+		},
+		{
+			name:    "ArgumentsNotObject",
+			call:    FunctionCall{Name: "read_file", Arguments: `["main.go"]`},
+			wantErr: ErrInvalidFunctionCall,
+		},
+		{
+			name:    "MalformedArguments",
+			call:    FunctionCall{Name: "read_file", Arguments: `{"path": `},
+			wantErr: ErrInvalidFunctionCall,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFunctionCall(&tt.call, functions)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ValidateFunctionCall() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && tt.call.Arguments != tt.want {
+				t.Errorf("Arguments = %q, want %q", tt.call.Arguments, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFunctions(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  CompleteParams
+		wantErr error
+	}{
+		{
+			name: "Valid",
+			params: CompleteParams{
+				Functions: []FunctionDefinition{{Name: "read_file"}},
+				Messages: []Message{
+					{Role: RoleAssistant, FunctionCall: &FunctionCall{ID: "call_1", Name: "read_file"}},
+					NewFunctionResultMessage(&FunctionCall{ID: "call_1", Name: "read_file"}, "package main"),
+				},
+			},
+		},
+		{
+			name:    "DuplicateFunction",
+			params:  CompleteParams{Functions: []FunctionDefinition{{Name: "read_file"}, {Name: "read_file"}}},
+			wantErr: ErrInvalidArguments,
+		},
+		{
+			name:    "FunctionMessageWithoutName",
+			params:  CompleteParams{Messages: []Message{{Role: RoleFunction, Content: "package main"}}},
+			wantErr: ErrInvalidFunctionCall,
+		},
+		{
+			name:    "UserFunctionCall",
+			params:  CompleteParams{Messages: []Message{{Role: RoleUser, FunctionCall: &FunctionCall{Name: "read_file"}}}},
+			wantErr: ErrInvalidFunctionCall,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateFunctions(tt.params); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateFunctions() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
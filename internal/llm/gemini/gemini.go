@@ -0,0 +1,551 @@
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"coda/internal/llm"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Supported models
+// https://ai.google.dev/gemini-api/docs/models
+var (
+	ModelGeminiFlash = llm.Model{
+		Name:          "gemini-2.0-flash",
+		DisplayName:   "Google Gemini 2.0 Flash",
+		Provider:      llm.Gemini,
+		MaxToken:      8192,
+		ContextWindow: 1_048_576,
+		PDFSupported:  true,
+		Version:       "2025-02",
+		Family:        "Gemini",
+		Pricing: &llm.ModelPricing{
+			InputPerToken:  0.0000001,
+			OutputPerToken: 0.0000004,
+			Currency:       "USD",
+		},
+		Capabilities: llm.ModelCapabilities{
+			SupportsStreaming: true,
+			SupportsFunctions: true,
+			SupportsVision:    true,
+			SupportsJSON:      true,
+		},
+	}
+
+	ModelGeminiPro = llm.Model{
+		Name:          "gemini-1.5-pro",
+		DisplayName:   "Google Gemini 1.5 Pro",
+		Provider:      llm.Gemini,
+		MaxToken:      8192,
+		ContextWindow: 2_097_152,
+		PDFSupported:  true,
+		Version:       "2024-09",
+		Family:        "Gemini",
+		Pricing: &llm.ModelPricing{
+			InputPerToken:  0.00000125,
+			OutputPerToken: 0.000005,
+			Currency:       "USD",
+		},
+		Capabilities: llm.ModelCapabilities{
+			SupportsStreaming: true,
+			SupportsFunctions: true,
+			SupportsVision:    true,
+			SupportsJSON:      true,
+		},
+	}
+)
+
+// API defaults
+const (
+	DefaultBaseURL = "https://generativelanguage.googleapis.com"
+	APIVersion     = "v1beta"
+)
+
+// Error statuses from the Generative Language API
+const (
+	StatusInvalidArgument    = "INVALID_ARGUMENT"
+	StatusFailedPrecondition = "FAILED_PRECONDITION"
+	StatusPermissionDenied   = "PERMISSION_DENIED"
+	StatusNotFound           = "NOT_FOUND"
+	StatusResourceExhausted  = "RESOURCE_EXHAUSTED"
+	StatusInternal           = "INTERNAL"
+	StatusUnavailable        = "UNAVAILABLE"
+	StatusDeadlineExceeded   = "DEADLINE_EXCEEDED"
+)
+
+// Reasons for blocking a prompt or stopping a candidate. Prompts and answers
+// blocked by the safety filters are reported as filtered, those matching a
+// terminology blocklist or a policy as not allowed. Prompts blocked for
+// other reasons are reported as filtered.
+var (
+	filteredReasons   = []string{"SAFETY", "RECITATION", "IMAGE_SAFETY"}
+	notAllowedReasons = []string{"BLOCKLIST", "PROHIBITED_CONTENT", "SPII"}
+)
+
+// Ensure Client implements the LLM interface
+var _ llm.LLM = (*Client)(nil)
+
+// Client is a Generative Language API client that implements the LLM interface.
+type Client struct {
+	cfg        llm.Config
+	baseURL    string
+	httpClient *http.Client
+}
+
+// New creates a new Gemini client.
+func New(cfg llm.Config) (llm.LLM, error) {
+	if cfg.APIKeyFunc == nil {
+		return nil, fmt.Errorf("API key function is required")
+	}
+
+	baseURL := cfg.LLMConfig.Gemini.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+
+	return &Client{
+		cfg:        cfg,
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: llm.NewHTTPClient(cfg.Timeout),
+	}, nil
+}
+
+// Complete processes the given parameters and returns a completion response.
+func (c *Client) Complete(
+	ctx context.Context,
+	params llm.CompleteParams,
+) (*llm.CompleteResponse, error) {
+	startTime := time.Now()
+
+	req, err := c.buildRequest(params)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.send(ctx, "generateContent", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var res generateContentResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, llm.NewLLMError(fmt.Errorf("decoding response: %w", err), string(llm.Gemini), c.cfg.Model.Name)
+	}
+	if err := c.blocked(res); err != nil {
+		return nil, err
+	}
+	if len(res.Candidates) == 0 {
+		return nil, llm.ErrNoMessages
+	}
+
+	// Convert response to our format
+	var msgs []llm.Message
+	for _, cand := range res.Candidates {
+		var (
+			content strings.Builder
+			calls   []*llm.FunctionCall
+		)
+		for _, p := range cand.Content.Parts {
+			content.WriteString(p.Text)
+			if p.FunctionCall != nil {
+				calls = append(calls, toFunctionCall(p.FunctionCall))
+			}
+		}
+
+		candMsgs, err := toMessages(content.String(), cand.FinishReason, calls, params.Functions)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, candMsgs...)
+	}
+
+	return c.response(msgs, res.ResponseID, res.Candidates[0].FinishReason, res.UsageMetadata, startTime), nil
+}
+
+// Stream processes the given parameters, delivering each generated delta to fn,
+// and returns the aggregated completion response.
+func (c *Client) Stream(
+	ctx context.Context,
+	params llm.CompleteParams,
+	fn llm.StreamFunc,
+) (*llm.CompleteResponse, error) {
+	startTime := time.Now()
+
+	req, err := c.buildRequest(params)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.send(ctx, "streamGenerateContent", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// Each event carries the parts generated since the previous one.
+	// Function calls are sent whole and only the first candidate is streamed.
+	var (
+		content      strings.Builder
+		calls        []*llm.FunctionCall
+		responseID   string
+		finishReason string
+		usage        *usageMetadata
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+
+		var event generateContentResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(data)), &event); err != nil {
+			return nil, llm.NewLLMError(fmt.Errorf("decoding stream event: %w", err), string(llm.Gemini), c.cfg.Model.Name)
+		}
+		if event.Error != nil {
+			return nil, c.apiError(event.Error.Code, event.Error.Status, event.Error.Message)
+		}
+		if err := c.blocked(event); err != nil {
+			return nil, err
+		}
+
+		if event.ResponseID != "" {
+			responseID = event.ResponseID
+		}
+		if event.UsageMetadata != nil {
+			usage = event.UsageMetadata
+		}
+		if len(event.Candidates) == 0 {
+			continue
+		}
+
+		cand := event.Candidates[0]
+		for _, p := range cand.Content.Parts {
+			if p.FunctionCall != nil {
+				calls = append(calls, toFunctionCall(p.FunctionCall))
+			}
+			if p.Text == "" {
+				continue
+			}
+			content.WriteString(p.Text)
+			if err := fn(llm.StreamChunk{Delta: p.Text}); err != nil {
+				return nil, err
+			}
+		}
+		if cand.FinishReason != "" {
+			finishReason = cand.FinishReason
+			if err := fn(llm.StreamChunk{FinishReason: finishReason}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, llm.NewLLMError(err, string(llm.Gemini), c.cfg.Model.Name)
+	}
+
+	msgs, err := toMessages(content.String(), finishReason, calls, params.Functions)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.response(msgs, responseID, finishReason, usage, startTime), nil
+}
+
+// response builds the completion response.
+func (c *Client) response(msgs []llm.Message, id, finishReason string, usage *usageMetadata, startTime time.Time) *llm.CompleteResponse {
+	if usage == nil {
+		usage = &usageMetadata{}
+	}
+
+	return &llm.CompleteResponse{
+		Messages: msgs,
+		Usage: &llm.Usage{
+			Unit:             "tokens",
+			PromptTokens:     usage.PromptTokenCount,
+			CompletionTokens: usage.CandidatesTokenCount,
+			TotalTokens:      usage.TotalTokenCount,
+		},
+		Metadata: llm.CompletionMetadata{
+			ModelName:     c.cfg.Model.Name,
+			FinishReason:  finishReason,
+			CompletionID:  id,
+			LatencyMs:     time.Since(startTime).Milliseconds(),
+			ProcessedAt:   time.Now().UTC(),
+			RequestTokens: usage.PromptTokenCount,
+		},
+	}
+}
+
+// send sends a request to the given method of the model and returns the
+// response, or the API error. Streamed methods are requested as server-sent events.
+func (c *Client) send(ctx context.Context, method string, req generateContentRequest) (*http.Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("encoding request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/%s/models/%s:%s", c.baseURL, APIVersion, url.PathEscape(c.cfg.Model.Name), method)
+	if method == "streamGenerateContent" {
+		endpoint += "?alt=sse"
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Goog-Api-Key", c.cfg.APIKeyFunc())
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, c.handleError(err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+
+		var errResp errorResponse
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		if err := json.Unmarshal(data, &errResp); err != nil || errResp.Error.Message == "" {
+			errResp.Error.Message = strings.TrimSpace(string(data))
+		}
+		return nil, c.apiError(resp.StatusCode, errResp.Error.Status, errResp.Error.Message)
+	}
+
+	return resp, nil
+}
+
+// buildRequest converts the given parameters to a generateContent request.
+// System messages are sent as the system instruction, assistant messages as
+// model turns and function results as function responses of user turns.
+func (c *Client) buildRequest(params llm.CompleteParams) (generateContentRequest, error) {
+	var (
+		req    generateContentRequest
+		system []part
+	)
+	for _, m := range params.Messages {
+		switch m.Role {
+		case llm.RoleSystem:
+			system = append(system, part{Text: m.Content})
+		case llm.RoleUser:
+			req.Contents = appendPart(req.Contents, "user", part{Text: m.Content})
+		case llm.RoleAssistant:
+			req.Contents = appendPart(req.Contents, "model", part{Text: m.Content})
+			if m.FunctionCall == nil {
+				continue
+			}
+
+			args := json.RawMessage(m.FunctionCall.Arguments)
+			if strings.TrimSpace(m.FunctionCall.Arguments) == "" {
+				args = json.RawMessage("{}")
+			}
+			if !json.Valid(args) {
+				return generateContentRequest{}, fmt.Errorf("%w: arguments of %s are not valid JSON", llm.ErrInvalidFunctionCall, m.FunctionCall.Name)
+			}
+			req.Contents = appendPart(req.Contents, "model", part{FunctionCall: &functionCall{
+				ID:   m.FunctionCall.ID,
+				Name: m.FunctionCall.Name,
+				Args: args,
+			}})
+		case llm.RoleFunction:
+			// Function calls are answered by name, the ID is only set by some models
+			req.Contents = appendPart(req.Contents, "user", part{FunctionResponse: &functionResponse{
+				ID:       m.CallID,
+				Name:     m.Name,
+				Response: functionResult(m.Content),
+			}})
+		default:
+			return generateContentRequest{}, fmt.Errorf("unsupported role: %s", m.Role)
+		}
+	}
+	if len(system) > 0 {
+		req.SystemInstruction = &content{Parts: system}
+	}
+
+	if len(params.Functions) > 0 {
+		declarations := make([]functionDeclaration, 0, len(params.Functions))
+		for _, f := range params.Functions {
+			declarations = append(declarations, functionDeclaration{Name: f.Name, Description: f.Description, Parameters: f.Parameters})
+		}
+		req.Tools = []tool{{FunctionDeclarations: declarations}}
+	}
+
+	config := generationConfig{
+		Temperature:     params.Temperature,
+		TopP:            params.TopP,
+		MaxOutputTokens: params.MaxTokens,
+		CandidateCount:  params.N,
+	}
+
+	// JSON output cannot be combined with function calling
+	if params.JSONMode && len(params.Functions) == 0 {
+		config.ResponseMIMEType = "application/json"
+	}
+	if config != (generationConfig{}) {
+		req.GenerationConfig = &config
+	}
+
+	if len(req.Contents) == 0 {
+		return generateContentRequest{}, fmt.Errorf("%w: no user message", llm.ErrInvalidArguments)
+	}
+	return req, nil
+}
+
+// appendPart appends a part to the conversation. Consecutive parts of the same
+// role are sent as one turn, so that the responses of parallel function calls
+// follow their calls. Empty text parts are skipped.
+func appendPart(contents []content, role string, p part) []content {
+	if p.FunctionCall == nil && p.FunctionResponse == nil && strings.TrimSpace(p.Text) == "" {
+		return contents
+	}
+	if n := len(contents); n > 0 && contents[n-1].Role == role {
+		contents[n-1].Parts = append(contents[n-1].Parts, p)
+		return contents
+	}
+	return append(contents, content{Role: role, Parts: []part{p}})
+}
+
+// functionResult converts the result of a function to a function response,
+// which must be a JSON object. Other results are wrapped in one.
+func functionResult(result string) json.RawMessage {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(result), &object); err == nil && object != nil {
+		return json.RawMessage(result)
+	}
+
+	data, _ := json.Marshal(map[string]string{"content": result})
+	return data
+}
+
+// toFunctionCall converts a function call of the model to our format.
+func toFunctionCall(call *functionCall) *llm.FunctionCall {
+	args := string(call.Args)
+	if args == "" {
+		args = "{}"
+	}
+	return &llm.FunctionCall{ID: call.ID, Name: call.Name, Arguments: args}
+}
+
+// toMessages converts the text and function calls of a candidate to messages.
+// Each function call becomes an assistant message with a function call, the
+// first one carrying the text. The calls are validated against the offered
+// functions.
+func toMessages(content, finishReason string, calls []*llm.FunctionCall, functions []llm.FunctionDefinition) ([]llm.Message, error) {
+	msg := llm.Message{
+		Role:         llm.RoleAssistant,
+		Content:      content,
+		FinishReason: finishReason,
+		Completed:    true,
+	}
+	if len(calls) == 0 {
+		return []llm.Message{msg}, nil
+	}
+
+	msgs := make([]llm.Message, 0, len(calls))
+	for i, call := range calls {
+		if err := llm.ValidateFunctionCall(call, functions); err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			msg.Content = ""
+		}
+		msg.FunctionCall = call
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// blocked returns an error when the prompt or a candidate of the response was
+// blocked by the safety filters or the content policies.
+func (c *Client) blocked(res generateContentResponse) error {
+	reason, subject := "", ""
+	if res.PromptFeedback != nil && res.PromptFeedback.BlockReason != "" {
+		reason, subject = res.PromptFeedback.BlockReason, "prompt"
+	}
+	for _, cand := range res.Candidates {
+		if reason != "" {
+			break
+		}
+		if slices.Contains(filteredReasons, cand.FinishReason) || slices.Contains(notAllowedReasons, cand.FinishReason) {
+			reason, subject = cand.FinishReason, "answer"
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+
+	err := llm.ErrContentFiltered
+	if slices.Contains(notAllowedReasons, reason) {
+		err = llm.ErrContentNotAllowed
+	}
+
+	message := fmt.Sprintf("the %s was blocked: %s", subject, reason)
+	return llm.NewLLMError(fmt.Errorf("%w: %s", err, message), string(llm.Gemini), c.cfg.Model.Name).
+		WithErrorCode(reason).
+		WithErrorMessage(message)
+}
+
+// apiError converts an error returned by the API to our error types.
+func (c *Client) apiError(statusCode int, status, message string) error {
+	llmErr := llm.NewLLMError(errors.New(message), string(llm.Gemini), c.cfg.Model.Name).
+		WithStatusCode(statusCode).
+		WithErrorCode(status).
+		WithErrorMessage(message)
+
+	switch {
+	case status == StatusResourceExhausted || statusCode == http.StatusTooManyRequests:
+		llmErr.Err = llm.ErrRateLimited
+		llmErr.Retryable = true
+	case strings.Contains(message, "API key not valid") || statusCode == http.StatusUnauthorized:
+		llmErr.Err = llm.ErrInvalidAPIKey
+	case status == StatusPermissionDenied || statusCode == http.StatusForbidden:
+		llmErr.Err = llm.ErrAuthenticationFailed
+	case status == StatusNotFound || statusCode == http.StatusNotFound:
+		llmErr.Err = llm.ErrModelNotFound
+	case status == StatusInvalidArgument && strings.Contains(message, "exceeds the maximum number of tokens"):
+		llmErr.Err = llm.ErrContextLengthExceeded
+	case status == StatusInvalidArgument || status == StatusFailedPrecondition:
+		llmErr.Err = fmt.Errorf("%w: %s", llm.ErrInvalidArguments, message)
+	case status == StatusDeadlineExceeded || statusCode == http.StatusGatewayTimeout:
+		llmErr.Err = llm.ErrTimeout
+		llmErr.Retryable = true
+	case status == StatusUnavailable || status == StatusInternal || statusCode >= 500:
+		llmErr.Err = llm.ErrServiceUnavailable
+		llmErr.Retryable = true
+	}
+
+	return llmErr
+}
+
+// handleError converts transport errors to our error types.
+func (c *Client) handleError(err error) error {
+	llmErr := llm.NewLLMError(err, string(llm.Gemini), c.cfg.Model.Name)
+
+	var netErr interface{ Timeout() bool }
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		llmErr.Err = fmt.Errorf("%w: %w", llm.ErrTimeout, err)
+		llmErr.Retryable = true
+	}
+	return llmErr
+}
+
+func init() {
+	llm.RegisterLLM(New, []llm.Model{
+		ModelGeminiFlash,
+		ModelGeminiPro,
+	})
+}
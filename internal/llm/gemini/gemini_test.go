@@ -0,0 +1,266 @@
+package gemini
+
+import (
+	"coda/internal/config"
+	"coda/internal/llm"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestClient returns a client of an httptest stand-in of the Generative
+// Language API answering with handler, and the requests it received.
+func newTestClient(t *testing.T, handler http.HandlerFunc) (llm.LLM, *[]generateContentRequest) {
+	t.Helper()
+
+	var requests []generateContentRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/v1beta/models/gemini-2.0-flash:") || r.Header.Get("X-Goog-Api-Key") != "test-key" {
+			t.Errorf("unexpected request %s %s with headers %v", r.Method, r.URL.Path, r.Header)
+		}
+
+		var req generateContentRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		requests = append(requests, req)
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := New(llm.Config{
+		Model:      ModelGeminiFlash,
+		APIKeyFunc: func() string { return "test-key" },
+		LLMConfig:  config.LLM{Gemini: config.Gemini{APIKey: "test-key", BaseURL: srv.URL}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return client, &requests
+}
+
+// respond returns a handler writing the body with the status code.
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	}
+}
+
+// sse returns a handler streaming the events.
+func sse(events ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("alt") != "sse" {
+			http.Error(w, "streams must be requested as server-sent events", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			fmt.Fprintf(w, "data: %s\r\n\r\n", e)
+		}
+	}
+}
+
+var readFile = llm.FunctionDefinition{
+	Name:        "read_file",
+	Description: "Read a file",
+	Parameters:  map[string]any{"type": "object", "properties": map[string]any{"path": map[string]any{"type": "string"}}},
+}
+
+func TestComplete(t *testing.T) {
+	client, requests := newTestClient(t, respond(http.StatusOK, `{
+		"candidates": [{"content": {"role": "model", "parts": [{"text": "Hello"}]}, "finishReason": "STOP"}],
+		"usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 2, "totalTokenCount": 12},
+		"modelVersion": "gemini-2.0-flash",
+		"responseId": "resp_1"
+	}`))
+
+	res, err := client.Complete(context.Background(), llm.CompleteParams{
+		Messages: []llm.Message{
+			llm.NewSystemMessage("Be brief."),
+			llm.NewUserMessage("Hi"),
+			llm.NewAssistantMessage("Hello, how can I help?"),
+			llm.NewUserMessage("Say hello"),
+		},
+		JSONMode: true,
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	req := (*requests)[0]
+	if req.SystemInstruction == nil || req.SystemInstruction.Parts[0].Text != "Be brief." {
+		t.Errorf("system instruction = %+v", req.SystemInstruction)
+	}
+	var roles []string
+	for _, c := range req.Contents {
+		roles = append(roles, c.Role)
+	}
+	if got := strings.Join(roles, ","); got != "user,model,user" {
+		t.Errorf("roles = %s, want user,model,user", got)
+	}
+	if req.GenerationConfig == nil || req.GenerationConfig.ResponseMIMEType != "application/json" {
+		t.Errorf("generation config = %+v", req.GenerationConfig)
+	}
+
+	if got := res.Messages[0]; got.Content != "Hello" || got.Role != llm.RoleAssistant || got.FinishReason != "STOP" {
+		t.Errorf("message = %+v", got)
+	}
+	if res.Usage.PromptTokens != 10 || res.Usage.CompletionTokens != 2 || res.Usage.TotalTokens != 12 {
+		t.Errorf("usage = %+v", res.Usage)
+	}
+	if res.Metadata.CompletionID != "resp_1" {
+		t.Errorf("completion ID = %q, want resp_1", res.Metadata.CompletionID)
+	}
+}
+
+func TestCompleteFunctionCall(t *testing.T) {
+	client, requests := newTestClient(t, respond(http.StatusOK, `{
+		"candidates": [{"content": {"role": "model", "parts": [
+			{"text": "Let me check."},
+			{"functionCall": {"name": "read_file", "args": {"path": "util.go"}}}
+		]}, "finishReason": "STOP"}]
+	}`))
+
+	call := &llm.FunctionCall{Name: "read_file", Arguments: `{"path": "main.go"}`}
+	res, err := client.Complete(context.Background(), llm.CompleteParams{
+		Messages: []llm.Message{
+			llm.NewUserMessage("Review main.go"),
+			{Role: llm.RoleAssistant, FunctionCall: call},
+			llm.NewFunctionResultMessage(call, "package main"),
+		},
+		Functions: []llm.FunctionDefinition{readFile},
+		JSONMode:  true,
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	req := (*requests)[0]
+	if len(req.Tools) != 1 || req.Tools[0].FunctionDeclarations[0].Name != "read_file" {
+		t.Errorf("tools = %+v", req.Tools)
+	}
+	if req.GenerationConfig != nil && req.GenerationConfig.ResponseMIMEType != "" {
+		t.Errorf("response MIME type = %q, want none with functions", req.GenerationConfig.ResponseMIMEType)
+	}
+	if len(req.Contents) != 3 || req.Contents[1].Parts[0].FunctionCall == nil || req.Contents[2].Role != "user" {
+		t.Fatalf("contents = %+v", req.Contents)
+	}
+	if got := req.Contents[2].Parts[0].FunctionResponse; got == nil || got.Name != "read_file" || string(got.Response) != `{"content":"package main"}` {
+		t.Errorf("function response = %+v", got)
+	}
+
+	msg := res.Messages[0]
+	if msg.Content != "Let me check." || msg.FunctionCall == nil || msg.FunctionCall.Arguments != `{"path": "util.go"}` {
+		t.Errorf("message = %+v, call = %+v", msg, msg.FunctionCall)
+	}
+}
+
+func TestStream(t *testing.T) {
+	client, _ := newTestClient(t, sse(
+		`{"candidates": [{"content": {"role": "model", "parts": [{"text": "Hel"}]}}], "usageMetadata": {"promptTokenCount": 10}, "responseId": "resp_2"}`,
+		`{"candidates": [{"content": {"role": "model", "parts": [{"text": "lo"}]}, "finishReason": "STOP"}], "usageMetadata": {"promptTokenCount": 10, "candidatesTokenCount": 2, "totalTokenCount": 12}}`,
+	))
+
+	var deltas []string
+	res, err := client.Stream(context.Background(), llm.CompleteParams{
+		Messages: []llm.Message{llm.NewUserMessage("Hi")},
+	}, func(chunk llm.StreamChunk) error {
+		deltas = append(deltas, chunk.Delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+
+	if got := strings.Join(deltas, ""); got != "Hello" {
+		t.Errorf("deltas = %q, want Hello", got)
+	}
+	if res.Messages[0].Content != "Hello" || res.Metadata.FinishReason != "STOP" || res.Metadata.CompletionID != "resp_2" {
+		t.Errorf("response = %+v", res)
+	}
+	if res.Usage.TotalTokens != 12 {
+		t.Errorf("usage = %+v", res.Usage)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		handler       http.HandlerFunc
+		stream        bool
+		wantErr       error
+		wantRetryable bool
+	}{
+		{
+			name:    "PromptBlocked",
+			handler: respond(http.StatusOK, `{"promptFeedback": {"blockReason": "SAFETY"}}`),
+			wantErr: llm.ErrContentFiltered,
+		},
+		{
+			name:    "AnswerProhibited",
+			handler: respond(http.StatusOK, `{"candidates": [{"content": {"parts": []}, "finishReason": "PROHIBITED_CONTENT"}]}`),
+			wantErr: llm.ErrContentNotAllowed,
+		},
+		{
+			name:    "StreamBlocked",
+			handler: sse(`{"candidates": [{"content": {"parts": [{"text": "Sure"}]}, "finishReason": "SAFETY"}]}`),
+			stream:  true,
+			wantErr: llm.ErrContentFiltered,
+		},
+		{
+			name:          "ResourceExhausted",
+			handler:       respond(http.StatusTooManyRequests, `{"error": {"code": 429, "message": "Resource has been exhausted", "status": "RESOURCE_EXHAUSTED"}}`),
+			wantErr:       llm.ErrRateLimited,
+			wantRetryable: true,
+		},
+		{
+			name:    "InvalidAPIKey",
+			handler: respond(http.StatusBadRequest, `{"error": {"code": 400, "message": "API key not valid. Please pass a valid API key.", "status": "INVALID_ARGUMENT"}}`),
+			wantErr: llm.ErrInvalidAPIKey,
+		},
+		{
+			name:    "PromptTooLong",
+			handler: respond(http.StatusBadRequest, `{"error": {"code": 400, "message": "The input token count (2100000) exceeds the maximum number of tokens allowed (1048576).", "status": "INVALID_ARGUMENT"}}`),
+			wantErr: llm.ErrContextLengthExceeded,
+		},
+		{
+			name:          "Unavailable",
+			handler:       respond(http.StatusServiceUnavailable, `{"error": {"code": 503, "message": "The model is overloaded.", "status": "UNAVAILABLE"}}`),
+			wantErr:       llm.ErrServiceUnavailable,
+			wantRetryable: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, _ := newTestClient(t, tt.handler)
+			params := llm.CompleteParams{Messages: []llm.Message{llm.NewUserMessage("Hi")}}
+
+			var err error
+			if tt.stream {
+				_, err = client.Stream(context.Background(), params, func(llm.StreamChunk) error { return nil })
+			} else {
+				_, err = client.Complete(context.Background(), params)
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			var llmErr *llm.LLMError
+			if !errors.As(err, &llmErr) || llmErr.Provider != string(llm.Gemini) {
+				t.Fatalf("error = %#v, want a Gemini LLMError", err)
+			}
+			if llm.IsRetryable(err) != tt.wantRetryable {
+				t.Errorf("IsRetryable() = %v, want %v", llm.IsRetryable(err), tt.wantRetryable)
+			}
+		})
+	}
+}
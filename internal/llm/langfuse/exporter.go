@@ -0,0 +1,354 @@
+package langfuse
+
+import (
+	"coda/internal/config"
+	"coda/internal/logger"
+	"coda/internal/metrics"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Default settings of the exporter
+const (
+	DefaultQueueSize      = 1000
+	DefaultBatchSize      = 100
+	DefaultFlushInterval  = 5 * time.Second
+	DefaultMaxRetries     = 3
+	DefaultInitialBackoff = 500 * time.Millisecond
+	DefaultMaxBackoff     = 10 * time.Second
+)
+
+// exporterConfig configures the batching and retries of an Exporter.
+// Unset fields take the default values.
+type exporterConfig struct {
+	QueueSize      int           // Events waiting to be sent, newer events are dropped when full
+	BatchSize      int           // Events sent per request
+	FlushInterval  time.Duration // Maximum time an event waits to be sent
+	MaxRetries     int           // Retries of a batch after a server error or rate limit
+	InitialBackoff time.Duration // Wait before the first retry, doubled after each retry
+	MaxBackoff     time.Duration // Maximum wait between retries
+}
+
+// ExporterStats counts the events handled by an Exporter.
+type ExporterStats struct {
+	Enqueued int64 // Events accepted in the queue
+	Sent     int64 // Events ingested by Langfuse
+	Dropped  int64 // Events dropped because the queue was full or the exporter stopped
+	Failed   int64 // Events rejected by Langfuse or still failing after the retries
+	Retried  int64 // Events sent again after a failure
+}
+
+// ingester sends batches of events to Langfuse.
+type ingester interface {
+	IngestContext(ctx context.Context, batch []Event) (*IngestionResponse, error)
+}
+
+// Exporter sends events to Langfuse in the background. Events are queued
+// without blocking the caller and sent in batches, when a batch is full or
+// the flush interval elapses. Batches failing with a server error or a rate
+// limit are retried with an exponential backoff, as are the events of a batch
+// Langfuse failed to ingest for the same reasons.
+type Exporter struct {
+	client ingester
+	cfg    exporterConfig
+	queue  chan Event
+
+	ctx    context.Context // Canceled when the shutdown deadline is exceeded
+	cancel context.CancelFunc
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+
+	stopped                                  atomic.Bool
+	enqueued, sent, dropped, failed, retried atomic.Int64
+}
+
+// NewExporter creates an Exporter sending the events to the configured Langfuse
+// project and starts sending them. Shutdown must be called to send the
+// remaining events.
+func NewExporter(cfg *config.Config) *Exporter {
+	return newExporter(NewClient(cfg), exporterConfig{
+		QueueSize:     cfg.LLM.Langfuse.QueueSize,
+		BatchSize:     cfg.LLM.Langfuse.BatchSize,
+		FlushInterval: cfg.LLM.Langfuse.FlushInterval,
+	})
+}
+
+func newExporter(client ingester, cfg exporterConfig) *Exporter {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = DefaultQueueSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = DefaultFlushInterval
+	}
+	if cfg.MaxRetries < 0 {
+		cfg.MaxRetries = 0
+	} else if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = DefaultMaxRetries
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = DefaultInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Exporter{
+		client: client,
+		cfg:    cfg,
+		queue:  make(chan Event, cfg.QueueSize),
+		ctx:    ctx,
+		cancel: cancel,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// Enqueue queues the events to be sent. Events are dropped rather than
+// blocking the caller when the queue is full. A nil Exporter drops the events,
+// so that callers need not check whether Langfuse is configured.
+func (e *Exporter) Enqueue(events ...Event) {
+	if e == nil {
+		return
+	}
+
+	if e.stopped.Load() {
+		e.dropped.Add(int64(len(events)))
+		return
+	}
+
+	for i, event := range events {
+		select {
+		case e.queue <- event:
+			e.enqueued.Add(1)
+		default:
+			// Keep the queue bounded: the events that do not fit are lost.
+			// Only the first drop is logged, the stats count the others.
+			n := len(events) - i
+			if e.dropped.Add(int64(n)) == int64(n) {
+				logger.Warn(e.ctx, "Langfuse queue is full, dropping events", "queue_size", e.cfg.QueueSize)
+			}
+			return
+		}
+	}
+}
+
+// Stats returns the counts of the events handled so far.
+func (e *Exporter) Stats() ExporterStats {
+	if e == nil {
+		return ExporterStats{}
+	}
+	return ExporterStats{
+		Enqueued: e.enqueued.Load(),
+		Sent:     e.sent.Load(),
+		Dropped:  e.dropped.Load(),
+		Failed:   e.failed.Load(),
+		Retried:  e.retried.Load(),
+	}
+}
+
+// RegisterMetrics registers the counts of the events handled by the exporter
+// and the length of its queue.
+func (e *Exporter) RegisterMetrics(reg prometheus.Registerer) error {
+	counter := func(name, help string, value func(ExporterStats) int64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: metrics.Namespace, Subsystem: "langfuse", Name: name, Help: help,
+		}, func() float64 {
+			return float64(value(e.Stats()))
+		})
+	}
+
+	for _, c := range []prometheus.Collector{
+		counter("events_enqueued_total", "Events accepted in the queue.", func(s ExporterStats) int64 { return s.Enqueued }),
+		counter("events_sent_total", "Events ingested by Langfuse.", func(s ExporterStats) int64 { return s.Sent }),
+		counter("events_dropped_total", "Events dropped because the queue was full or the exporter stopped.", func(s ExporterStats) int64 { return s.Dropped }),
+		counter("events_failed_total", "Events rejected by Langfuse or still failing after the retries.", func(s ExporterStats) int64 { return s.Failed }),
+		counter("events_retried_total", "Events sent again after a failure.", func(s ExporterStats) int64 { return s.Retried }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metrics.Namespace, Subsystem: "langfuse", Name: "queue_length",
+			Help: "Events waiting to be sent.",
+		}, func() float64 {
+			return float64(len(e.queue))
+		}),
+	} {
+		if err := reg.Register(c); err != nil {
+			return fmt.Errorf("registering Langfuse metrics: %w", err)
+		}
+	}
+	return nil
+}
+
+// Shutdown stops accepting events and sends the queued ones. When ctx is done
+// first, the pending requests are canceled, the remaining events dropped and
+// the context error returned.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	if e == nil {
+		return nil
+	}
+
+	e.once.Do(func() {
+		e.stopped.Store(true)
+		close(e.stop)
+	})
+
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		e.cancel()
+		<-e.done
+		return ctx.Err()
+	}
+}
+
+// run batches the queued events until the exporter stops, then sends the
+// events left in the queue.
+func (e *Exporter) run() {
+	defer close(e.done)
+	defer e.cancel()
+
+	ticker := time.NewTicker(e.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Event, 0, e.cfg.BatchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			e.send(batch)
+			batch = make([]Event, 0, e.cfg.BatchSize)
+		}
+	}
+
+	for {
+		select {
+		case event := <-e.queue:
+			batch = append(batch, event)
+			if len(batch) >= e.cfg.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.stop:
+			for {
+				select {
+				case event := <-e.queue:
+					batch = append(batch, event)
+					if len(batch) >= e.cfg.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send sends a batch, retrying it, or the events Langfuse failed to ingest,
+// while the failures are temporary.
+func (e *Exporter) send(batch []Event) {
+	wait := e.cfg.InitialBackoff
+
+	for retry := 0; ; retry++ {
+		if e.ctx.Err() != nil {
+			// The shutdown deadline is exceeded
+			e.dropped.Add(int64(len(batch)))
+			return
+		}
+
+		resp, err := e.client.IngestContext(e.ctx, batch)
+		if err == nil {
+			batch = e.ingested(batch, resp)
+			if len(batch) == 0 {
+				return
+			}
+			err = errPartialFailure
+		}
+
+		if !isRetryable(err) || retry >= e.cfg.MaxRetries {
+			logger.Error(e.ctx, "failed to send events to Langfuse", "err", err, "events", len(batch), "attempts", retry+1)
+			e.failed.Add(int64(len(batch)))
+			return
+		}
+
+		e.retried.Add(int64(len(batch)))
+		select {
+		case <-time.After(wait):
+		case <-e.ctx.Done():
+		}
+		wait = min(wait*2, e.cfg.MaxBackoff)
+	}
+}
+
+// errPartialFailure reports that Langfuse failed to ingest some events of a
+// batch for temporary reasons.
+var errPartialFailure = errors.New("some events were not ingested")
+
+// ingested counts the events of the batch Langfuse ingested or rejected, and
+// returns the ones to send again.
+func (e *Exporter) ingested(batch []Event, resp *IngestionResponse) []Event {
+	if len(resp.Errors) == 0 {
+		e.sent.Add(int64(len(batch)))
+		return nil
+	}
+
+	failed := make(map[string]bool, len(resp.Errors))
+	rejected := 0
+	for _, r := range resp.Errors {
+		if isRetryableStatus(r.Status) {
+			failed[r.ID] = true
+			continue
+		}
+		rejected++
+		logger.Error(e.ctx, "Langfuse rejected event", "id", r.ID, "status", r.Status, "message", r.Message, "error", r.Error)
+	}
+	e.failed.Add(int64(rejected))
+	e.sent.Add(int64(len(batch) - rejected - len(failed)))
+
+	var retry []Event
+	for _, event := range batch {
+		if failed[event.ID] {
+			retry = append(retry, event)
+		}
+	}
+	return retry
+}
+
+// isRetryable checks whether sending a batch failed for a temporary reason:
+// a server error, a rate limit, a transport error or a partial failure.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var (
+		statusErr *StatusError
+		urlErr    *url.Error
+	)
+	switch {
+	case errors.As(err, &statusErr):
+		return isRetryableStatus(statusErr.StatusCode)
+	case errors.As(err, &urlErr):
+		return true
+	default:
+		return errors.Is(err, errPartialFailure)
+	}
+}
+
+// isRetryableStatus checks whether a status code reports a temporary failure.
+func isRetryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
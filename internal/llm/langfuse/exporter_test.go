@@ -0,0 +1,190 @@
+package langfuse
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeIngester records the batches it receives and answers with the scripted
+// responses, then with successes.
+type fakeIngester struct {
+	mu        sync.Mutex
+	batches   [][]Event
+	responses []func(batch []Event) (*IngestionResponse, error)
+	block     chan struct{} // Blocks the requests until closed, if not nil
+}
+
+func (f *fakeIngester) IngestContext(ctx context.Context, batch []Event) (*IngestionResponse, error) {
+	if f.block != nil {
+		select {
+		case <-f.block:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.batches = append(f.batches, batch)
+	if len(f.responses) > 0 {
+		respond := f.responses[0]
+		f.responses = f.responses[1:]
+		return respond(batch)
+	}
+	return &IngestionResponse{}, nil
+}
+
+func (f *fakeIngester) sizes() []int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var sizes []int
+	for _, b := range f.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+func events(n int) []Event {
+	var events []Event
+	for i := range n {
+		events = append(events, Event{ID: fmt.Sprintf("event-%d", i), Type: "trace-create"})
+	}
+	return events
+}
+
+func failWith(status int) func([]Event) (*IngestionResponse, error) {
+	return func([]Event) (*IngestionResponse, error) {
+		return nil, &StatusError{StatusCode: status}
+	}
+}
+
+func TestExporter(t *testing.T) {
+	partialFailure := func(batch []Event) (*IngestionResponse, error) {
+		var resp IngestionResponse
+		err := json.Unmarshal(fmt.Appendf(nil, `{
+			"successes": [],
+			"errors": [{"id": %q, "status": 500}, {"id": %q, "status": 400, "message": "invalid body"}]
+		}`, batch[0].ID, batch[1].ID), &resp)
+		return &resp, err
+	}
+
+	tests := []struct {
+		name      string
+		events    int
+		responses []func([]Event) (*IngestionResponse, error)
+		wantSizes []int
+		wantStats ExporterStats
+	}{
+		{
+			name:      "Batches",
+			events:    5,
+			wantSizes: []int{2, 2, 1},
+			wantStats: ExporterStats{Enqueued: 5, Sent: 5},
+		},
+		{
+			name:      "RetriesServerErrors",
+			events:    2,
+			responses: []func([]Event) (*IngestionResponse, error){failWith(http.StatusServiceUnavailable), failWith(http.StatusTooManyRequests)},
+			wantSizes: []int{2, 2, 2},
+			wantStats: ExporterStats{Enqueued: 2, Sent: 2, Retried: 4},
+		},
+		{
+			name:      "GivesUpAfterRetries",
+			events:    2,
+			responses: []func([]Event) (*IngestionResponse, error){failWith(http.StatusInternalServerError), failWith(http.StatusBadGateway), failWith(http.StatusGatewayTimeout)},
+			wantSizes: []int{2, 2, 2},
+			wantStats: ExporterStats{Enqueued: 2, Failed: 2, Retried: 4},
+		},
+		{
+			name:      "DoesNotRetryClientErrors",
+			events:    2,
+			responses: []func([]Event) (*IngestionResponse, error){failWith(http.StatusUnauthorized)},
+			wantSizes: []int{2},
+			wantStats: ExporterStats{Enqueued: 2, Failed: 2},
+		},
+		{
+			name:      "RetriesPartialFailures",
+			events:    2,
+			responses: []func([]Event) (*IngestionResponse, error){partialFailure},
+			wantSizes: []int{2, 1},
+			wantStats: ExporterStats{Enqueued: 2, Sent: 1, Failed: 1, Retried: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingester := &fakeIngester{responses: tt.responses}
+			e := newExporter(ingester, exporterConfig{
+				BatchSize:      2,
+				FlushInterval:  time.Hour,
+				MaxRetries:     2,
+				InitialBackoff: time.Millisecond,
+			})
+
+			e.Enqueue(events(tt.events)...)
+			if err := e.Shutdown(context.Background()); err != nil {
+				t.Fatalf("Shutdown() error = %v", err)
+			}
+
+			if got := fmt.Sprint(ingester.sizes()); got != fmt.Sprint(tt.wantSizes) {
+				t.Errorf("batch sizes = %s, want %v", got, tt.wantSizes)
+			}
+			if got := e.Stats(); got != tt.wantStats {
+				t.Errorf("stats = %+v, want %+v", got, tt.wantStats)
+			}
+		})
+	}
+}
+
+func TestExporterFlushInterval(t *testing.T) {
+	ingester := &fakeIngester{}
+	e := newExporter(ingester, exporterConfig{BatchSize: 10, FlushInterval: 10 * time.Millisecond})
+	defer e.Shutdown(context.Background())
+
+	e.Enqueue(events(1)...)
+
+	deadline := time.Now().Add(time.Second)
+	for len(ingester.sizes()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("event not sent after the flush interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestExporterBackpressure(t *testing.T) {
+	ingester := &fakeIngester{block: make(chan struct{})}
+	e := newExporter(ingester, exporterConfig{QueueSize: 2, BatchSize: 1, FlushInterval: time.Hour})
+
+	// The first event is taken from the queue and blocks the exporter, the
+	// next two fill the queue and the others are dropped
+	e.Enqueue(events(1)...)
+	deadline := time.Now().Add(time.Second)
+	for len(e.queue) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("event not taken from the queue")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	e.Enqueue(events(4)...)
+
+	if got := e.Stats(); got.Enqueued != 3 || got.Dropped != 2 {
+		t.Errorf("stats = %+v, want 3 enqueued and 2 dropped", got)
+	}
+
+	// Events not sent by the shutdown deadline are dropped
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := e.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	e.Enqueue(events(1)...)
+	if got := e.Stats(); got.Sent != 0 || got.Dropped != 5 {
+		t.Errorf("stats = %+v, want 0 sent and 5 dropped", got)
+	}
+}
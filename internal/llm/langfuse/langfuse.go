@@ -3,6 +3,7 @@ package langfuse
 import (
 	"bytes"
 	"coda/internal/config"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
@@ -71,8 +72,22 @@ type IngestionResponse struct {
 	} `json:"errors"`
 }
 
+// StatusError is returned by Ingest when the ingestion API answers with an unexpected status code
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
 // Ingest sends a batch of events to the Langfuse ingestion API
 func (c *Client) Ingest(batch []Event) (*IngestionResponse, error) {
+	return c.IngestContext(context.Background(), batch)
+}
+
+// IngestContext sends a batch of events to the Langfuse ingestion API, canceling the request with ctx
+func (c *Client) IngestContext(ctx context.Context, batch []Event) (*IngestionResponse, error) {
 	req := BatchRequest{
 		Batch: batch,
 	}
@@ -83,7 +98,7 @@ func (c *Client) Ingest(batch []Event) (*IngestionResponse, error) {
 	}
 
 	url := fmt.Sprintf("%s/api/public/ingestion", c.apiURL)
-	httpReq, err := http.NewRequest("POST", url, bytes.NewBuffer(reqBody))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqBody))
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %w", err)
 	}
@@ -100,7 +115,7 @@ func (c *Client) Ingest(batch []Event) (*IngestionResponse, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusMultiStatus && resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		return nil, &StatusError{StatusCode: resp.StatusCode}
 	}
 
 	var ingestionResp IngestionResponse
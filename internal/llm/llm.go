@@ -4,6 +4,7 @@ import (
 	"coda/internal/config"
 	"context"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 )
@@ -12,6 +13,22 @@ import (
 type LLM interface {
 	// Complete processes the given parameters and returns a completion response.
 	Complete(ctx context.Context, params CompleteParams) (*CompleteResponse, error)
+
+	// Stream processes the given parameters, delivering incremental deltas to fn
+	// as they are generated, and returns the aggregated completion response.
+	Stream(ctx context.Context, params CompleteParams, fn StreamFunc) (*CompleteResponse, error)
+}
+
+// StreamFunc is called for each chunk of a streamed completion.
+// Returning an error aborts the stream and is returned to the caller.
+type StreamFunc func(chunk StreamChunk) error
+
+// StreamChunk is an incremental delta of a streamed completion.
+type StreamChunk struct {
+	// Delta is the content generated since the previous chunk
+	Delta string
+	// FinishReason is set on the final chunk of the stream
+	FinishReason string
 }
 
 // ModelInfo provides metadata about a language model.
@@ -38,6 +55,9 @@ type CompleteParams struct {
 	Stream      bool
 	Functions   []FunctionDefinition `json:"functions,omitempty"`
 	JSONMode    bool                 `json:"json_mode,omitempty"`
+	// ResponseSchema is a JSON schema the output of a JSON mode completion must
+	// conform to. It is honored by the providers supporting structured outputs.
+	ResponseSchema any `json:"response_schema,omitempty"`
 }
 
 // FunctionDefinition defines a function that can be called by the model.
@@ -63,6 +83,22 @@ type CompletionMetadata struct {
 	LatencyMs     int64
 	ProcessedAt   time.Time
 	RequestTokens int
+	// Timings is reported by providers running the model themselves
+	Timings *Timings
+	// Cost is estimated from the usage and the model pricing, when both are known
+	Cost *Cost
+	// CacheHit is set when the completion was answered from the cache, at no cost
+	CacheHit bool
+}
+
+// Timings breaks down the time a model spent on a completion.
+type Timings struct {
+	Load                  time.Duration // Loading the model into memory
+	PromptEval            time.Duration // Processing the prompt
+	Eval                  time.Duration // Generating the completion
+	Total                 time.Duration // Whole request on the server
+	PromptTokensPerSecond float64       // Prompt processing throughput
+	TokensPerSecond       float64       // Generation throughput
 }
 
 // Usage contains token usage information.
@@ -79,59 +115,148 @@ type APIKeyFunc func() string
 // Registry of supported models
 var (
 	modelRegistryMu sync.RWMutex
-	supportedModels = map[Model]SupportedModels{}
+	constructors    = map[Provider]Constructor{}
+	builtinModels   []Model
 )
 
-// SupportedModels contains information about a supported model.
-type SupportedModels struct {
-	Constructor func(Config) (LLM, error)
-	Model       Model
-}
-
 // Constructor is a function that creates a new LLM instance.
 type Constructor func(cfg Config) (LLM, error)
 
 // Registry contains a list of available models.
 type Registry struct {
-	models []Model
+	cfg        *config.Config
+	mu         sync.RWMutex
+	builtin    []Model
+	configured []Model
+	discovered map[Provider][]Model
+	models     []Model
+	cancel     context.CancelFunc
+	done       chan struct{}
 }
 
 // NewRegistry initializes a new model registry with the given configuration.
-func NewRegistry(cfg *config.Config) *Registry {
+// The catalog consists of the built-in models registered by the providers,
+// the models discovered from the provider servers and the models declared in
+// the configuration, in increasing order of precedence.
+func NewRegistry(cfg *config.Config) (*Registry, error) {
 	modelRegistryMu.RLock()
 	defer modelRegistryMu.RUnlock()
 
-	models := make([]Model, 0, len(supportedModels))
-	for model := range supportedModels {
-		switch model.Provider {
-		case OpenAI:
-			models = append(models, model)
-		case Ollama:
-			if !cfg.LLM.Ollama.IsConfigured() {
+	configured := make([]Model, 0, len(cfg.LLM.Models))
+	for _, mc := range cfg.LLM.Models {
+		model := modelFromConfig(mc)
+		if _, ok := constructors[model.Provider]; !ok {
+			return nil, fmt.Errorf("model %q: provider %q is not supported", model.Name, model.Provider)
+		}
+		configured = append(configured, model)
+	}
+
+	endpoints := make(map[string]bool, len(cfg.LLM.Endpoints))
+	for _, ec := range cfg.LLM.Endpoints {
+		if endpoints[ec.Name] {
+			return nil, fmt.Errorf("endpoint %q is declared more than once", ec.Name)
+		}
+		endpoints[ec.Name] = true
+		for _, mc := range ec.Models {
+			configured = append(configured, EndpointModel(ec.Name, modelFromConfig(mc)))
+		}
+	}
+
+	// Azure models are requested by the name of their deployment on the resource
+	for _, mc := range cfg.LLM.Azure.Deployments {
+		model := modelFromConfig(mc)
+		model.Provider = Azure
+		if mc.DisplayName == "" {
+			model.DisplayName = fmt.Sprintf("%s (Azure)", mc.Name)
+		}
+		configured = append(configured, model)
+	}
+
+	r := &Registry{
+		cfg:        cfg,
+		builtin:    append([]Model(nil), builtinModels...),
+		configured: configured,
+		discovered: map[Provider][]Model{},
+	}
+	r.rebuild()
+
+	return r, nil
+}
+
+// Models returns the models in the registry.
+func (r *Registry) Models() []Model {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.models
+}
+
+// rebuild merges the model sources into the catalog.
+// The caller must hold the write lock or have exclusive access to the registry.
+func (r *Registry) rebuild() {
+	models := append([]Model(nil), r.builtin...)
+
+	// Discovered models refine the metadata of built-in models
+	providers := make([]Provider, 0, len(r.discovered))
+	for provider := range r.discovered {
+		providers = append(providers, provider)
+	}
+	slices.Sort(providers)
+	for _, provider := range providers {
+		for _, model := range r.discovered[provider] {
+			if i := indexOfModel(models, model); i >= 0 {
+				models[i] = mergeDiscoveredModel(models[i], model)
 				continue
 			}
 			models = append(models, model)
 		}
 	}
 
-	return &Registry{
-		models: models,
+	// Configured models replace everything else
+	for _, model := range r.configured {
+		if i := indexOfModel(models, model); i >= 0 {
+			models[i] = model
+			continue
+		}
+		models = append(models, model)
+	}
+
+	// Only expose models whose provider is configured
+	available := models[:0]
+	for _, model := range models {
+		if !isProviderConfigured(r.cfg, model.Provider) {
+			continue
+		}
+		available = append(available, model)
 	}
+
+	r.models = available
 }
 
-// RegisterLLM registers models with their constructor function.
+// RegisterLLM registers the constructor for a provider along with its built-in models.
 func RegisterLLM(constructor Constructor, models []Model) {
 	modelRegistryMu.Lock()
 	defer modelRegistryMu.Unlock()
 
 	for _, model := range models {
-		supportedModels[model] = SupportedModels{
-			Constructor: constructor,
-			Model:       model,
+		constructors[model.Provider] = constructor
+		if i := indexOfModel(builtinModels, model); i >= 0 {
+			builtinModels[i] = model
+			continue
 		}
+		builtinModels = append(builtinModels, model)
 	}
 }
 
+// RegisterProvider registers the constructor for a provider without built-in models,
+// whose models are declared in the configuration or discovered.
+func RegisterProvider(provider Provider, constructor Constructor) {
+	modelRegistryMu.Lock()
+	defer modelRegistryMu.Unlock()
+
+	constructors[provider] = constructor
+}
+
 // New creates a new LLM instance for the specified model.
 func New(cfg Config) (LLM, error) {
 	if cfg.APIKeyFunc == nil {
@@ -139,12 +264,40 @@ func New(cfg Config) (LLM, error) {
 	}
 
 	modelRegistryMu.RLock()
-	supportedModel, ok := supportedModels[cfg.Model]
+	constructor, ok := constructors[cfg.Model.Provider]
 	modelRegistryMu.RUnlock()
 
 	if !ok {
 		return nil, fmt.Errorf("model %q is not supported", cfg.Model.Name)
 	}
 
-	return supportedModel.Constructor(cfg)
+	return constructor(cfg)
+}
+
+// isProviderConfigured reports whether the given provider can be used with the configuration.
+func isProviderConfigured(cfg *config.Config, provider Provider) bool {
+	switch provider {
+	case Ollama:
+		return cfg.LLM.Ollama.IsConfigured()
+	case Anthropic:
+		return cfg.LLM.Anthropic.IsConfigured()
+	case OpenAICompatible:
+		return len(cfg.LLM.Endpoints) > 0
+	case Azure:
+		return cfg.LLM.Azure.IsConfigured()
+	case Gemini:
+		return cfg.LLM.Gemini.IsConfigured()
+	default:
+		return true
+	}
+}
+
+// indexOfModel returns the index of the model with the same provider, endpoint and name, or -1.
+func indexOfModel(models []Model, target Model) int {
+	for i, model := range models {
+		if model.Provider == target.Provider && model.Endpoint == target.Endpoint && model.Name == target.Name {
+			return i
+		}
+	}
+	return -1
 }
@@ -18,6 +18,7 @@ type Message struct {
 	Content      string        `json:"content"`
 	Name         string        `json:"name,omitempty"`
 	FunctionCall *FunctionCall `json:"function_call,omitempty"`
+	CallID       string        `json:"call_id,omitempty"` // ID of the function call answered by a function message
 	FinishReason string        `json:"finish_reason,omitempty"`
 	Completed    bool          `json:"completed,omitempty"`
 	Error        *string       `json:"error,omitempty"`
@@ -68,6 +69,13 @@ func NewFunctionMessage(name string, content string) Message {
 	return msg
 }
 
+// NewFunctionResultMessage creates a function message with the result of a function call.
+func NewFunctionResultMessage(call *FunctionCall, content string) Message {
+	msg := NewFunctionMessage(call.Name, content)
+	msg.CallID = call.ID
+	return msg
+}
+
 // IsError returns true if the message is an error.
 func (m *Message) IsError() bool {
 	return m.Error != nil
@@ -153,8 +161,9 @@ func (m *Message) UnmarshalJSON(data []byte) error {
 
 // FunctionCall represents a call to a function.
 type FunctionCall struct {
+	ID        string `json:"id,omitempty"` // Provider-assigned ID, empty for providers without call IDs
 	Name      string `json:"name,omitempty"`
-	Arguments string `json:"arguments,omitempty"`
+	Arguments string `json:"arguments,omitempty"` // JSON object
 }
 
 // ParseArguments parses the arguments string into the provided struct.
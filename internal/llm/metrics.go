@@ -0,0 +1,174 @@
+package llm
+
+import (
+	"coda/internal/metrics"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics records the completions as Prometheus metrics, labeled by provider
+// and model. A nil Metrics records nothing.
+type Metrics struct {
+	attempts         *prometheus.CounterVec
+	retries          *prometheus.CounterVec
+	fallbacks        *prometheus.CounterVec
+	budgetExceeded   *prometheus.CounterVec
+	errors           *prometheus.CounterVec
+	tokens           *prometheus.CounterVec
+	cost             *prometheus.CounterVec
+	duration         *prometheus.HistogramVec
+	timeToFirstToken *prometheus.HistogramVec
+}
+
+// NewMetrics creates the completion metrics and registers them.
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	labels := []string{"provider", "model"}
+	latencyBuckets := []float64{0.25, 0.5, 1, 2.5, 5, 10, 20, 40, 80, 120}
+
+	m := &Metrics{
+		attempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace, Subsystem: "llm", Name: "attempts_total",
+			Help: "Requests sent to the models, retries included.",
+		}, labels),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace, Subsystem: "llm", Name: "retries_total",
+			Help: "Requests sent again after a retryable error.",
+		}, labels),
+		fallbacks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace, Subsystem: "llm", Name: "fallbacks_total",
+			Help: "Completions handed to a fallback model after the previous model failed, by fallback model.",
+		}, labels),
+		budgetExceeded: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace, Subsystem: "llm", Name: "budget_exceeded_total",
+			Help: "Requests over a budget limit, by limit scope and action taken.",
+		}, append(labels, "scope", "action")),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace, Subsystem: "llm", Name: "errors_total",
+			Help: "Failed requests, by error kind and provider error code.",
+		}, append(labels, "kind", "code")),
+		tokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace, Subsystem: "llm", Name: "tokens_total",
+			Help: "Tokens sent to (input) and generated by (output) the models.",
+		}, append(labels, "direction")),
+		cost: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metrics.Namespace, Subsystem: "llm", Name: "estimated_cost_total",
+			Help: "Estimated cost of the completions from the model pricing.",
+		}, append(labels, "currency")),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metrics.Namespace, Subsystem: "llm", Name: "request_duration_seconds",
+			Help:    "Duration of the requests sent to the models, by outcome.",
+			Buckets: latencyBuckets,
+		}, append(labels, "outcome")),
+		timeToFirstToken: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metrics.Namespace, Subsystem: "llm", Name: "time_to_first_token_seconds",
+			Help:    "Time until the first chunk of the streamed completions.",
+			Buckets: latencyBuckets,
+		}, labels),
+	}
+
+	for _, c := range []prometheus.Collector{
+		m.attempts, m.retries, m.fallbacks, m.budgetExceeded, m.errors, m.tokens, m.cost, m.duration, m.timeToFirstToken,
+	} {
+		if err := reg.Register(c); err != nil {
+			return nil, fmt.Errorf("registering LLM metrics: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// observeAttempt records a request sent to a model.
+func (m *Metrics) observeAttempt(a *attempt) {
+	if m == nil {
+		return
+	}
+
+	provider, model := string(a.model.Provider), a.model.Name
+	m.attempts.WithLabelValues(provider, model).Inc()
+	if a.number > 1 {
+		m.retries.WithLabelValues(provider, model).Inc()
+	}
+
+	outcome := "success"
+	if a.err != nil {
+		outcome = "error"
+		kind, code := errorLabels(a.err)
+		m.errors.WithLabelValues(provider, model, kind, code).Inc()
+	}
+	m.duration.WithLabelValues(provider, model, outcome).Observe(a.end.Sub(a.start).Seconds())
+	if !a.firstToken.IsZero() {
+		m.timeToFirstToken.WithLabelValues(provider, model).Observe(a.firstToken.Sub(a.start).Seconds())
+	}
+
+	if a.err != nil || a.res.Usage == nil {
+		return
+	}
+	usage := a.res.Usage
+	m.tokens.WithLabelValues(provider, model, "input").Add(float64(usage.PromptTokens))
+	m.tokens.WithLabelValues(provider, model, "output").Add(float64(usage.CompletionTokens))
+	if cost := a.res.Metadata.Cost; cost != nil {
+		m.cost.WithLabelValues(provider, model, cost.Currency).Add(cost.Total)
+	}
+}
+
+// observeFallback records a completion handed to a fallback model.
+func (m *Metrics) observeFallback(model Model) {
+	if m == nil {
+		return
+	}
+	m.fallbacks.WithLabelValues(string(model.Provider), model.Name).Inc()
+}
+
+// observeBudgetExceeded records a request for the model over a budget limit.
+func (m *Metrics) observeBudgetExceeded(model Model, scope, action string) {
+	if m == nil {
+		return
+	}
+	m.budgetExceeded.WithLabelValues(string(model.Provider), model.Name, scope, action).Inc()
+}
+
+// errorKinds name the errors of the metrics, the first matching kind is used.
+var errorKinds = []struct {
+	err  error
+	kind string
+}{
+	{ErrStreamInterrupted, "stream_interrupted"},
+	{ErrRateLimited, "rate_limited"},
+	{ErrTooManyRequests, "rate_limited"},
+	{ErrServiceUnavailable, "service_unavailable"},
+	{ErrModelOverloaded, "model_overloaded"},
+	{ErrTimeout, "timeout"},
+	{context.DeadlineExceeded, "timeout"},
+	{context.Canceled, "canceled"},
+	{ErrInvalidAPIKey, "authentication"},
+	{ErrAuthenticationFailed, "authentication"},
+	{ErrContextLengthExceeded, "context_length_exceeded"},
+	{ErrTokenLimitReached, "token_limit_reached"},
+	{ErrContentFiltered, "content_filtered"},
+	{ErrContentNotAllowed, "content_not_allowed"},
+	{ErrModelNotFound, "model_not_found"},
+	{ErrInvalidArguments, "invalid_arguments"},
+	{ErrInvalidFunctionCall, "invalid_function_call"},
+	{ErrNoMessages, "no_messages"},
+	{ErrBudgetExceeded, "budget_exceeded"},
+}
+
+// errorLabels returns the kind of the error and the provider error code of
+// the LLMError it wraps, if any.
+func errorLabels(err error) (kind, code string) {
+	kind = "other"
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			kind = k.kind
+			break
+		}
+	}
+
+	var llmErr *LLMError
+	if errors.As(err, &llmErr) {
+		code = llmErr.ErrorCode
+	}
+	return kind, code
+}
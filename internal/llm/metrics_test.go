@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestErrorLabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantKind string
+		wantCode string
+	}{
+		{
+			name:     "provider error",
+			err:      &LLMError{Provider: "openai", ErrorCode: "rate_limit_exceeded", Err: ErrRateLimited},
+			wantKind: "rate_limited",
+			wantCode: "rate_limit_exceeded",
+		},
+		{name: "wrapped", err: fmt.Errorf("%w: stream closed", ErrStreamInterrupted), wantKind: "stream_interrupted"},
+		{name: "unknown", err: errors.New("boom"), wantKind: "other"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, code := errorLabels(tt.err)
+			if kind != tt.wantKind || code != tt.wantCode {
+				t.Errorf("errorLabels() = (%q, %q), want (%q, %q)", kind, code, tt.wantKind, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestMetricsObserveAttempt(t *testing.T) {
+	m, err := NewMetrics(prometheus.NewRegistry())
+	if err != nil {
+		t.Fatalf("NewMetrics() error = %v", err)
+	}
+
+	model := Model{
+		Provider: OpenAI,
+		Name:     "gpt-4o",
+		Pricing:  &ModelPricing{InputPerToken: 0.01, OutputPerToken: 0.02, Currency: "USD"},
+	}
+	usage := &Usage{PromptTokens: 100, CompletionTokens: 50}
+	start := time.Now()
+	m.observeAttempt(&attempt{model: model, number: 1, start: start, end: start.Add(time.Second), err: ErrRateLimited})
+	m.observeAttempt(&attempt{
+		model: model, number: 2, start: start, end: start.Add(time.Second),
+		res: &CompleteResponse{Usage: usage, Metadata: CompletionMetadata{Cost: model.Pricing.Cost(usage)}},
+	})
+
+	tests := []struct {
+		name string
+		c    prometheus.Collector
+		want float64
+	}{
+		{name: "attempts", c: m.attempts.WithLabelValues("openai", "gpt-4o"), want: 2},
+		{name: "retries", c: m.retries.WithLabelValues("openai", "gpt-4o"), want: 1},
+		{name: "errors", c: m.errors.WithLabelValues("openai", "gpt-4o", "rate_limited", ""), want: 1},
+		{name: "input tokens", c: m.tokens.WithLabelValues("openai", "gpt-4o", "input"), want: 100},
+		{name: "output tokens", c: m.tokens.WithLabelValues("openai", "gpt-4o", "output"), want: 50},
+		{name: "cost", c: m.cost.WithLabelValues("openai", "gpt-4o", "USD"), want: 2},
+	}
+	for _, tt := range tests {
+		if got := testutil.ToFloat64(tt.c); got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
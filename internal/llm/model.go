@@ -2,6 +2,7 @@ package llm
 
 import (
 	"coda/internal/config"
+	"fmt"
 	"time"
 )
 
@@ -16,6 +17,7 @@ type Config struct {
 // Model represents a language model.
 type Model struct {
 	Provider      Provider
+	Endpoint      string // Name of the endpoint serving an OpenAI-compatible model
 	Name          string
 	DisplayName   string
 	MaxToken      int
@@ -34,16 +36,111 @@ type ModelPricing struct {
 	Currency       string
 }
 
+// Cost returns the cost of the tokens of usage, or nil when the model has no
+// pricing or the provider did not report the usage.
+func (p *ModelPricing) Cost(usage *Usage) *Cost {
+	if p == nil || usage == nil {
+		return nil
+	}
+	cost := &Cost{
+		Input:    float64(usage.PromptTokens) * p.InputPerToken,
+		Output:   float64(usage.CompletionTokens) * p.OutputPerToken,
+		Currency: p.Currency,
+	}
+	cost.Total = cost.Input + cost.Output
+	return cost
+}
+
+// Cost is the cost of one or more completions, estimated from the model pricing.
+type Cost struct {
+	Input    float64 `json:"input"`
+	Output   float64 `json:"output"`
+	Total    float64 `json:"total"`
+	Currency string  `json:"currency"`
+}
+
+// Add returns the sum of the costs. A nil cost adds nothing, and costs in
+// another currency are not added.
+func (c *Cost) Add(other *Cost) *Cost {
+	switch {
+	case other == nil:
+		return c
+	case c == nil:
+		sum := *other
+		return &sum
+	case c.Currency != other.Currency:
+		return c
+	}
+	return &Cost{
+		Input:    c.Input + other.Input,
+		Output:   c.Output + other.Output,
+		Total:    c.Total + other.Total,
+		Currency: c.Currency,
+	}
+}
+
 // Provider represents an LLM provider.
 type Provider string
 
 // Supported providers
 const (
-	OpenAI Provider = "openai"
-	Ollama Provider = "ollama"
+	OpenAI           Provider = "openai"
+	Ollama           Provider = "ollama"
+	Anthropic        Provider = "anthropic"
+	OpenAICompatible Provider = "openai-compatible"
+	Azure            Provider = "azure"
+	Gemini           Provider = "gemini"
 )
 
 // String returns the string representation of the provider.
 func (p Provider) String() string {
 	return string(p)
 }
+
+// modelFromConfig converts a model declared in the configuration to a Model.
+func modelFromConfig(mc config.Model) Model {
+	model := Model{
+		Provider:      Provider(mc.Provider),
+		Name:          mc.Name,
+		DisplayName:   mc.DisplayName,
+		MaxToken:      mc.MaxTokens,
+		ContextWindow: mc.ContextWindow,
+		Version:       mc.Version,
+		Family:        mc.Family,
+		Capabilities: ModelCapabilities{
+			SupportsStreaming: mc.Capabilities.Streaming,
+			SupportsFunctions: mc.Capabilities.Functions,
+			SupportsVision:    mc.Capabilities.Vision,
+			SupportsJSON:      mc.Capabilities.JSON,
+		},
+	}
+
+	if model.DisplayName == "" {
+		model.DisplayName = mc.Name
+	}
+
+	if mc.Pricing != nil {
+		model.Pricing = &ModelPricing{
+			InputPerToken:  mc.Pricing.InputPerToken,
+			OutputPerToken: mc.Pricing.OutputPerToken,
+			Currency:       mc.Pricing.Currency,
+		}
+		if model.Pricing.Currency == "" {
+			model.Pricing.Currency = "USD"
+		}
+	}
+
+	return model
+}
+
+// EndpointModel attaches a model to the OpenAI-compatible endpoint serving it.
+// The endpoint name is added to the default display name, which must be unique
+// across endpoints serving the same model.
+func EndpointModel(endpoint string, model Model) Model {
+	if model.DisplayName == "" || model.DisplayName == model.Name {
+		model.DisplayName = fmt.Sprintf("%s (%s)", model.Name, endpoint)
+	}
+	model.Provider = OpenAICompatible
+	model.Endpoint = endpoint
+	return model
+}
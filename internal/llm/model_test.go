@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModelPricingCost(t *testing.T) {
+	pricing := &ModelPricing{InputPerToken: 0.5, OutputPerToken: 2, Currency: "USD"}
+
+	tests := []struct {
+		name    string
+		pricing *ModelPricing
+		usage   *Usage
+		want    *Cost
+	}{
+		{
+			name:    "priced",
+			pricing: pricing,
+			usage:   &Usage{PromptTokens: 10, CompletionTokens: 3},
+			want:    &Cost{Input: 5, Output: 6, Total: 11, Currency: "USD"},
+		},
+		{name: "no pricing", usage: &Usage{PromptTokens: 10}},
+		{name: "no usage", pricing: pricing},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pricing.Cost(tt.usage); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Cost() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCostAdd(t *testing.T) {
+	usd := &Cost{Input: 1, Output: 2, Total: 3, Currency: "USD"}
+
+	tests := []struct {
+		name  string
+		cost  *Cost
+		other *Cost
+		want  *Cost
+	}{
+		{name: "sum", cost: usd, other: usd, want: &Cost{Input: 2, Output: 4, Total: 6, Currency: "USD"}},
+		{name: "nil cost", other: usd, want: usd},
+		{name: "nil other", cost: usd, want: usd},
+		{name: "other currency", cost: usd, other: &Cost{Total: 1, Currency: "EUR"}, want: usd},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cost.Add(tt.other); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Add() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
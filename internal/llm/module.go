@@ -2,17 +2,139 @@ package llm
 
 import (
 	"coda/internal/config"
+	"coda/internal/llm/cache"
+	"coda/internal/llm/langfuse"
+	"context"
+	"fmt"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/fx"
 )
 
 // Module exports the LLM module for dependency injection.
 var Module = fx.Module("llm",
 	fx.Provide(
-		// Provide the completer with default configuration
-		func(cfg *config.Config) Completer {
-			r := NewRegistry(cfg)
-			return NewCompleter(cfg, r, WithCompleterRetryConfig(DefaultRetryConfig))
+		// Provide the model registry
+		func(cfg *config.Config) (*Registry, error) {
+			r, err := NewRegistry(cfg)
+			if err != nil {
+				return nil, fmt.Errorf("creating model registry: %w", err)
+			}
+			return r, nil
+		},
+		// Provide the Langfuse exporter, nil when Langfuse is not configured
+		newExporter,
+		// Provide the completion metrics, nil without a metrics registry
+		newMetrics,
+		// Provide the spending budget, nil when no limit is configured
+		NewBudget,
+		// Provide the completion cache, nil when the cache is disabled
+		newCacheStore,
+		// Provide the completer with default configuration, behind the cache
+		func(cfg *config.Config, r *Registry, e *langfuse.Exporter, m *Metrics, b *Budget, s cache.Store) Completer {
+			c := NewCompleter(cfg, r,
+				WithCompleterRetryConfig(DefaultRetryConfig),
+				WithCompleterLangfuse(e),
+				WithCompleterMetrics(m),
+				WithCompleterBudget(b),
+			)
+			if s == nil {
+				return c
+			}
+
+			ttl := cfg.LLM.Cache.TTL
+			if ttl == 0 {
+				ttl = DefaultCacheTTL
+			}
+			return NewCachingCompleter(c, s, ttl)
 		},
 	),
+	fx.Invoke(registerLifetimeHooks),
 )
+
+// metricsParams are the dependencies of the metrics. The registry is provided
+// by the server only, the command-line client does not record metrics.
+type metricsParams struct {
+	fx.In
+
+	Registerer prometheus.Registerer `optional:"true"`
+	Exporter   *langfuse.Exporter
+}
+
+// newMetrics registers the completion metrics, and those of the Langfuse
+// exporter, on the registry.
+func newMetrics(p metricsParams) (*Metrics, error) {
+	if p.Registerer == nil {
+		return nil, nil
+	}
+
+	if p.Exporter != nil {
+		if err := p.Exporter.RegisterMetrics(p.Registerer); err != nil {
+			return nil, err
+		}
+	}
+	return NewMetrics(p.Registerer)
+}
+
+// newExporter starts the Langfuse exporter and sends the queued events when
+// the application stops.
+func newExporter(lc fx.Lifecycle, cfg *config.Config) *langfuse.Exporter {
+	if !cfg.LLM.Langfuse.IsConfigured() {
+		return nil
+	}
+
+	e := langfuse.NewExporter(cfg)
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			if err := e.Shutdown(ctx); err != nil {
+				return fmt.Errorf("flushing Langfuse events: %w", err)
+			}
+			return nil
+		},
+	})
+	return e
+}
+
+// newCacheStore opens the store of the completion cache: an in-memory LRU, in
+// front of a database file when a path is configured. The database is closed
+// when the application stops.
+func newCacheStore(lc fx.Lifecycle, cfg *config.Config) (cache.Store, error) {
+	if cfg.LLM.Cache.Disabled {
+		return nil, nil
+	}
+
+	size := cfg.LLM.Cache.Size
+	if size == 0 {
+		size = DefaultCacheSize
+	}
+	memory := cache.NewMemory(size)
+	if cfg.LLM.Cache.Path == "" {
+		return memory, nil
+	}
+
+	disk, err := cache.NewBolt(cfg.LLM.Cache.Path)
+	if err != nil {
+		return nil, fmt.Errorf("opening completion cache: %w", err)
+	}
+	store := cache.NewTiered(memory, disk)
+	lc.Append(fx.Hook{
+		OnStop: func(context.Context) error {
+			return store.Close()
+		},
+	})
+	return store, nil
+}
+
+// registerLifetimeHooks starts and stops the background model discovery.
+func registerLifetimeHooks(lc fx.Lifecycle, r *Registry) {
+	lc.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			r.Start()
+			return nil
+		},
+		OnStop: func(_ context.Context) error {
+			r.Stop()
+			return nil
+		},
+	})
+}
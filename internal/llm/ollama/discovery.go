@@ -0,0 +1,107 @@
+package ollama
+
+import (
+	"coda/internal/config"
+	"coda/internal/llm"
+	"coda/internal/logger"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/ollama/ollama/api"
+)
+
+// Discover lists the models installed on the Ollama server using the
+// /api/tags endpoint and enriches each of them with /api/show metadata.
+func Discover(ctx context.Context, cfg config.LLM) ([]llm.Model, error) {
+	u, err := url.Parse(cfg.Ollama.BaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %w", err)
+	}
+
+	// The context bounds the whole discovery
+	client := api.NewClient(u, http.DefaultClient)
+
+	list, err := client.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing models: %w", err)
+	}
+
+	models := make([]llm.Model, 0, len(list.Models))
+	for _, m := range list.Models {
+		show, err := client.Show(ctx, &api.ShowRequest{Model: m.Name})
+		if err != nil {
+			// Fall back to the summary from the list endpoint
+			logger.Warn(ctx, "failed to show Ollama model", "model", m.Name, "err", err)
+			show = &api.ShowResponse{Details: m.Details}
+		}
+		models = append(models, modelFromOllama(m, show))
+	}
+
+	return models, nil
+}
+
+// modelFromOllama converts an installed Ollama model to a Model.
+func modelFromOllama(m api.ListModelResponse, show *api.ShowResponse) llm.Model {
+	contextWindow := contextLength(show.ModelInfo)
+
+	// Ollama resolves untagged names to the latest tag
+	name := strings.TrimSuffix(m.Name, ":latest")
+
+	model := llm.Model{
+		Name:          name,
+		DisplayName:   name,
+		Provider:      llm.Ollama,
+		MaxToken:      contextWindow,
+		ContextWindow: contextWindow,
+		Version:       shortDigest(m.Digest),
+		Family:        show.Details.Family,
+		Pricing: &llm.ModelPricing{
+			InputPerToken:  0.00000,
+			OutputPerToken: 0.00000,
+			Currency:       "USD",
+		},
+		Capabilities: llm.ModelCapabilities{
+			SupportsStreaming: true,
+			// Ollama only passes tools to models whose template renders them
+			SupportsFunctions: strings.Contains(show.Template, ".Tools"),
+			SupportsVision:    slices.Contains(show.Details.Families, "clip") || slices.Contains(show.Details.Families, "mllama"),
+			SupportsJSON:      true,
+		},
+	}
+
+	if m.Details.ParameterSize != "" {
+		model.DisplayName = fmt.Sprintf("%s (%s)", name, m.Details.ParameterSize)
+	}
+
+	return model
+}
+
+// contextLength extracts the trained context length from the model info,
+// which is keyed by architecture (e.g. "llama.context_length").
+func contextLength(info map[string]any) int {
+	arch, _ := info["general.architecture"].(string)
+	if arch == "" {
+		return 0
+	}
+
+	switch v := info[arch+".context_length"].(type) {
+	case float64:
+		return int(v)
+	case int:
+		return v
+	default:
+		return 0
+	}
+}
+
+// shortDigest returns the first 12 characters of a model digest.
+func shortDigest(digest string) string {
+	if len(digest) > 12 {
+		return digest[:12]
+	}
+	return digest
+}
@@ -0,0 +1,111 @@
+package ollama
+
+import (
+	"coda/internal/config"
+	"coda/internal/llm"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/ollama/ollama/api"
+)
+
+func TestDiscover(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			w.Write([]byte(`{"models": [
+				{"name": "qwen2.5-coder:latest", "model": "qwen2.5-coder:latest", "digest": "2b0496514337a3d5",
+					"details": {"family": "qwen2", "families": ["qwen2"], "parameter_size": "7.6B"}},
+				{"name": "llava:7b", "model": "llava:7b", "digest": "8dd30f6b",
+					"details": {"family": "llama", "families": ["llama", "clip"]}}
+			]}`))
+		case "/api/show":
+			var req api.ShowRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("decoding request: %v", err)
+			}
+			// The metadata of the second model cannot be read
+			if req.Model != "qwen2.5-coder:latest" {
+				http.Error(w, `{"error": "model not found"}`, http.StatusNotFound)
+				return
+			}
+			w.Write([]byte(`{
+				"template": "{{- if .Tools }}{{ .Tools }}{{ end }}",
+				"details": {"family": "qwen2", "families": ["qwen2"], "parameter_size": "7.6B"},
+				"model_info": {"general.architecture": "qwen2", "qwen2.context_length": 32768}
+			}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	models, err := Discover(context.Background(), config.LLM{Ollama: config.Ollama{BaseURL: server.URL}})
+	if err != nil {
+		t.Fatalf("Discover() error = %v", err)
+	}
+
+	free := &llm.ModelPricing{Currency: "USD"}
+	want := []llm.Model{
+		{
+			Name:          "qwen2.5-coder",
+			DisplayName:   "qwen2.5-coder (7.6B)",
+			Provider:      llm.Ollama,
+			MaxToken:      32768,
+			ContextWindow: 32768,
+			Version:       "2b0496514337",
+			Family:        "qwen2",
+			Pricing:       free,
+			Capabilities:  llm.ModelCapabilities{SupportsStreaming: true, SupportsFunctions: true, SupportsJSON: true},
+		},
+		{
+			// Described from the list alone, without its context length
+			Name:         "llava:7b",
+			DisplayName:  "llava:7b",
+			Provider:     llm.Ollama,
+			Version:      "8dd30f6b",
+			Family:       "llama",
+			Pricing:      free,
+			Capabilities: llm.ModelCapabilities{SupportsStreaming: true, SupportsVision: true, SupportsJSON: true},
+		},
+	}
+	if !reflect.DeepEqual(models, want) {
+		t.Errorf("Discover() = %+v, want %+v", models, want)
+	}
+}
+
+func TestDiscoverUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	models, err := Discover(context.Background(), config.LLM{Ollama: config.Ollama{BaseURL: server.URL}})
+	if err == nil || len(models) != 0 {
+		t.Errorf("Discover() = %v, %v, want an error", models, err)
+	}
+}
+
+func TestContextLength(t *testing.T) {
+	tests := []struct {
+		name string
+		info map[string]any
+		want int
+	}{
+		{name: "JSON", info: map[string]any{"general.architecture": "llama", "llama.context_length": float64(131072)}, want: 131072},
+		{name: "Int", info: map[string]any{"general.architecture": "gemma3", "gemma3.context_length": 8192}, want: 8192},
+		{name: "OtherArchitecture", info: map[string]any{"general.architecture": "llama", "qwen2.context_length": float64(32768)}},
+		{name: "NoArchitecture", info: map[string]any{"llama.context_length": float64(131072)}},
+		{name: "NoInfo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := contextLength(tt.info); got != tt.want {
+				t.Errorf("contextLength() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
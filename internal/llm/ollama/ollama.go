@@ -4,10 +4,12 @@ import (
 	"coda/internal/llm"
 	"coda/internal/logger"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
+	"maps"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/ollama/ollama/api"
@@ -58,21 +60,251 @@ func (c *Client) Complete(
 ) (*llm.CompleteResponse, error) {
 	startTime := time.Now()
 
-	httpClient := &http.Client{
-		Timeout: c.cfg.Timeout,
+	client, err := c.newAPIClient(ctx)
+	if err != nil {
+		return nil, err
 	}
 
+	req, err := c.newChatRequest(params, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var msgs []llm.Message
+	var last api.ChatResponse
+	respFunc := func(resp api.ChatResponse) error {
+		last = resp
+		respMsgs, err := toMessages(resp.Message.Role, resp.Message.Content, resp.DoneReason, resp.Message.ToolCalls, params.Functions)
+		if err != nil {
+			return err
+		}
+		msgs = append(msgs, respMsgs...)
+		return nil
+	}
+
+	if err := client.Chat(ctx, req, respFunc); err != nil {
+		return nil, c.handleError(err)
+	}
+
+	return c.response(msgs, last, startTime), nil
+}
+
+// Stream processes the given parameters, delivering each generated delta to fn,
+// and returns the aggregated completion response.
+func (c *Client) Stream(
+	ctx context.Context,
+	params llm.CompleteParams,
+	fn llm.StreamFunc,
+) (*llm.CompleteResponse, error) {
+	startTime := time.Now()
+
+	client, err := c.newAPIClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := c.newChatRequest(params, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Accumulate the deltas while forwarding them to the caller.
+	// Tool calls are not streamed but sent whole in one response.
+	var content strings.Builder
+	var calls []api.ToolCall
+	var last api.ChatResponse
+	respFunc := func(resp api.ChatResponse) error {
+		last = resp
+		content.WriteString(resp.Message.Content)
+		calls = append(calls, resp.Message.ToolCalls...)
+
+		if resp.Message.Content == "" && !resp.Done {
+			return nil
+		}
+
+		return fn(llm.StreamChunk{
+			Delta:        resp.Message.Content,
+			FinishReason: resp.DoneReason,
+		})
+	}
+
+	if err := client.Chat(ctx, req, respFunc); err != nil {
+		return nil, c.handleError(err)
+	}
+
+	msgs, err := toMessages("assistant", content.String(), last.DoneReason, calls, params.Functions)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.response(msgs, last, startTime), nil
+}
+
+// response builds the completion response. The final response of a chat
+// carries the token counts and timings of the whole completion.
+func (c *Client) response(msgs []llm.Message, last api.ChatResponse, startTime time.Time) *llm.CompleteResponse {
+	return &llm.CompleteResponse{
+		Messages: msgs,
+		Usage: &llm.Usage{
+			Unit:             "tokens",
+			PromptTokens:     last.PromptEvalCount,
+			CompletionTokens: last.EvalCount,
+			TotalTokens:      last.PromptEvalCount + last.EvalCount,
+		},
+		Metadata: llm.CompletionMetadata{
+			ModelName:     c.cfg.Model.Name,
+			FinishReason:  last.DoneReason,
+			LatencyMs:     time.Since(startTime).Milliseconds(),
+			ProcessedAt:   time.Now().UTC(),
+			RequestTokens: last.PromptEvalCount,
+			Timings:       timings(last.Metrics),
+		},
+	}
+}
+
+// timings converts the metrics of a chat response to timings.
+func timings(m api.Metrics) *llm.Timings {
+	return &llm.Timings{
+		Load:                  m.LoadDuration,
+		PromptEval:            m.PromptEvalDuration,
+		Eval:                  m.EvalDuration,
+		Total:                 m.TotalDuration,
+		PromptTokensPerSecond: perSecond(m.PromptEvalCount, m.PromptEvalDuration),
+		TokensPerSecond:       perSecond(m.EvalCount, m.EvalDuration),
+	}
+}
+
+// perSecond returns the throughput of processing the tokens in the duration.
+func perSecond(tokens int, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(tokens) / d.Seconds()
+}
+
+// newAPIClient creates an Ollama API client for the configured base URL.
+func (c *Client) newAPIClient(ctx context.Context) (*api.Client, error) {
+	httpClient := llm.NewHTTPClient(c.cfg.Timeout)
+
 	u, err := url.Parse(c.cfg.LLMConfig.Ollama.BaseURL)
 	if err != nil {
 		logger.Error(ctx, "invalid base URL", "err", err)
 		return nil, fmt.Errorf("invalid base URL: %w", err)
 	}
 
-	client := api.NewClient(u, httpClient)
+	return api.NewClient(u, httpClient), nil
+}
+
+// responseFormat returns the output format requested by the parameters:
+// the response schema for structured outputs, or any JSON in JSON mode.
+// Requests offering tools are not constrained, which would keep the model
+// from calling them.
+func responseFormat(params llm.CompleteParams) (json.RawMessage, error) {
+	if !params.JSONMode || len(params.Functions) > 0 {
+		return nil, nil
+	}
+	if params.ResponseSchema == nil {
+		return json.RawMessage(`"json"`), nil
+	}
+
+	schema, err := json.Marshal(params.ResponseSchema)
+	if err != nil {
+		return nil, fmt.Errorf("%w: response schema: %w", llm.ErrInvalidArguments, err)
+	}
+	return schema, nil
+}
+
+// requestOptions returns the model options of a request: the configured
+// options, overridden by the sampling parameters of the request.
+func requestOptions(configured map[string]any, params llm.CompleteParams) (map[string]any, error) {
+	// Ollama generates a single completion per request
+	if params.N != nil && *params.N > 1 {
+		return nil, fmt.Errorf("%w: Ollama cannot generate %d completions", llm.ErrInvalidArguments, *params.N)
+	}
+
+	options := maps.Clone(configured)
+	if options == nil {
+		options = map[string]any{}
+	}
+	if params.MaxTokens != nil {
+		options["num_predict"] = *params.MaxTokens
+	}
+	if params.Temperature != nil {
+		options["temperature"] = *params.Temperature
+	}
+	if params.TopP != nil {
+		options["top_p"] = *params.TopP
+	}
+	if len(options) == 0 {
+		return nil, nil
+	}
+	return options, nil
+}
+
+// newChatRequest builds the chat request for the parameters.
+func (c *Client) newChatRequest(params llm.CompleteParams, stream bool) (*api.ChatRequest, error) {
+	messages, err := toOllamaMessages(params.Messages)
+	if err != nil {
+		return nil, err
+	}
+
+	tools, err := toTools(params.Functions)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := responseFormat(params)
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := requestOptions(c.cfg.LLMConfig.Ollama.Options, params)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &api.ChatRequest{
+		Model:    c.cfg.Model.Name,
+		Messages: messages,
+		Stream:   &stream,
+		Format:   format,
+		Tools:    tools,
+		Options:  options,
+	}
+	if keepAlive := c.cfg.LLMConfig.Ollama.KeepAlive; keepAlive != nil {
+		req.KeepAlive = &api.Duration{Duration: *keepAlive}
+	}
+	return req, nil
+}
+
+// toTools converts function definitions to Ollama tools.
+func toTools(functions []llm.FunctionDefinition) (api.Tools, error) {
+	var tools api.Tools
+	for _, f := range functions {
+		// The parameters are a JSON schema of any Go type, decoded into the
+		// schema subset understood by Ollama
+		data, err := json.Marshal(map[string]any{
+			"type":     "function",
+			"function": f,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("%w: parameters of %s: %w", llm.ErrInvalidArguments, f.Name, err)
+		}
 
-	// Convert messages to Ollama format
+		var tool api.Tool
+		if err := json.Unmarshal(data, &tool); err != nil {
+			return nil, fmt.Errorf("%w: parameters of %s are not a JSON schema object: %w", llm.ErrInvalidArguments, f.Name, err)
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
+
+// toOllamaMessages converts messages to Ollama format.
+// Function calls and their results are sent as tool calls and tool messages.
+func toOllamaMessages(msgs []llm.Message) ([]api.Message, error) {
 	var messages []api.Message
-	for _, m := range params.Messages {
+	for _, m := range msgs {
 		switch m.Role {
 		case llm.RoleUser:
 			messages = append(messages, api.Message{
@@ -80,58 +312,73 @@ func (c *Client) Complete(
 				Content: m.Content,
 			})
 		case llm.RoleAssistant:
-			messages = append(messages, api.Message{
+			msg := api.Message{
 				Role:    "assistant",
 				Content: m.Content,
-			})
+			}
+			if m.FunctionCall != nil {
+				var args api.ToolCallFunctionArguments
+				if err := m.FunctionCall.ParseArguments(&args); err != nil && m.FunctionCall.Arguments != "" {
+					return nil, fmt.Errorf("%w: arguments of %s are not a JSON object: %w", llm.ErrInvalidFunctionCall, m.FunctionCall.Name, err)
+				}
+
+				// Parallel calls are sent as one assistant message
+				call := api.ToolCall{Function: api.ToolCallFunction{Name: m.FunctionCall.Name, Arguments: args}}
+				if n := len(messages); n > 0 && messages[n-1].Role == "assistant" && len(messages[n-1].ToolCalls) > 0 {
+					messages[n-1].ToolCalls = append(messages[n-1].ToolCalls, call)
+					continue
+				}
+				msg.ToolCalls = []api.ToolCall{call}
+			}
+			messages = append(messages, msg)
 		case llm.RoleSystem:
 			messages = append(messages, api.Message{
 				Role:    "system",
 				Content: m.Content,
 			})
 		case llm.RoleFunction:
-			fallthrough
+			messages = append(messages, api.Message{
+				Role:    "tool",
+				Content: m.Content,
+			})
 		default:
 			return nil, fmt.Errorf("unsupported role: %s", m.Role)
 		}
 	}
+	return messages, nil
+}
 
-	// Build request parameters
-	stream := false
-	req := &api.ChatRequest{
-		Model:    c.cfg.Model.Name,
-		Messages: messages,
-		Stream:   &stream,
+// toMessages converts a response message to messages. Each tool call becomes a
+// message with a function call, the first one carrying the content. The calls
+// are validated against the offered functions.
+func toMessages(role, content, doneReason string, calls []api.ToolCall, functions []llm.FunctionDefinition) ([]llm.Message, error) {
+	msg := llm.Message{
+		Role:         llmRole(role),
+		Content:      content,
+		FinishReason: doneReason,
+		Completed:    true,
 	}
-
-	var msgs []llm.Message
-	var resp api.ChatResponse
-	respFunc := func(resp api.ChatResponse) error {
-		msgs = append(msgs, llm.Message{
-			Role:         llmRole(resp.Message.Role),
-			Content:      resp.Message.Content,
-			FinishReason: resp.DoneReason,
-			Completed:    true,
-		})
-		return nil
+	if len(calls) == 0 {
+		return []llm.Message{msg}, nil
 	}
 
-	if err := client.Chat(ctx, req, respFunc); err != nil {
-		return nil, c.handleError(err)
-	}
+	msgs := make([]llm.Message, 0, len(calls))
+	for i, tc := range calls {
+		call := &llm.FunctionCall{Name: tc.Function.Name}
+		if err := call.SetArguments(tc.Function.Arguments); err != nil {
+			return nil, fmt.Errorf("%w: %w", llm.ErrInvalidFunctionCall, err)
+		}
+		if err := llm.ValidateFunctionCall(call, functions); err != nil {
+			return nil, err
+		}
 
-	// Build the response
-	ret := &llm.CompleteResponse{
-		Messages: msgs,
-		Metadata: llm.CompletionMetadata{
-			ModelName:    c.cfg.Model.Name,
-			FinishReason: string(resp.DoneReason),
-			LatencyMs:    time.Since(startTime).Milliseconds(),
-			ProcessedAt:  time.Now().UTC(),
-		},
+		if i > 0 {
+			msg.Content = ""
+		}
+		msg.FunctionCall = call
+		msgs = append(msgs, msg)
 	}
-
-	return ret, nil
+	return msgs, nil
 }
 
 // handleError converts Ollama errors to our error types.
@@ -168,6 +415,9 @@ func init() {
 	llm.RegisterLLM(New, []llm.Model{
 		ModelTinySwallow,
 	})
+
+	// Discover the models installed on the server
+	llm.RegisterDiscoverer(llm.Ollama, Discover)
 }
 
 func llmRole(ollamaRole string) llm.Role {
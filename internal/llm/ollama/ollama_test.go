@@ -0,0 +1,285 @@
+package ollama
+
+import (
+	"bytes"
+	"coda/internal/config"
+	"coda/internal/llm"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ollama/ollama/api"
+)
+
+func TestNewChatRequest(t *testing.T) {
+	maxTokens, temperature, topP, one, two := 512, float32(0.2), float32(0.9), 1, 2
+	keepAlive := 10 * time.Minute
+	schema := json.RawMessage(`{"type":"object"}`)
+
+	tests := []struct {
+		name        string
+		cfg         config.Ollama
+		params      llm.CompleteParams
+		wantOptions map[string]any
+		wantFormat  string
+		wantErr     error
+	}{
+		{
+			name: "Defaults",
+		},
+		{
+			name:        "Sampling",
+			params:      llm.CompleteParams{MaxTokens: &maxTokens, Temperature: &temperature, TopP: &topP, N: &one},
+			wantOptions: map[string]any{"num_predict": 512, "temperature": float32(0.2), "top_p": float32(0.9)},
+		},
+		{
+			name:        "ConfiguredOptions",
+			cfg:         config.Ollama{Options: map[string]any{"num_ctx": 8192, "temperature": 0.8}},
+			params:      llm.CompleteParams{Temperature: &temperature},
+			wantOptions: map[string]any{"num_ctx": 8192, "temperature": float32(0.2)},
+		},
+		{
+			name:       "JSONMode",
+			params:     llm.CompleteParams{JSONMode: true},
+			wantFormat: `"json"`,
+		},
+		{
+			name:       "ResponseSchema",
+			params:     llm.CompleteParams{JSONMode: true, ResponseSchema: schema},
+			wantFormat: `{"type":"object"}`,
+		},
+		{
+			name:   "JSONModeWithTools",
+			params: llm.CompleteParams{JSONMode: true, Functions: []llm.FunctionDefinition{{Name: "read_file"}}},
+		},
+		{
+			name:    "SeveralCompletions",
+			params:  llm.CompleteParams{N: &two},
+			wantErr: llm.ErrInvalidArguments,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.KeepAlive = &keepAlive
+			c := &Client{cfg: llm.Config{Model: ModelTinySwallow, LLMConfig: config.LLM{Ollama: tt.cfg}}}
+			tt.params.Messages = []llm.Message{llm.NewUserMessage("Hi")}
+
+			req, err := c.newChatRequest(tt.params, false)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("newChatRequest() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(req.Options, tt.wantOptions) {
+				t.Errorf("options = %v, want %v", req.Options, tt.wantOptions)
+			}
+			if string(req.Format) != tt.wantFormat {
+				t.Errorf("format = %s, want %s", req.Format, tt.wantFormat)
+			}
+			if req.KeepAlive == nil || req.KeepAlive.Duration != keepAlive {
+				t.Errorf("keep alive = %v, want %v", req.KeepAlive, keepAlive)
+			}
+		})
+	}
+
+	// The configured options are not modified by the requests
+	cfg := map[string]any{"temperature": 0.8}
+	c := &Client{cfg: llm.Config{Model: ModelTinySwallow, LLMConfig: config.LLM{Ollama: config.Ollama{Options: cfg}}}}
+	if _, err := c.newChatRequest(llm.CompleteParams{Messages: []llm.Message{llm.NewUserMessage("Hi")}, Temperature: &temperature}, false); err != nil {
+		t.Fatal(err)
+	}
+	if cfg["temperature"] != 0.8 {
+		t.Errorf("configured temperature = %v, want 0.8", cfg["temperature"])
+	}
+}
+
+func TestResponse(t *testing.T) {
+	c := &Client{cfg: llm.Config{Model: ModelTinySwallow}}
+	last := api.ChatResponse{
+		DoneReason: "stop",
+		Done:       true,
+		Metrics: api.Metrics{
+			TotalDuration:      3 * time.Second,
+			LoadDuration:       time.Second,
+			PromptEvalCount:    200,
+			PromptEvalDuration: 500 * time.Millisecond,
+			EvalCount:          50,
+			EvalDuration:       time.Second,
+		},
+	}
+
+	res := c.response([]llm.Message{llm.NewAssistantMessage("Hello")}, last, time.Now())
+
+	wantUsage := llm.Usage{Unit: "tokens", PromptTokens: 200, CompletionTokens: 50, TotalTokens: 250}
+	if res.Usage == nil || *res.Usage != wantUsage {
+		t.Errorf("usage = %+v, want %+v", res.Usage, wantUsage)
+	}
+	if res.Metadata.FinishReason != "stop" || res.Metadata.RequestTokens != 200 {
+		t.Errorf("metadata = %+v", res.Metadata)
+	}
+
+	wantTimings := llm.Timings{
+		Load:                  time.Second,
+		PromptEval:            500 * time.Millisecond,
+		Eval:                  time.Second,
+		Total:                 3 * time.Second,
+		PromptTokensPerSecond: 400,
+		TokensPerSecond:       50,
+	}
+	if res.Metadata.Timings == nil || *res.Metadata.Timings != wantTimings {
+		t.Errorf("timings = %+v, want %+v", res.Metadata.Timings, wantTimings)
+	}
+}
+
+// newTestClient returns a client of an Ollama server stand-in answering chats
+// with the NDJSON lines, and the chat requests it received.
+func newTestClient(t *testing.T, lines ...string) (*Client, *[]api.ChatRequest) {
+	t.Helper()
+
+	var requests []api.ChatRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		var req api.ChatRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		requests = append(requests, req)
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, line := range lines {
+			var data bytes.Buffer
+			_ = json.Compact(&data, []byte(line))
+			fmt.Fprintf(w, "%s\n", data.Bytes())
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	cfg := llm.Config{Model: ModelTinySwallow, LLMConfig: config.LLM{Ollama: config.Ollama{BaseURL: srv.URL}}}
+	return &Client{cfg: cfg}, &requests
+}
+
+var readFile = llm.FunctionDefinition{
+	Name:        "read_file",
+	Description: "Read a file",
+	Parameters:  map[string]any{"type": "object", "properties": map[string]any{"path": map[string]any{"type": "string"}}},
+}
+
+func TestCompleteToolCalls(t *testing.T) {
+	c, requests := newTestClient(t, `{"model": "tinyswallow", "created_at": "2025-01-01T00:00:00Z",
+		"message": {"role": "assistant", "content": "Reading both files.", "tool_calls": [
+			{"function": {"name": "read_file", "arguments": {"path": "a.go"}}},
+			{"function": {"name": "read_file", "arguments": {"path": "b.go"}}}
+		]},
+		"done": true, "done_reason": "stop", "prompt_eval_count": 30, "eval_count": 20}`)
+
+	res, err := c.Complete(context.Background(), llm.CompleteParams{
+		Messages: []llm.Message{
+			llm.NewUserMessage("Review main.go"),
+			{Role: llm.RoleAssistant, FunctionCall: &llm.FunctionCall{Name: "read_file", Arguments: `{"path":"main.go"}`}},
+			{Role: llm.RoleAssistant, FunctionCall: &llm.FunctionCall{Name: "read_file", Arguments: `{"path":"go.mod"}`}},
+			llm.NewFunctionMessage("read_file", "package main"),
+			llm.NewFunctionMessage("read_file", "module coda"),
+		},
+		Functions: []llm.FunctionDefinition{readFile},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	// Parallel calls are sent as one assistant message, answered by tool messages
+	req := (*requests)[0]
+	var got []string
+	for _, m := range req.Messages {
+		desc := m.Role + ":" + m.Content
+		for _, tc := range m.ToolCalls {
+			args, _ := json.Marshal(tc.Function.Arguments)
+			desc += fmt.Sprintf(" %s(%s)", tc.Function.Name, args)
+		}
+		got = append(got, desc)
+	}
+	want := []string{
+		"user:Review main.go",
+		`assistant: read_file({"path":"main.go"}) read_file({"path":"go.mod"})`,
+		"tool:package main",
+		"tool:module coda",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+	if len(req.Tools) != 1 || req.Tools[0].Type != "function" || req.Tools[0].Function.Name != "read_file" {
+		t.Errorf("tools = %+v", req.Tools)
+	}
+
+	wantMsgs := []llm.Message{
+		{Role: llm.RoleAssistant, Content: "Reading both files.", FinishReason: "stop", Completed: true,
+			FunctionCall: &llm.FunctionCall{Name: "read_file", Arguments: `{"path":"a.go"}`}},
+		{Role: llm.RoleAssistant, FinishReason: "stop", Completed: true,
+			FunctionCall: &llm.FunctionCall{Name: "read_file", Arguments: `{"path":"b.go"}`}},
+	}
+	if !reflect.DeepEqual(res.Messages, wantMsgs) {
+		t.Errorf("messages = %+v, want %+v", res.Messages, wantMsgs)
+	}
+	if res.Usage.TotalTokens != 50 {
+		t.Errorf("usage = %+v", res.Usage)
+	}
+}
+
+func TestStreamToolCalls(t *testing.T) {
+	// Tool calls are not streamed in fragments but sent whole in one response
+	c, _ := newTestClient(t,
+		`{"model": "tinyswallow", "created_at": "2025-01-01T00:00:00Z", "message": {"role": "assistant", "content": "Reading"}, "done": false}`,
+		`{"model": "tinyswallow", "created_at": "2025-01-01T00:00:00Z", "message": {"role": "assistant", "content": " files."}, "done": false}`,
+		`{"model": "tinyswallow", "created_at": "2025-01-01T00:00:00Z", "message": {"role": "assistant", "content": "", "tool_calls": [
+			{"function": {"name": "read_file", "arguments": {"path": "a.go"}}},
+			{"function": {"name": "read_file", "arguments": {"path": "b.go"}}}
+		]}, "done": false}`,
+		`{"model": "tinyswallow", "created_at": "2025-01-01T00:00:00Z", "message": {"role": "assistant", "content": ""},
+			"done": true, "done_reason": "stop", "prompt_eval_count": 30, "eval_count": 20}`,
+	)
+
+	var deltas []string
+	res, err := c.Stream(context.Background(), llm.CompleteParams{
+		Messages:  []llm.Message{llm.NewUserMessage("Review a.go and b.go")},
+		Functions: []llm.FunctionDefinition{readFile},
+	}, func(chunk llm.StreamChunk) error {
+		deltas = append(deltas, chunk.Delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+
+	if got := strings.Join(deltas, ""); got != "Reading files." {
+		t.Errorf("streamed %q, want %q", got, "Reading files.")
+	}
+
+	calls := res.FunctionCalls()
+	want := []llm.FunctionCall{
+		{Name: "read_file", Arguments: `{"path":"a.go"}`},
+		{Name: "read_file", Arguments: `{"path":"b.go"}`},
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("got %d calls, want %d", len(calls), len(want))
+	}
+	for i, call := range calls {
+		if *call != want[i] {
+			t.Errorf("call %d = %+v, want %+v", i, *call, want[i])
+		}
+	}
+	if res.Messages[0].Content != "Reading files." || res.Metadata.FinishReason != "stop" {
+		t.Errorf("response = %+v, metadata %+v", res.Messages[0], res.Metadata)
+	}
+}
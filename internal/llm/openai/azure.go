@@ -0,0 +1,65 @@
+package openai
+
+import (
+	"coda/internal/config"
+	"coda/internal/llm"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/openai/openai-go/option"
+)
+
+// DefaultAzureAPIVersion is the Azure OpenAI API version used when none is configured.
+// https://learn.microsoft.com/en-us/azure/ai-services/openai/reference#rest-api-versioning
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// NewAzure creates a client for a model deployment of the Azure OpenAI Service.
+// The model name is the name of the deployment.
+func NewAzure(cfg llm.Config) (llm.LLM, error) {
+	if cfg.APIKeyFunc == nil {
+		return nil, fmt.Errorf("API key function is required")
+	}
+
+	azure := cfg.LLMConfig.Azure
+	if !azure.IsConfigured() {
+		return nil, fmt.Errorf("Azure OpenAI is not configured")
+	}
+
+	baseURL, err := deploymentURL(azure, cfg.Model.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	apiVersion := azure.APIVersion
+	if apiVersion == "" {
+		apiVersion = DefaultAzureAPIVersion
+	}
+
+	opts := []option.RequestOption{
+		option.WithBaseURL(baseURL.String()),
+		option.WithQuery("api-version", apiVersion),
+	}
+
+	// Entra ID tokens are sent as bearer tokens, API keys in their own header
+	if azure.BearerToken != "" {
+		opts = append(opts, option.WithAPIKey(azure.BearerToken))
+	} else {
+		opts = append(opts,
+			option.WithHeaderDel("Authorization"),
+			option.WithHeader("Api-Key", cfg.APIKeyFunc()),
+		)
+	}
+
+	return newClient(cfg, opts...), nil
+}
+
+// deploymentURL returns the base URL of the requests to a deployment, below which
+// the chat completions path is resolved.
+func deploymentURL(azure config.Azure, deployment string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSuffix(azure.Endpoint, "/") + "/")
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid Azure OpenAI endpoint %q", azure.Endpoint)
+	}
+	return u.JoinPath("openai", "deployments", deployment+"/"), nil
+}
@@ -0,0 +1,142 @@
+package openai
+
+import (
+	"coda/internal/config"
+	"coda/internal/llm"
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestDeploymentURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+		wantErr  bool
+	}{
+		{endpoint: "https://coda.openai.azure.com", want: "https://coda.openai.azure.com/openai/deployments/gpt-4o/chat/completions"},
+		{endpoint: "https://coda.openai.azure.com/", want: "https://coda.openai.azure.com/openai/deployments/gpt-4o/chat/completions"},
+		{endpoint: "coda.openai.azure.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			u, err := deploymentURL(config.Azure{Endpoint: tt.endpoint}, "gpt-4o")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("deploymentURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			// The client resolves API paths against the base URL
+			got, err := u.Parse("chat/completions")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("deploymentURL() resolves to %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToMessagesContentFilter(t *testing.T) {
+	_, err := toMessages("The first half of", FinishReasonContentFilter, nil, nil)
+	if !errors.Is(err, llm.ErrContentFiltered) {
+		t.Errorf("toMessages() error = %v, want %v", err, llm.ErrContentFiltered)
+	}
+}
+
+func TestNewAzure(t *testing.T) {
+	tests := []struct {
+		name          string
+		azure         config.Azure
+		wantVersion   string
+		wantAPIKey    string
+		wantAuthorize string
+	}{
+		{
+			name:        "APIKey",
+			azure:       config.Azure{APIKey: "azure-key"},
+			wantVersion: DefaultAzureAPIVersion,
+			wantAPIKey:  "azure-key",
+		},
+		{
+			name:          "BearerToken",
+			azure:         config.Azure{APIVersion: "2025-01-01-preview", BearerToken: "entra-token"},
+			wantVersion:   "2025-01-01-preview",
+			wantAuthorize: "Bearer entra-token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := newTestServer(t, respond(http.StatusOK, `{
+				"id": "chatcmpl-1", "object": "chat.completion", "created": 1, "model": "gpt-4o",
+				"choices": [{"index": 0, "message": {"role": "assistant", "content": "Looks good."}, "finish_reason": "stop"}],
+				"usage": {"prompt_tokens": 10, "completion_tokens": 3, "total_tokens": 13}
+			}`))
+			tt.azure.Endpoint = srv.URL
+			client := newAzureTestClient(t, tt.azure)
+
+			res, err := client.Complete(context.Background(), llm.CompleteParams{Messages: []llm.Message{llm.NewUserMessage("Hi")}})
+			if err != nil {
+				t.Fatalf("Complete() error = %v", err)
+			}
+			if res.Messages[0].Content != "Looks good." {
+				t.Errorf("content = %q", res.Messages[0].Content)
+			}
+
+			req := (*requests)[0]
+			if req.path != "/openai/deployments/coda-gpt-4o/chat/completions" {
+				t.Errorf("path = %q", req.path)
+			}
+			query, err := url.ParseQuery(req.query)
+			if err != nil || query.Get("api-version") != tt.wantVersion {
+				t.Errorf("query = %q, want api-version %s", req.query, tt.wantVersion)
+			}
+			// API keys are sent in their own header, never as bearer tokens
+			if got := req.header.Get("Api-Key"); got != tt.wantAPIKey {
+				t.Errorf("Api-Key = %q, want %q", got, tt.wantAPIKey)
+			}
+			if got := req.header.Get("Authorization"); got != tt.wantAuthorize {
+				t.Errorf("Authorization = %q, want %q", got, tt.wantAuthorize)
+			}
+		})
+	}
+}
+
+func TestAzureContentFilter(t *testing.T) {
+	// Prompts matched by the content filter are rejected with a 400
+	srv, _ := newTestServer(t, respond(http.StatusBadRequest, `{"error": {
+		"message": "The response was filtered due to the prompt triggering content management policy.",
+		"type": null, "param": "prompt", "code": "content_filter", "status": 400
+	}}`))
+	client := newAzureTestClient(t, config.Azure{Endpoint: srv.URL, APIKey: "azure-key"})
+
+	_, err := client.Complete(context.Background(), llm.CompleteParams{Messages: []llm.Message{llm.NewUserMessage("Hi")}})
+	if !errors.Is(err, llm.ErrContentFiltered) {
+		t.Errorf("Complete() error = %v, want %v", err, llm.ErrContentFiltered)
+	}
+	var llmErr *llm.LLMError
+	if !errors.As(err, &llmErr) || llmErr.StatusCode != http.StatusBadRequest || llmErr.Retryable {
+		t.Errorf("Complete() error = %#v, want a non-retryable 400", err)
+	}
+}
+
+// newAzureTestClient returns a client of a deployment of the Azure resource.
+func newAzureTestClient(t *testing.T, azure config.Azure) llm.LLM {
+	t.Helper()
+
+	client, err := NewAzure(llm.Config{
+		Model:      llm.Model{Provider: llm.Azure, Name: "coda-gpt-4o"},
+		APIKeyFunc: func() string { return azure.APIKey },
+		LLMConfig:  config.LLM{Azure: azure},
+	})
+	if err != nil {
+		t.Fatalf("NewAzure() error = %v", err)
+	}
+	return client
+}
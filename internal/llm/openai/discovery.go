@@ -0,0 +1,96 @@
+package openai
+
+import (
+	"coda/internal/config"
+	"coda/internal/llm"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// modelList is the response of the /models endpoint.
+type modelList struct {
+	Data []struct {
+		ID          string `json:"id"`
+		MaxModelLen int    `json:"max_model_len"` // Context window reported by vLLM
+	} `json:"data"`
+}
+
+// Discover lists the models served by the OpenAI-compatible endpoints that
+// don't declare their models, using the /models endpoint. The models of the
+// reachable endpoints are returned along with the errors of the others.
+func Discover(ctx context.Context, cfg config.LLM) ([]llm.Model, error) {
+	var (
+		models []llm.Model
+		errs   []error
+	)
+	for _, endpoint := range cfg.Endpoints {
+		if len(endpoint.Models) > 0 {
+			continue
+		}
+
+		list, err := listModels(ctx, endpoint)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("endpoint %s: %w", endpoint.Name, err))
+			continue
+		}
+		for _, m := range list.Data {
+			models = append(models, llm.EndpointModel(endpoint.Name, llm.Model{
+				Name:          m.ID,
+				ContextWindow: m.MaxModelLen,
+				Capabilities: llm.ModelCapabilities{
+					SupportsStreaming: true,
+				},
+			}))
+		}
+	}
+
+	return models, errors.Join(errs...)
+}
+
+// listModels requests the models served by an endpoint.
+func listModels(ctx context.Context, endpoint config.Endpoint) (*modelList, error) {
+	baseURL, err := endpointURL(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL.JoinPath("models").String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+endpoint.APIKey)
+	}
+
+	// The context bounds the whole discovery
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("listing models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("listing models: unexpected status %s", resp.Status)
+	}
+
+	var list modelList
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		return nil, fmt.Errorf("decoding models: %w", err)
+	}
+	return &list, nil
+}
+
+// endpointURL parses the base URL of an endpoint. The URL ends with a slash
+// so that API paths are resolved below it rather than replacing its last segment.
+func endpointURL(endpoint config.Endpoint) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSuffix(endpoint.BaseURL, "/") + "/")
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("endpoint %s: invalid base URL %q", endpoint.Name, endpoint.BaseURL)
+	}
+	return u, nil
+}
@@ -0,0 +1,85 @@
+package openai
+
+import (
+	"coda/internal/config"
+	"coda/internal/llm"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDiscover(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q, want %q", got, "Bearer secret")
+		}
+		w.Write([]byte(`{"object": "list", "data": [{"id": "qwen2.5-coder", "object": "model", "max_model_len": 32768}]}`))
+	}))
+	defer server.Close()
+
+	cfg := config.LLM{
+		Endpoints: []config.Endpoint{
+			{Name: "vllm", BaseURL: server.URL + "/v1", APIKey: "secret"},
+			{Name: "declared", BaseURL: "http://127.0.0.1:1/v1", Models: []config.Model{{Name: "llama"}}},
+			{Name: "down", BaseURL: server.URL + "/missing"},
+		},
+	}
+
+	models, err := Discover(context.Background(), cfg)
+	if err == nil {
+		t.Error("Discover() error = nil, want the error of the unreachable endpoint")
+	}
+	if len(models) != 1 {
+		t.Fatalf("Discover() = %d models, want 1", len(models))
+	}
+
+	want := llm.Model{
+		Provider:      llm.OpenAICompatible,
+		Endpoint:      "vllm",
+		Name:          "qwen2.5-coder",
+		DisplayName:   "qwen2.5-coder (vllm)",
+		ContextWindow: 32768,
+		Capabilities:  llm.ModelCapabilities{SupportsStreaming: true},
+	}
+	if got := models[0]; got != want {
+		t.Errorf("Discover() = %+v, want %+v", got, want)
+	}
+}
+
+func TestEndpointURL(t *testing.T) {
+	tests := []struct {
+		baseURL string
+		want    string
+		wantErr bool
+	}{
+		{baseURL: "http://localhost:8000/v1", want: "http://localhost:8000/v1/chat/completions"},
+		{baseURL: "http://localhost:8080/v1/", want: "http://localhost:8080/v1/chat/completions"},
+		{baseURL: "localhost:8000", wantErr: true},
+		{baseURL: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.baseURL, func(t *testing.T) {
+			u, err := endpointURL(config.Endpoint{Name: "test", BaseURL: tt.baseURL})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("endpointURL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			// The client resolves API paths against the base URL
+			got, err := u.Parse("chat/completions")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("endpointURL() resolves to %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,9 +3,11 @@ package openai
 import (
 	"coda/internal/llm"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"net/http"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/openai/openai-go"
@@ -46,8 +48,13 @@ const (
 	ErrCodeRateLimitExceeded     = "rate_limit_exceeded"
 	ErrCodeInsufficientQuota     = "insufficient_quota"
 	ErrCodeInvalidRequestError   = "invalid_request_error"
+	ErrCodeContentFilter         = "content_filter" // Azure OpenAI content filtering
 )
 
+// FinishReasonContentFilter is the finish reason of a completion whose
+// content was filtered.
+const FinishReasonContentFilter = "content_filter"
+
 // Ensure Client implements the LLM interface
 var _ llm.LLM = (*Client)(nil)
 
@@ -64,22 +71,37 @@ func New(cfg llm.Config) (llm.LLM, error) {
 	}
 
 	// Create the OpenAI client with options
-	var client *openai.Client
+	opts := []option.RequestOption{option.WithAPIKey(cfg.APIKeyFunc())}
 
-	// Set custom timeout if provided
-	if cfg.Timeout > 0 {
-		httpClient := &http.Client{
-			Timeout: cfg.Timeout,
+	// OpenAI-compatible servers are reached at the base URL of their endpoint
+	if cfg.Model.Provider == llm.OpenAICompatible {
+		endpoint, ok := cfg.LLMConfig.Endpoint(cfg.Model.Endpoint)
+		if !ok {
+			return nil, fmt.Errorf("endpoint %q is not configured", cfg.Model.Endpoint)
+		}
+		baseURL, err := endpointURL(endpoint)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, option.WithBaseURL(baseURL.String()))
+
+		// Servers without authentication don't need the header
+		if endpoint.APIKey == "" {
+			opts = append(opts, option.WithHeaderDel("Authorization"))
 		}
-		client = openai.NewClient(option.WithHTTPClient(httpClient), option.WithAPIKey(cfg.APIKeyFunc()))
-	} else {
-		client = openai.NewClient(option.WithAPIKey(cfg.APIKeyFunc()))
 	}
 
+	return newClient(cfg, opts...), nil
+}
+
+// newClient creates a client with the given request options and the configured timeout.
+func newClient(cfg llm.Config, opts ...option.RequestOption) *Client {
+	opts = append(opts, option.WithHTTPClient(llm.NewHTTPClient(cfg.Timeout)))
+
 	return &Client{
 		cfg:    cfg,
-		client: client,
-	}, nil
+		client: openai.NewClient(opts...),
+	}
 }
 
 // GetModelInfo returns information about the model.
@@ -96,22 +118,275 @@ func (c *Client) Complete(
 ) (*llm.CompleteResponse, error) {
 	startTime := time.Now()
 
+	completionParams, err := c.buildParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make the API call
+	completion, err := c.client.Chat.Completions.New(ctx, completionParams)
+	if err != nil {
+		return nil, c.handleError(err)
+	}
+
+	// Check for empty response
+	if len(completion.Choices) == 0 {
+		return nil, llm.ErrNoMessages
+	}
+
+	// Convert response to our format
+	var msgs []llm.Message
+	for _, choice := range completion.Choices {
+		var calls []*llm.FunctionCall
+		for _, tc := range choice.Message.ToolCalls {
+			calls = append(calls, &llm.FunctionCall{
+				ID:        tc.ID,
+				Name:      tc.Function.Name,
+				Arguments: tc.Function.Arguments,
+			})
+		}
+
+		choiceMsgs, err := toMessages(choice.Message.Content, string(choice.FinishReason), calls, params.Functions)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, choiceMsgs...)
+	}
+
+	// Build the response
+	ret := &llm.CompleteResponse{
+		Messages: msgs,
+		Usage: &llm.Usage{
+			Unit:             "tokens",
+			PromptTokens:     int(completion.Usage.PromptTokens),
+			CompletionTokens: int(completion.Usage.CompletionTokens),
+			TotalTokens:      int(completion.Usage.TotalTokens),
+		},
+		Metadata: llm.CompletionMetadata{
+			ModelName:     c.cfg.Model.Name,
+			FinishReason:  string(completion.Choices[0].FinishReason),
+			CompletionID:  completion.ID,
+			LatencyMs:     time.Since(startTime).Milliseconds(),
+			ProcessedAt:   time.Now().UTC(),
+			RequestTokens: int(completion.Usage.PromptTokens),
+		},
+	}
+
+	return ret, nil
+}
+
+// Stream processes the given parameters, delivering each generated delta to fn,
+// and returns the aggregated completion response.
+func (c *Client) Stream(
+	ctx context.Context,
+	params llm.CompleteParams,
+	fn llm.StreamFunc,
+) (*llm.CompleteResponse, error) {
+	startTime := time.Now()
+
+	completionParams, err := c.buildParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ask for a final chunk carrying token usage
+	completionParams.StreamOptions = openai.F(openai.ChatCompletionStreamOptionsParam{
+		IncludeUsage: openai.F(true),
+	})
+
+	stream := c.client.Chat.Completions.NewStreaming(ctx, completionParams)
+	defer stream.Close()
+
+	// Accumulate the deltas while forwarding them to the caller
+	var (
+		content      strings.Builder
+		calls        []*llm.FunctionCall
+		callIndex    = map[int64]*llm.FunctionCall{}
+		completionID string
+		finishReason string
+		usage        openai.CompletionUsage
+	)
+	for stream.Next() {
+		chunk := stream.Current()
+		if chunk.ID != "" {
+			completionID = chunk.ID
+		}
+		if chunk.Usage.TotalTokens > 0 {
+			usage = chunk.Usage
+		}
+
+		// Only the first choice is streamed
+		if len(chunk.Choices) == 0 {
+			continue
+		}
+		choice := chunk.Choices[0]
+		if choice.FinishReason != "" {
+			finishReason = string(choice.FinishReason)
+		}
+
+		// Tool calls arrive in fragments keyed by index: the first carries the
+		// ID and name, the following ones the rest of the arguments
+		for _, tc := range choice.Delta.ToolCalls {
+			call, ok := callIndex[tc.Index]
+			if !ok {
+				call = &llm.FunctionCall{}
+				callIndex[tc.Index] = call
+				calls = append(calls, call)
+			}
+			if tc.ID != "" {
+				call.ID = tc.ID
+			}
+			call.Name += tc.Function.Name
+			call.Arguments += tc.Function.Arguments
+		}
+
+		if choice.Delta.Content == "" && choice.FinishReason == "" {
+			continue
+		}
+
+		content.WriteString(choice.Delta.Content)
+		if err := fn(llm.StreamChunk{
+			Delta:        choice.Delta.Content,
+			FinishReason: string(choice.FinishReason),
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return nil, c.handleError(err)
+	}
+
+	msgs, err := toMessages(content.String(), finishReason, calls, params.Functions)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the response
+	ret := &llm.CompleteResponse{
+		Messages: msgs,
+		Usage: &llm.Usage{
+			Unit:             "tokens",
+			PromptTokens:     int(usage.PromptTokens),
+			CompletionTokens: int(usage.CompletionTokens),
+			TotalTokens:      int(usage.TotalTokens),
+		},
+		Metadata: llm.CompletionMetadata{
+			ModelName:     c.cfg.Model.Name,
+			FinishReason:  finishReason,
+			CompletionID:  completionID,
+			LatencyMs:     time.Since(startTime).Milliseconds(),
+			ProcessedAt:   time.Now().UTC(),
+			RequestTokens: int(usage.PromptTokens),
+		},
+	}
+
+	return ret, nil
+}
+
+// toMessages converts the content and tool calls of a choice to messages. Each
+// tool call becomes an assistant message with a function call, the first one
+// carrying the content. The calls are validated against the offered functions.
+func toMessages(content, finishReason string, calls []*llm.FunctionCall, functions []llm.FunctionDefinition) ([]llm.Message, error) {
+	// A filtered completion is cut where the filter matched
+	if finishReason == FinishReasonContentFilter {
+		return nil, fmt.Errorf("%w: the completion was filtered", llm.ErrContentFiltered)
+	}
+
+	msg := llm.Message{
+		Role:         llm.RoleAssistant,
+		Content:      content,
+		FinishReason: finishReason,
+		Completed:    true,
+	}
+	if len(calls) == 0 {
+		return []llm.Message{msg}, nil
+	}
+
+	msgs := make([]llm.Message, 0, len(calls))
+	for i, call := range calls {
+		if err := llm.ValidateFunctionCall(call, functions); err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			msg.Content = ""
+		}
+		msg.FunctionCall = call
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+// buildParams converts the given parameters to an OpenAI chat completion request.
+func (c *Client) buildParams(params llm.CompleteParams) (openai.ChatCompletionNewParams, error) {
 	// Convert messages to OpenAI format
-	var messages []openai.ChatCompletionMessageParamUnion
-	for _, m := range params.Messages {
+	var (
+		messages []openai.ChatCompletionMessageParamUnion
+		calls    = -1 // Index of the assistant message collecting consecutive tool calls
+		pending  = map[string][]string{}
+	)
+	for i, m := range params.Messages {
+		if m.Role != llm.RoleAssistant || m.FunctionCall == nil {
+			calls = -1
+		}
+
 		switch m.Role {
 		case llm.RoleUser:
 			messages = append(messages, openai.UserMessage(m.Content))
 		case llm.RoleAssistant:
-			messages = append(messages, openai.AssistantMessage(m.Content))
+			if m.FunctionCall == nil {
+				messages = append(messages, openai.AssistantMessage(m.Content))
+				continue
+			}
+
+			// Tool calls need an ID to be answered. Calls from providers
+			// without IDs are given one from their position.
+			id := m.FunctionCall.ID
+			if id == "" {
+				id = fmt.Sprintf("call_%d", i)
+			}
+			pending[m.FunctionCall.Name] = append(pending[m.FunctionCall.Name], id)
+			call := openai.ChatCompletionMessageToolCallParam{
+				ID:   openai.F(id),
+				Type: openai.F(openai.ChatCompletionMessageToolCallTypeFunction),
+				Function: openai.F(openai.ChatCompletionMessageToolCallFunctionParam{
+					Name:      openai.F(m.FunctionCall.Name),
+					Arguments: openai.F(m.FunctionCall.Arguments),
+				}),
+			}
+
+			// Parallel calls are sent as one assistant message
+			if calls >= 0 {
+				msg := messages[calls].(openai.ChatCompletionAssistantMessageParam)
+				msg.ToolCalls = openai.F(append(msg.ToolCalls.Value, call))
+				messages[calls] = msg
+				continue
+			}
+
+			msg := openai.ChatCompletionAssistantMessageParam{
+				Role: openai.F(openai.ChatCompletionAssistantMessageParamRoleAssistant),
+			}
+			if m.Content != "" {
+				msg = openai.AssistantMessage(m.Content)
+			}
+			msg.ToolCalls = openai.F([]openai.ChatCompletionMessageToolCallParam{call})
+			calls = len(messages)
+			messages = append(messages, msg)
 		case llm.RoleSystem:
 			messages = append(messages, openai.SystemMessage(m.Content))
 		case llm.RoleFunction:
-			// Current version doesn't support function messages directly
-			// Fallback to a user message
-			messages = append(messages, openai.UserMessage(fmt.Sprintf("Function %s returned: %s", m.Name, m.Content)))
+			// Results without a call ID answer the oldest call of the function
+			id := m.CallID
+			if ids := pending[m.Name]; id == "" && len(ids) > 0 {
+				id = ids[0]
+			}
+			pending[m.Name] = slices.DeleteFunc(pending[m.Name], func(p string) bool { return p == id })
+			if id == "" {
+				return openai.ChatCompletionNewParams{}, fmt.Errorf("%w: no call of %s to answer", llm.ErrInvalidFunctionCall, m.Name)
+			}
+			messages = append(messages, openai.ToolMessage(id, m.Content))
 		default:
-			return nil, fmt.Errorf("unsupported role: %s", m.Role)
+			return openai.ChatCompletionNewParams{}, fmt.Errorf("unsupported role: %s", m.Role)
 		}
 	}
 
@@ -141,69 +416,72 @@ func (c *Client) Complete(
 		completionParams.N = openai.Int(int64(*params.N))
 	}
 
-	// Note: Function calling and JSON mode are not directly supported in this version
-	// of the library in the same way. We would need to adapt this based on the actual
-	// library version and capabilities.
-
-	// Make the API call
-	completion, err := c.client.Chat.Completions.New(ctx, completionParams)
-	if err != nil {
-		return nil, c.handleError(err)
-	}
-
-	// Check for empty response
-	if len(completion.Choices) == 0 {
-		return nil, llm.ErrNoMessages
+	// JSON mode constrains the output to a valid JSON object.
+	// The messages must instruct the model to produce JSON.
+	if params.JSONMode {
+		completionParams.ResponseFormat = openai.F[openai.ChatCompletionNewParamsResponseFormatUnion](openai.ResponseFormatJSONObjectParam{
+			Type: openai.F(openai.ResponseFormatJSONObjectTypeJSONObject),
+		})
 	}
 
-	// Convert response to our format
-	var msgs []llm.Message
-	for _, choice := range completion.Choices {
-		msg := llm.Message{
-			Role:         llm.Role(choice.Message.Role),
-			Content:      choice.Message.Content,
-			FinishReason: string(choice.FinishReason),
-			Completed:    true,
+	if len(params.Functions) > 0 {
+		tools, err := toTools(params.Functions)
+		if err != nil {
+			return openai.ChatCompletionNewParams{}, err
 		}
+		completionParams.Tools = openai.F(tools)
 
-		// Note: Function calls handling would need to be adapted based on
-		// the actual library version
-
-		msgs = append(msgs, msg)
+		// One call per turn keeps the conversation simple to continue
+		completionParams.ParallelToolCalls = openai.F(false)
 	}
 
-	// Build the response
-	ret := &llm.CompleteResponse{
-		Messages: msgs,
-		Usage: &llm.Usage{
-			Unit:             "tokens",
-			PromptTokens:     int(completion.Usage.PromptTokens),
-			CompletionTokens: int(completion.Usage.CompletionTokens),
-			TotalTokens:      int(completion.Usage.TotalTokens),
-		},
-		Metadata: llm.CompletionMetadata{
-			ModelName:     c.cfg.Model.Name,
-			FinishReason:  string(completion.Choices[0].FinishReason),
-			CompletionID:  completion.ID,
-			LatencyMs:     time.Since(startTime).Milliseconds(),
-			ProcessedAt:   time.Now().UTC(),
-			RequestTokens: int(completion.Usage.PromptTokens),
-		},
-	}
+	return completionParams, nil
+}
 
-	return ret, nil
+// toTools converts function definitions to OpenAI tools.
+func toTools(functions []llm.FunctionDefinition) ([]openai.ChatCompletionToolParam, error) {
+	tools := make([]openai.ChatCompletionToolParam, 0, len(functions))
+	for _, f := range functions {
+		// The parameters are a JSON schema of any Go type
+		var parameters openai.FunctionParameters
+		if f.Parameters != nil {
+			data, err := json.Marshal(f.Parameters)
+			if err != nil {
+				return nil, fmt.Errorf("%w: parameters of %s: %w", llm.ErrInvalidArguments, f.Name, err)
+			}
+			if err := json.Unmarshal(data, &parameters); err != nil {
+				return nil, fmt.Errorf("%w: parameters of %s are not a JSON schema object: %w", llm.ErrInvalidArguments, f.Name, err)
+			}
+		}
+
+		function := openai.FunctionDefinitionParam{
+			Name: openai.F(f.Name),
+		}
+		if parameters != nil {
+			function.Parameters = openai.F(parameters)
+		}
+		if f.Description != "" {
+			function.Description = openai.F(f.Description)
+		}
+		tools = append(tools, openai.ChatCompletionToolParam{
+			Type:     openai.F(openai.ChatCompletionToolTypeFunction),
+			Function: openai.F(function),
+		})
+	}
+	return tools, nil
 }
 
 // handleError converts OpenAI errors to our error types.
 func (c *Client) handleError(err error) error {
 	var apiErr *openai.Error
 	if errors.As(err, &apiErr) {
-		llmErr := llm.NewLLMError(err, string(llm.OpenAI), c.cfg.Model.Name).
+		code := errorCode(apiErr)
+		llmErr := llm.NewLLMError(err, string(c.cfg.Model.Provider), c.cfg.Model.Name).
 			WithStatusCode(apiErr.StatusCode).
-			WithErrorCode(apiErr.Code)
+			WithErrorCode(code)
 
 		// Map specific error codes
-		switch apiErr.Code {
+		switch code {
 		case ErrCodeContextLengthExceeded:
 			llmErr.Err = llm.ErrContextLengthExceeded
 			return llmErr
@@ -217,6 +495,9 @@ func (c *Client) handleError(err error) error {
 			llmErr.Err = llm.ErrRateLimited
 			llmErr.Retryable = true
 			return llmErr
+		case ErrCodeContentFilter:
+			llmErr.Err = llm.ErrContentFiltered
+			return llmErr
 		case ErrCodeInsufficientQuota:
 			llmErr.Err = errors.New("insufficient quota")
 			return llmErr
@@ -239,11 +520,32 @@ func (c *Client) handleError(err error) error {
 	}
 
 	// For non-API errors, wrap in our error type
-	return llm.NewLLMError(err, string(llm.OpenAI), c.cfg.Model.Name)
+	return llm.NewLLMError(err, string(c.cfg.Model.Provider), c.cfg.Model.Name)
+}
+
+// errorCode returns the code of an API error. The SDK decodes the error from the
+// root of the response body, while the API wraps it in an "error" object.
+func errorCode(apiErr *openai.Error) string {
+	if apiErr.Code != "" {
+		return apiErr.Code
+	}
+
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(apiErr.JSON.RawJSON()), &body); err != nil {
+		return ""
+	}
+	return body.Error.Code
 }
 
 func init() {
 	llm.RegisterLLM(New, []llm.Model{
 		ModelGPT4o,
 	})
+	llm.RegisterProvider(llm.OpenAICompatible, New)
+	llm.RegisterProvider(llm.Azure, NewAzure)
+	llm.RegisterDiscoverer(llm.OpenAICompatible, Discover)
 }
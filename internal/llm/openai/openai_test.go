@@ -0,0 +1,282 @@
+package openai
+
+import (
+	"bytes"
+	"coda/internal/config"
+	"coda/internal/llm"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// chatRequest is the part of a chat completion request checked by the tests.
+type chatRequest struct {
+	Model    string        `json:"model"`
+	Messages []chatMessage `json:"messages"`
+	Tools    []struct {
+		Type     string `json:"type"`
+		Function struct {
+			Name       string         `json:"name"`
+			Parameters map[string]any `json:"parameters"`
+		} `json:"function"`
+	} `json:"tools"`
+	ParallelToolCalls *bool `json:"parallel_tool_calls"`
+}
+
+// chatMessage is a message of a chat completion request.
+type chatMessage struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content,omitempty"`
+	ToolCalls  []toolCall      `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+}
+
+// toolCall is a tool call of an assistant message.
+type toolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// text returns the text of a message content, sent as a string or as text parts.
+func (m chatMessage) text() string {
+	var s string
+	if err := json.Unmarshal(m.Content, &s); err == nil {
+		return s
+	}
+	var parts []struct {
+		Text string `json:"text"`
+	}
+	_ = json.Unmarshal(m.Content, &parts)
+	var b strings.Builder
+	for _, p := range parts {
+		b.WriteString(p.Text)
+	}
+	return b.String()
+}
+
+// recordedRequest is a request received by the test server.
+type recordedRequest struct {
+	path   string
+	query  string
+	header http.Header
+	body   chatRequest
+}
+
+// newTestServer starts an httptest stand-in of the chat completions API
+// answering with handler, and returns the requests it received.
+func newTestServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *[]recordedRequest) {
+	t.Helper()
+
+	var requests []recordedRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := recordedRequest{path: r.URL.Path, query: r.URL.RawQuery, header: r.Header.Clone()}
+		if err := json.NewDecoder(r.Body).Decode(&req.body); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		requests = append(requests, req)
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+// newTestClient returns a client of an OpenAI-compatible endpoint served by handler.
+func newTestClient(t *testing.T, handler http.HandlerFunc) (llm.LLM, *[]recordedRequest) {
+	t.Helper()
+
+	srv, requests := newTestServer(t, handler)
+	client, err := New(llm.Config{
+		Model:      llm.Model{Provider: llm.OpenAICompatible, Endpoint: "test", Name: "qwen2.5-coder"},
+		APIKeyFunc: func() string { return "test-key" },
+		LLMConfig: config.LLM{Endpoints: []config.Endpoint{
+			{Name: "test", BaseURL: srv.URL + "/v1", APIKey: "test-key"},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return client, requests
+}
+
+// respond returns a handler writing the body with the status code.
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, body)
+	}
+}
+
+// sse returns a handler streaming the chunks, followed by the end of the stream.
+func sse(chunks ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, c := range chunks {
+			var data bytes.Buffer
+			_ = json.Compact(&data, []byte(c))
+			fmt.Fprintf(w, "data: %s\n\n", data.Bytes())
+		}
+		_, _ = io.WriteString(w, "data: [DONE]\n\n")
+	}
+}
+
+var readFile = llm.FunctionDefinition{
+	Name:        "read_file",
+	Description: "Read a file",
+	Parameters:  map[string]any{"type": "object", "properties": map[string]any{"path": map[string]any{"type": "string"}}},
+}
+
+func TestCompleteToolCalls(t *testing.T) {
+	client, requests := newTestClient(t, respond(http.StatusOK, `{
+		"id": "chatcmpl-1", "object": "chat.completion", "created": 1, "model": "qwen2.5-coder",
+		"choices": [{
+			"index": 0,
+			"message": {"role": "assistant", "content": "Reading both files.", "tool_calls": [
+				{"id": "call_a", "type": "function", "function": {"name": "read_file", "arguments": "{\"path\":\"a.go\"}"}},
+				{"id": "call_b", "type": "function", "function": {"name": "read_file", "arguments": "{\"path\":\"b.go\"}"}}
+			]},
+			"finish_reason": "tool_calls"
+		}],
+		"usage": {"prompt_tokens": 30, "completion_tokens": 20, "total_tokens": 50}
+	}`))
+
+	call := &llm.FunctionCall{ID: "call_1", Name: "read_file", Arguments: `{"path":"main.go"}`}
+	res, err := client.Complete(context.Background(), llm.CompleteParams{
+		Messages: []llm.Message{
+			llm.NewUserMessage("Review main.go"),
+			{Role: llm.RoleAssistant, FunctionCall: call},
+			// Calls of providers without IDs are given one from their position
+			{Role: llm.RoleAssistant, FunctionCall: &llm.FunctionCall{Name: "read_file", Arguments: `{"path":"go.mod"}`}},
+			llm.NewFunctionResultMessage(call, "package main"),
+			// Results without a call ID answer the oldest pending call
+			llm.NewFunctionMessage("read_file", "module coda"),
+		},
+		Functions: []llm.FunctionDefinition{readFile},
+	})
+	if err != nil {
+		t.Fatalf("Complete() error = %v", err)
+	}
+
+	req := (*requests)[0]
+	if req.path != "/v1/chat/completions" || req.header.Get("Authorization") != "Bearer test-key" {
+		t.Errorf("request %s with headers %v", req.path, req.header)
+	}
+
+	// Parallel calls are sent as one assistant message
+	var got []string
+	for _, m := range req.body.Messages {
+		desc := m.Role + ":" + m.text()
+		for _, tc := range m.ToolCalls {
+			desc += fmt.Sprintf(" %s/%s/%s(%s)", tc.ID, tc.Type, tc.Function.Name, tc.Function.Arguments)
+		}
+		if m.ToolCallID != "" {
+			desc += " <- " + m.ToolCallID
+		}
+		got = append(got, desc)
+	}
+	want := []string{
+		"user:Review main.go",
+		`assistant: call_1/function/read_file({"path":"main.go"}) call_2/function/read_file({"path":"go.mod"})`,
+		"tool:package main <- call_1",
+		"tool:module coda <- call_2",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("messages = %q, want %q", got, want)
+	}
+
+	if len(req.body.Tools) != 1 || req.body.Tools[0].Type != "function" || req.body.Tools[0].Function.Name != "read_file" || req.body.Tools[0].Function.Parameters == nil {
+		t.Errorf("tools = %+v", req.body.Tools)
+	}
+	if req.body.ParallelToolCalls == nil || *req.body.ParallelToolCalls {
+		t.Errorf("parallel_tool_calls = %v, want false", req.body.ParallelToolCalls)
+	}
+
+	calls := res.FunctionCalls()
+	if len(res.Messages) != 2 || len(calls) != 2 {
+		t.Fatalf("got %d messages with %d calls, want 2 and 2", len(res.Messages), len(calls))
+	}
+	if res.Messages[0].Content != "Reading both files." || res.Messages[1].Content != "" {
+		t.Errorf("contents = %q, %q", res.Messages[0].Content, res.Messages[1].Content)
+	}
+	wantCall := llm.FunctionCall{ID: "call_b", Name: "read_file", Arguments: `{"path":"b.go"}`}
+	if calls[0].ID != "call_a" || *calls[1] != wantCall {
+		t.Errorf("calls = %+v, %+v", calls[0], calls[1])
+	}
+}
+
+func TestCompleteUnansweredResult(t *testing.T) {
+	client, requests := newTestClient(t, respond(http.StatusOK, `{}`))
+
+	_, err := client.Complete(context.Background(), llm.CompleteParams{
+		Messages:  []llm.Message{llm.NewUserMessage("Hi"), llm.NewFunctionMessage("read_file", "package main")},
+		Functions: []llm.FunctionDefinition{readFile},
+	})
+	if err == nil || len(*requests) != 0 {
+		t.Errorf("Complete() error = %v after %d requests, want an error before sending", err, len(*requests))
+	}
+}
+
+func TestStreamToolCalls(t *testing.T) {
+	chunk := func(delta, finishReason string) string {
+		if finishReason == "" {
+			finishReason = "null"
+		}
+		return fmt.Sprintf(`{"id": "chatcmpl-2", "object": "chat.completion.chunk", "created": 1, "model": "qwen2.5-coder",
+			"choices": [{"index": 0, "delta": %s, "finish_reason": %s}]}`, delta, finishReason)
+	}
+	client, _ := newTestClient(t, sse(
+		chunk(`{"role": "assistant", "content": "Reading"}`, ""),
+		chunk(`{"content": " files."}`, ""),
+		// Fragments of the calls are keyed by index, the first ones carrying the ID and name
+		chunk(`{"tool_calls": [{"index": 0, "id": "call_a", "type": "function", "function": {"name": "read_file", "arguments": ""}}]}`, ""),
+		chunk(`{"tool_calls": [{"index": 0, "function": {"arguments": "{\"path\":"}}]}`, ""),
+		chunk(`{"tool_calls": [{"index": 1, "id": "call_b", "type": "function", "function": {"name": "read_file", "arguments": "{\"path\":\"b.go\"}"}}]}`, ""),
+		chunk(`{"tool_calls": [{"index": 0, "function": {"arguments": "\"a.go\"}"}}]}`, ""),
+		chunk(`{}`, `"tool_calls"`),
+		`{"id": "chatcmpl-2", "object": "chat.completion.chunk", "created": 1, "model": "qwen2.5-coder", "choices": [],
+			"usage": {"prompt_tokens": 30, "completion_tokens": 20, "total_tokens": 50}}`,
+	))
+
+	var deltas []string
+	res, err := client.Stream(context.Background(), llm.CompleteParams{
+		Messages:  []llm.Message{llm.NewUserMessage("Review a.go and b.go")},
+		Functions: []llm.FunctionDefinition{readFile},
+	}, func(chunk llm.StreamChunk) error {
+		deltas = append(deltas, chunk.Delta)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Stream() error = %v", err)
+	}
+
+	if got := strings.Join(deltas, ""); got != "Reading files." {
+		t.Errorf("streamed %q, want %q", got, "Reading files.")
+	}
+
+	calls := res.FunctionCalls()
+	want := []llm.FunctionCall{
+		{ID: "call_a", Name: "read_file", Arguments: `{"path":"a.go"}`},
+		{ID: "call_b", Name: "read_file", Arguments: `{"path":"b.go"}`},
+	}
+	if len(calls) != len(want) {
+		t.Fatalf("got %d calls, want %d", len(calls), len(want))
+	}
+	for i, call := range calls {
+		if *call != want[i] {
+			t.Errorf("call %d = %+v, want %+v", i, *call, want[i])
+		}
+	}
+	if res.Messages[0].Content != "Reading files." || res.Metadata.FinishReason != "tool_calls" || res.Usage.TotalTokens != 50 {
+		t.Errorf("response = %+v, usage %+v", res.Messages[0], res.Usage)
+	}
+}
@@ -0,0 +1,68 @@
+package llm
+
+import "unicode/utf8"
+
+// Token budget defaults
+const (
+	// DefaultContextWindow is the context window assumed for models that do not declare one.
+	DefaultContextWindow = 8192
+
+	// maxOutputReserve is the number of tokens kept free for the response,
+	// at most a quarter of the context window.
+	maxOutputReserve = 4096
+
+	// messageOverhead approximates the tokens used by the role and delimiters of a message.
+	messageOverhead = 4
+)
+
+// EstimateTokens estimates the number of tokens of a text without a tokenizer.
+// It approximates the BPE tokenizers of GPT and Llama models, which use about
+// one token per 3.5 characters of English text and code, and about one token
+// per character of Japanese and other non-ASCII text. The estimate errs on the
+// high side so that prompts sized with it fit.
+func EstimateTokens(text string) int {
+	var ascii, other int
+	for _, r := range text {
+		if r < utf8.RuneSelf {
+			ascii++
+		} else {
+			other++
+		}
+	}
+	return (ascii*2+6)/7 + other
+}
+
+// EstimateMessageTokens estimates the number of prompt tokens of the messages.
+func EstimateMessageTokens(messages []Message) int {
+	tokens := 0
+	for _, m := range messages {
+		tokens += messageOverhead + EstimateTokens(m.Content)
+	}
+	return tokens
+}
+
+// ContextTokens returns the context window of the model, or DefaultContextWindow
+// if the model does not declare one.
+func (m Model) ContextTokens() int {
+	if m.ContextWindow > 0 {
+		return m.ContextWindow
+	}
+	return DefaultContextWindow
+}
+
+// InputTokenLimit returns the number of prompt tokens that can be sent to the model
+// while leaving room for its response.
+func (m Model) InputTokenLimit() int {
+	window := m.ContextTokens()
+	reserve := min(maxOutputReserve, window/4)
+	if m.MaxToken > 0 && m.MaxToken < window {
+		reserve = min(reserve, m.MaxToken)
+	}
+	return window - reserve
+}
+
+// Fits reports whether the messages fit in the context window of the model
+// with room left for the response.
+func (m Model) Fits(messages []Message) bool {
+	return EstimateMessageTokens(messages) <= m.InputTokenLimit()
+}
@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the completions.
+var tracer = otel.Tracer("coda/internal/llm")
+
+// NewHTTPClient creates the HTTP client of a provider. Each request is
+// recorded as a span of the completion attempt sending it, and carries the
+// trace context to the provider.
+func NewHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	}
+}
+
+// modelAttributes returns the span attributes identifying a model, following
+// the OpenTelemetry semantic conventions for generative AI.
+func modelAttributes(model Model) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("gen_ai.system", string(model.Provider)),
+		attribute.String("gen_ai.request.model", model.Name),
+	}
+	if model.Endpoint != "" {
+		attrs = append(attrs, attribute.String("gen_ai.endpoint", model.Endpoint))
+	}
+	return attrs
+}
+
+// endSpan ends the span of a completion, with its usage or its error.
+func endSpan(span trace.Span, res *CompleteResponse, err error) {
+	defer span.End()
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return
+	}
+
+	if res.Metadata.FinishReason != "" {
+		span.SetAttributes(attribute.StringSlice("gen_ai.response.finish_reasons", []string{res.Metadata.FinishReason}))
+	}
+	if res.Usage != nil {
+		span.SetAttributes(
+			attribute.Int("gen_ai.usage.input_tokens", res.Usage.PromptTokens),
+			attribute.Int("gen_ai.usage.output_tokens", res.Usage.CompletionTokens),
+		)
+	}
+}
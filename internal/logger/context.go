@@ -2,6 +2,8 @@ package logger
 
 import (
 	"context"
+
+	"go.opentelemetry.io/otel/trace"
 )
 
 // WithLogger returns a new context with the given logger.
@@ -10,12 +12,17 @@ func WithLogger(ctx context.Context, logger Logger) context.Context {
 }
 
 // FromContext returns a logger from the given context.
+// The logs are tagged with the IDs of the trace and span of the context, if any.
 func FromContext(ctx context.Context) Logger {
-	value := ctx.Value(contextKey{})
-	if value == nil {
-		return Default
+	logger := Default
+	if value := ctx.Value(contextKey{}); value != nil {
+		logger = value.(Logger)
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		logger = logger.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
 	}
-	return value.(Logger)
+	return logger
 }
 
 // Debug logs a message with debug level.
@@ -3,6 +3,7 @@ package logger
 import (
 	"coda/internal/config"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"regexp"
@@ -77,6 +78,12 @@ func New(cfg *config.Config) Logger {
 	return Default
 }
 
+// NewConsole creates a logger writing human-readable logs at or above the given level to w.
+// It is used by the command-line client, which keeps stdout for its own output.
+func NewConsole(w io.Writer, level slog.Level) Logger {
+	return &appLogger{logger: slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level}))}
+}
+
 // Debugf implements logger.Logger.
 func (a *appLogger) Debugf(format string, v ...any) {
 	if a.group == "" {
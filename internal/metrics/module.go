@@ -0,0 +1,15 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/fx"
+)
+
+// Module is the fx module for the metrics package.
+// It provides the registry, also as the Registerer of the collectors.
+var Module = fx.Module("metrics",
+	fx.Provide(
+		NewRegistry,
+		func(reg *prometheus.Registry) prometheus.Registerer { return reg },
+	),
+)
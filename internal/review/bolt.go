@@ -0,0 +1,134 @@
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultStorePath is the review database file used when no path is configured.
+const DefaultStorePath = "data/reviews.db"
+
+// reviewsBucket holds the reviews keyed by ID.
+var reviewsBucket = []byte("reviews")
+
+// BoltStore is a Store backed by an embedded bbolt database file.
+// Reviews are stored as JSON under their ID, so the key order follows the creation order.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+var _ Store = (*BoltStore)(nil)
+
+// NewBoltStore opens (or creates) the review database at the given path.
+func NewBoltStore(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("creating store directory: %w", err)
+	}
+
+	// Fail instead of blocking forever when another process holds the file lock
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(reviewsBucket)
+		return err
+	}); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("creating bucket: %w", err)
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Save creates or replaces a review.
+func (s *BoltStore) Save(_ context.Context, review *Review) error {
+	data, err := json.Marshal(review)
+	if err != nil {
+		return fmt.Errorf("encoding review: %w", err)
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(reviewsBucket).Put([]byte(review.ID), data)
+	})
+}
+
+// Get returns the review with the given ID or ErrNotFound.
+func (s *BoltStore) Get(_ context.Context, id string) (*Review, error) {
+	var review *Review
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(reviewsBucket).Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+
+		var err error
+		review, err = decodeReview(data)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
+
+// List returns up to limit reviews of the owner, newest first.
+func (s *BoltStore) List(_ context.Context, owner string, limit int) ([]*Review, error) {
+	var reviews []*Review
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(reviewsBucket).Cursor()
+		for k, v := c.Last(); k != nil; k, v = c.Prev() {
+			if limit > 0 && len(reviews) >= limit {
+				break
+			}
+
+			review, err := decodeReview(v)
+			if err != nil {
+				return fmt.Errorf("review %s: %w", k, err)
+			}
+			if review.Owner != owner {
+				continue
+			}
+			reviews = append(reviews, review)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reviews, nil
+}
+
+// Delete removes the review with the given ID or returns ErrNotFound.
+func (s *BoltStore) Delete(_ context.Context, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(reviewsBucket)
+		if b.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+// Close closes the underlying database.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// decodeReview decodes a review stored as JSON.
+func decodeReview(data []byte) (*Review, error) {
+	var review Review
+	if err := json.Unmarshal(data, &review); err != nil {
+		return nil, fmt.Errorf("decoding review: %w", err)
+	}
+	return &review, nil
+}
@@ -0,0 +1,136 @@
+package review
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestBoltStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("SaveGetListDelete", func(t *testing.T) {
+		t.Parallel()
+
+		store := openTestStore(t)
+
+		first := NewReview("print(1)", "python", "medium", "medium", "GPT-4o", "# first", nil)
+		second := NewReview("print(2)", "python", "high", "low", "GPT-4o", "# second", nil)
+		for _, r := range []*Review{first, second} {
+			if err := store.Save(ctx, r); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+
+		got, err := store.Get(ctx, first.ID)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got.Code != first.Code || got.Result != first.Result || got.Model != first.Model {
+			t.Errorf("Expected review %+v, got %+v", first, got)
+		}
+
+		// Newest reviews are listed first
+		reviews, err := store.List(ctx, "", 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(reviews) != 2 || reviews[0].ID != second.ID || reviews[1].ID != first.ID {
+			t.Errorf("Expected reviews [%s %s], got %v", second.ID, first.ID, reviews)
+		}
+
+		reviews, err = store.List(ctx, "", 1)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(reviews) != 1 || reviews[0].ID != second.ID {
+			t.Errorf("Expected only the newest review, got %v", reviews)
+		}
+
+		if err := store.Delete(ctx, first.ID); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := store.Get(ctx, first.ID); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound after delete, got %v", err)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		t.Parallel()
+
+		store := openTestStore(t)
+
+		if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+		if err := store.Delete(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Owners", func(t *testing.T) {
+		t.Parallel()
+
+		store := openTestStore(t)
+
+		mine := NewReview("print(1)", "python", "medium", "medium", "GPT-4o", "# mine", nil)
+		mine.Owner = "key:a"
+		theirs := NewReview("print(2)", "python", "medium", "medium", "GPT-4o", "# theirs", nil)
+		theirs.Owner = "key:b"
+		for _, r := range []*Review{mine, theirs} {
+			if err := store.Save(ctx, r); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+
+		reviews, err := store.List(ctx, "key:a", 0)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(reviews) != 1 || reviews[0].ID != mine.ID {
+			t.Errorf("Expected only the reviews of the owner, got %v", reviews)
+		}
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "nested", "reviews.db")
+		store, err := NewBoltStore(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		r := NewReview("print(1)", "python", "medium", "medium", "GPT-4o", "# result", nil)
+		if err := store.Save(ctx, r); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if err := store.Close(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		// Reviews survive a restart
+		store, err = NewBoltStore(path)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		defer store.Close()
+
+		if _, err := store.Get(ctx, r.ID); err != nil {
+			t.Errorf("Expected review to be persisted, got %v", err)
+		}
+	})
+}
+
+// openTestStore opens a store in a temporary directory that is closed with the test.
+func openTestStore(t *testing.T) *BoltStore {
+	t.Helper()
+
+	store, err := NewBoltStore(filepath.Join(t.TempDir(), "reviews.db"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+
+	return store
+}
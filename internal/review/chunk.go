@@ -0,0 +1,166 @@
+package review
+
+import (
+	"coda/internal/llm"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxChunks bounds the number of chunks of a review, and so the number of model calls.
+const maxChunks = 20
+
+// minChunkTokens is the smallest chunk budget worth reviewing. Models with a
+// smaller context window cannot review code in chunks.
+const minChunkTokens = 256
+
+// chunk is a part of code too large to be reviewed at once.
+type chunk struct {
+	StartLine int // Line number of the first line of the chunk in the code
+	Lines     []string
+}
+
+// EndLine returns the line number of the last line of the chunk in the code.
+func (c chunk) EndLine() int {
+	return c.StartLine + len(c.Lines) - 1
+}
+
+// declarationPattern matches lines starting a top-level declaration in common languages.
+var declarationPattern = regexp.MustCompile(`^(?:(?:export|public|private|protected|internal|static|abstract|final|async|unsafe|pub(?:\([a-z]+\))?)\s+)*(?:func|def|class|type|var|const|let|function|interface|struct|enum|impl|fn|trait|module|object|record|namespace)\b`)
+
+// splitChunks splits code into chunks of at most budget tokens once their lines
+// are numbered. Chunks break before top-level declarations so that functions and
+// classes stay whole; declarations larger than the budget break at blank lines,
+// or between lines as a last resort.
+func splitChunks(code string, budget int) []chunk {
+	lines := strings.Split(code, "\n")
+	width := len(strconv.Itoa(len(lines)))
+
+	tokens := make([]int, len(lines))
+	for i, line := range lines {
+		tokens[i] = llm.EstimateTokens(fmt.Sprintf("%*d | %s\n", width, i+1, line))
+	}
+	size := func(r lineRange) int {
+		n := 0
+		for _, t := range tokens[r.start:r.end] {
+			n += t
+		}
+		return n
+	}
+
+	// Split the code into units that fit in the budget, preferring larger units
+	var units []lineRange
+	for _, decl := range splitBefore(lines, lineRange{0, len(lines)}, isDeclarationStart) {
+		if size(decl) <= budget {
+			units = append(units, decl)
+			continue
+		}
+		for _, block := range splitBefore(lines, decl, isBlockStart) {
+			if size(block) <= budget {
+				units = append(units, block)
+				continue
+			}
+			for i := block.start; i < block.end; i++ {
+				units = append(units, lineRange{i, i + 1})
+			}
+		}
+	}
+
+	// Pack consecutive units into chunks
+	var chunks []chunk
+	start, used := 0, 0
+	for _, u := range units {
+		n := size(u)
+		if used > 0 && used+n > budget {
+			chunks = append(chunks, chunk{StartLine: start + 1, Lines: lines[start:u.start]})
+			start, used = u.start, 0
+		}
+		used += n
+	}
+	return append(chunks, chunk{StartLine: start + 1, Lines: lines[start:]})
+}
+
+// lineRange is the range of lines [start, end) of the code.
+type lineRange struct {
+	start, end int
+}
+
+// splitBefore splits a range of lines before each line for which isBreak returns true.
+func splitBefore(lines []string, r lineRange, isBreak func(lines []string, i int) bool) []lineRange {
+	var ranges []lineRange
+	start := r.start
+	for i := r.start + 1; i < r.end; i++ {
+		if isBreak(lines, i) {
+			ranges = append(ranges, lineRange{start, i})
+			start = i
+		}
+	}
+	return append(ranges, lineRange{start, r.end})
+}
+
+// isDeclarationStart reports whether a top-level declaration starts at line i,
+// including the comments and decorators attached to it.
+func isDeclarationStart(lines []string, i int) bool {
+	if i > 0 && isAttached(lines[i-1]) {
+		return false
+	}
+
+	j := i
+	for j < len(lines) && isAttached(lines[j]) {
+		j++
+	}
+	return j < len(lines) && declarationPattern.MatchString(lines[j])
+}
+
+// isAttached reports whether a line is a comment or decorator that belongs
+// to the declaration following it.
+func isAttached(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	for _, prefix := range []string{"//", "#", "/*", "*", "@", "--"} {
+		if strings.HasPrefix(trimmed, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isBlockStart reports whether line i starts a block of code after a blank line.
+func isBlockStart(lines []string, i int) bool {
+	return strings.TrimSpace(lines[i-1]) == "" && strings.TrimSpace(lines[i]) != ""
+}
+
+// numberChunk prefixes each line of a chunk with its line number in the code.
+func numberChunk(c chunk, lineCount int) string {
+	width := len(strconv.Itoa(lineCount))
+
+	var b strings.Builder
+	for i, line := range c.Lines {
+		fmt.Fprintf(&b, "%*d | %s\n", width, c.StartLine+i, line)
+	}
+	return b.String()
+}
+
+// dedupeFindings removes findings reported more than once, such as issues with
+// code spanning two chunks. Findings are duplicates when they have the same
+// category, location and message, ignoring case and spacing.
+func dedupeFindings(findings []Finding) []Finding {
+	type key struct {
+		category   Category
+		file       string
+		start, end int
+		message    string
+	}
+
+	seen := make(map[key]bool, len(findings))
+	deduped := make([]Finding, 0, len(findings))
+	for _, f := range findings {
+		k := key{f.Category, f.File, f.StartLine, f.EndLine, strings.Join(strings.Fields(strings.ToLower(f.Message)), " ")}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		deduped = append(deduped, f)
+	}
+	return deduped
+}
@@ -0,0 +1,110 @@
+package review
+
+import (
+	"coda/internal/llm"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSplitChunks(t *testing.T) {
+	body := strings.Repeat("    x = compute(x)\n", 10)
+	code := "import os\n\n" +
+		"# Adds numbers\n@decorator\ndef add(a, b):\n" + body + "\n" +
+		"class Greeter:\n" + body + "\n" +
+		"def main():\n" + body
+
+	chunks := splitChunks(code, 100)
+
+	var starts []string
+	for _, c := range chunks {
+		starts = append(starts, c.Lines[0])
+		if got := llm.EstimateTokens(numberChunk(c, strings.Count(code, "\n")+1)); got > 100 {
+			t.Errorf("chunk at line %d has %d tokens, want at most 100", c.StartLine, got)
+		}
+	}
+	want := []string{"import os", "class Greeter:", "def main():"}
+	if strings.Join(starts, "|") != strings.Join(want, "|") {
+		t.Errorf("chunks start with %q, want %q", starts, want)
+	}
+
+	last := chunks[len(chunks)-1]
+	if last.EndLine() != strings.Count(code, "\n")+1 {
+		t.Errorf("last chunk ends at line %d, want %d", last.EndLine(), strings.Count(code, "\n")+1)
+	}
+}
+
+// chunkCompleter reviews each chunk with a finding on its first line, and
+// fails to merge the reviews so that the local merge is used.
+type chunkCompleter struct {
+	llm.Completer
+	calls int
+}
+
+func (c *chunkCompleter) Complete(_ context.Context, params llm.CompleteParams, _ llm.Model) (*llm.CompleteResponse, error) {
+	c.calls++
+	if strings.Contains(params.Messages[0].Content, "were reviewed separately") {
+		return nil, llm.ErrServiceUnavailable
+	}
+
+	var first int
+	_, _ = fmt.Sscanf(strings.TrimSpace(params.Messages[1].Content), "%d", &first)
+	content := fmt.Sprintf(`{"summary": "part", "findings": [
+		{"severity": "minor", "category": "style", "startLine": %d, "message": "Part starts here"},
+		{"severity": "major", "category": "bug", "startLine": 1, "message": "Same  issue"}
+	]}`, first)
+	return &llm.CompleteResponse{Messages: []llm.Message{{Content: content}}}, nil
+}
+
+func TestServiceChunkedReview(t *testing.T) {
+	completer := &chunkCompleter{}
+	svc := NewService(completer, NewMemoryStore(), nil)
+
+	model := DefaultModel
+	model.ContextWindow = 2048
+	code := strings.Repeat("def f():\n"+strings.Repeat("    pass\n", 20)+"\n", 40)
+
+	rv, err := svc.Review(context.Background(), Request{Code: code, Language: "python", Model: model})
+	if err != nil {
+		t.Fatalf("Review() error = %v", err)
+	}
+
+	chunks := completer.calls - 1
+	if chunks < 2 {
+		t.Fatalf("got %d chunks, want at least 2", chunks)
+	}
+	// One finding per chunk, plus the finding repeated by every chunk
+	if len(rv.Findings) != chunks+1 {
+		t.Errorf("got %d findings, want %d", len(rv.Findings), chunks+1)
+	}
+}
+
+func TestServiceChunkedStreamProgress(t *testing.T) {
+	completer := &chunkCompleter{}
+	svc := NewService(completer, NewMemoryStore(), nil)
+
+	model := DefaultModel
+	model.ContextWindow = 2048
+	code := strings.Repeat("def f():\n"+strings.Repeat("    pass\n", 20)+"\n", 40)
+
+	var updates []StreamUpdate
+	_, err := svc.StreamReview(context.Background(), Request{Code: code, Language: "python", Model: model}, func(update StreamUpdate) error {
+		updates = append(updates, update)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamReview() error = %v", err)
+	}
+
+	// Each chunk is reported as it is reviewed, and the structured result is not streamed
+	chunks := completer.calls - 1
+	if len(updates) != chunks {
+		t.Fatalf("got %d updates, want %d", len(updates), chunks)
+	}
+	for i, update := range updates {
+		if want := (StreamUpdate{Part: i + 1, Parts: chunks}); update != want {
+			t.Errorf("update %d = %+v, want %+v", i, update, want)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package review
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// DiffLanguage is the language of reviews of unified diffs.
+const DiffLanguage = "diff"
+
+// ErrInvalidDiff is returned when a diff review is requested for input that is not a unified diff.
+var ErrInvalidDiff = errors.New("invalid diff")
+
+// LineKind is the kind of a line in a diff hunk.
+type LineKind int
+
+// Kinds of diff lines
+const (
+	LineContext LineKind = iota // Unchanged line shown for context
+	LineAdded                   // Line added in the new file
+	LineRemoved                 // Line removed from the old file
+)
+
+// Diff is a parsed unified diff, as produced by diff -u, git diff or git format-patch.
+type Diff struct {
+	Files []FileDiff
+}
+
+// FileDiff contains the changes to a single file.
+type FileDiff struct {
+	OldPath string // Empty for added files
+	NewPath string // Empty for deleted files
+	Hunks   []Hunk
+}
+
+// Path returns the path of the file in the new tree, or in the old tree for deleted files.
+func (f *FileDiff) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+// Hunk is a contiguous set of changes with its surrounding context.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Section            string // Text after the hunk range, usually the enclosing function
+	Lines              []DiffLine
+}
+
+// DiffLine is a line of a hunk.
+type DiffLine struct {
+	Kind    LineKind
+	Content string
+	OldLine int // Line number in the old file, 0 for added lines
+	NewLine int // Line number in the new file, 0 for removed lines
+}
+
+// hunkHeaderPattern matches a hunk header such as "@@ -1,3 +1,4 @@ func main()".
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@ ?(.*)$`)
+
+// LooksLikeDiff reports whether the text appears to be a unified diff.
+func LooksLikeDiff(text string) bool {
+	var header, hunk bool
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "), strings.HasPrefix(line, "+++ "):
+			header = true
+		case hunkHeaderPattern.MatchString(strings.TrimRight(line, "\r")):
+			hunk = true
+		}
+		if header && hunk {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseDiff parses a unified diff. Text outside of the file diffs, such as the
+// commit message and diffstat of git format-patch output, is ignored.
+func ParseDiff(text string) (*Diff, error) {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+
+	diff := &Diff{}
+	var file *FileDiff
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			diff.Files = append(diff.Files, FileDiff{})
+			file = &diff.Files[len(diff.Files)-1]
+			file.OldPath, file.NewPath = parseGitDiffPaths(line)
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			// Plain unified diffs have no "diff --git" line before the file headers
+			if file == nil || len(file.Hunks) > 0 {
+				diff.Files = append(diff.Files, FileDiff{})
+				file = &diff.Files[len(diff.Files)-1]
+			}
+			file.OldPath = parseFilePath(line[4:])
+			file.NewPath = parseFilePath(lines[i+1][4:])
+			i++
+
+		case strings.HasPrefix(line, "@@ "):
+			if file == nil {
+				return nil, fmt.Errorf("%w: hunk before file header at line %d", ErrInvalidDiff, i+1)
+			}
+			hunk, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			file.Hunks = append(file.Hunks, hunk)
+			i = next - 1
+		}
+	}
+
+	// Drop files without textual changes, such as binary files and mode changes
+	diff.Files = slices.DeleteFunc(diff.Files, func(f FileDiff) bool {
+		return len(f.Hunks) == 0
+	})
+	if len(diff.Files) == 0 {
+		return nil, fmt.Errorf("%w: no changes found", ErrInvalidDiff)
+	}
+
+	return diff, nil
+}
+
+// parseHunk parses the hunk starting at lines[start] and returns the index of the line following it.
+func parseHunk(lines []string, start int) (Hunk, int, error) {
+	m := hunkHeaderPattern.FindStringSubmatch(lines[start])
+	if m == nil {
+		return Hunk{}, 0, fmt.Errorf("%w: malformed hunk header at line %d", ErrInvalidDiff, start+1)
+	}
+
+	hunk := Hunk{
+		OldStart: atoiDefault(m[1], 0),
+		OldLines: atoiDefault(m[2], 1),
+		NewStart: atoiDefault(m[3], 0),
+		NewLines: atoiDefault(m[4], 1),
+		Section:  strings.TrimSpace(m[5]),
+	}
+
+	oldLine, newLine := hunk.OldStart, hunk.NewStart
+	oldLeft, newLeft := hunk.OldLines, hunk.NewLines
+	i := start + 1
+	for ; i < len(lines) && (oldLeft > 0 || newLeft > 0); i++ {
+		line := lines[i]
+
+		// Editors sometimes strip the leading space of empty context lines
+		if line == "" {
+			line = " "
+		}
+
+		switch line[0] {
+		case ' ':
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: LineContext, Content: line[1:], OldLine: oldLine, NewLine: newLine})
+			oldLine, newLine = oldLine+1, newLine+1
+			oldLeft, newLeft = oldLeft-1, newLeft-1
+		case '+':
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: LineAdded, Content: line[1:], NewLine: newLine})
+			newLine++
+			newLeft--
+		case '-':
+			hunk.Lines = append(hunk.Lines, DiffLine{Kind: LineRemoved, Content: line[1:], OldLine: oldLine})
+			oldLine++
+			oldLeft--
+		case '\\':
+			// "\ No newline at end of file"
+		default:
+			return Hunk{}, 0, fmt.Errorf("%w: unexpected line in hunk at line %d", ErrInvalidDiff, i+1)
+		}
+	}
+
+	// Skip a marker following the last line of the hunk
+	if i < len(lines) && strings.HasPrefix(lines[i], `\`) {
+		i++
+	}
+
+	return hunk, i, nil
+}
+
+// parseGitDiffPaths extracts the paths from a "diff --git a/old b/new" line.
+// The paths are refined by the "---" and "+++" headers when present.
+func parseGitDiffPaths(line string) (string, string) {
+	rest := strings.TrimPrefix(line, "diff --git ")
+	if i := strings.Index(rest, " b/"); i >= 0 {
+		return strings.TrimPrefix(rest[:i], "a/"), rest[i+3:]
+	}
+	return "", ""
+}
+
+// parseFilePath extracts the path from a "---" or "+++" header, returning
+// an empty path for /dev/null.
+func parseFilePath(header string) string {
+	// Timestamps are separated by a tab
+	path, _, _ := strings.Cut(header, "\t")
+	path = strings.TrimSpace(path)
+	if path == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		return path[2:]
+	}
+	return path
+}
+
+// atoiDefault parses a decimal number, returning def for an empty string.
+func atoiDefault(s string, def int) int {
+	if s == "" {
+		return def
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return n
+}
+
+// Annotate renders the diff for the model, prefixing each line with its
+// new-file line number so that findings can refer to it.
+func (d *Diff) Annotate() string {
+	var b strings.Builder
+	for _, file := range d.Files {
+		fmt.Fprintf(&b, "### File: %s\n", file.Path())
+		for _, hunk := range file.Hunks {
+			fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@ %s\n", hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines, hunk.Section)
+			for _, line := range hunk.Lines {
+				switch line.Kind {
+				case LineAdded:
+					fmt.Fprintf(&b, "+ %5d | %s\n", line.NewLine, line.Content)
+				case LineRemoved:
+					fmt.Fprintf(&b, "- %5s | %s\n", "", line.Content)
+				default:
+					fmt.Fprintf(&b, "  %5d | %s\n", line.NewLine, line.Content)
+				}
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// MapFindings maps findings back to the files and added lines of the diff.
+// The file of a finding is resolved against the paths of the diff, and its line
+// range is narrowed to the added lines it covers. Findings about lines that were
+// not added are moved to the closest added line of the same hunk, or lose their
+// line range when they are outside of all hunks.
+func (d *Diff) MapFindings(findings []Finding) []Finding {
+	mapped := make([]Finding, 0, len(findings))
+	for _, f := range findings {
+		file := d.resolveFile(f.File)
+		if file == nil {
+			f.StartLine, f.EndLine = 0, 0
+			mapped = append(mapped, f)
+			continue
+		}
+
+		f.File = file.Path()
+		if f.StartLine > 0 {
+			f.StartLine, f.EndLine = file.mapLineRange(f.StartLine, f.EndLine)
+		}
+		mapped = append(mapped, f)
+	}
+	return mapped
+}
+
+// resolveFile finds the file a finding refers to.
+func (d *Diff) resolveFile(path string) *FileDiff {
+	paths := make([]string, len(d.Files))
+	for i := range d.Files {
+		paths[i] = d.Files[i].Path()
+	}
+
+	if i := matchPath(paths, path); i >= 0 {
+		return &d.Files[i]
+	}
+	return nil
+}
+
+// mapLineRange narrows a new-file line range to the added lines it covers.
+func (f *FileDiff) mapLineRange(start, end int) (int, int) {
+	if end < start {
+		end = start
+	}
+
+	var first, last int
+	for _, hunk := range f.Hunks {
+		for _, line := range hunk.Lines {
+			if line.Kind != LineAdded || line.NewLine < start || line.NewLine > end {
+				continue
+			}
+			if first == 0 {
+				first = line.NewLine
+			}
+			last = line.NewLine
+		}
+	}
+	if first > 0 {
+		return first, last
+	}
+
+	// Move the finding to the closest added line of the hunk containing it
+	for _, hunk := range f.Hunks {
+		if start < hunk.NewStart || start >= hunk.NewStart+hunk.NewLines {
+			continue
+		}
+
+		closest, distance := 0, 0
+		for _, line := range hunk.Lines {
+			if line.Kind != LineAdded {
+				continue
+			}
+			if d := abs(line.NewLine - start); closest == 0 || d < distance {
+				closest, distance = line.NewLine, d
+			}
+		}
+		return closest, closest
+	}
+
+	return 0, 0
+}
+
+// abs returns the absolute value of n.
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,131 @@
+package review
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const formatPatch = `From 1a2b3c4d Mon Sep 17 00:00:00 2001
+From: Jane Doe <jane@example.com>
+Subject: [PATCH] Fix sum
+
+---
+ calc.py | 3 ++-
+ 1 file changed, 2 insertions(+), 1 deletion(-)
+
+diff --git a/calc.py b/calc.py
+index 83db48f..bf269f4 100644
+--- a/calc.py
++++ b/calc.py
+@@ -1,4 +1,5 @@ import math
+ def calculate_sum(numbers):
+-    total = 1
++    total = 0
++    # Sum all numbers
+     for num in numbers:
+         total += num
+diff --git a/new.py b/new.py
+new file mode 100644
+--- /dev/null
++++ b/new.py
+@@ -0,0 +1,2 @@
++print("hello")
++print("world")
+\ No newline at end of file
+diff --git a/logo.png b/logo.png
+Binary files a/logo.png and b/logo.png differ
+--
+2.39.0
+`
+
+func TestParseDiff(t *testing.T) {
+	diff, err := ParseDiff(formatPatch)
+	if err != nil {
+		t.Fatalf("ParseDiff() error = %v", err)
+	}
+
+	if len(diff.Files) != 2 {
+		t.Fatalf("got %d files, want 2", len(diff.Files))
+	}
+	if got := diff.Files[0]; got.OldPath != "calc.py" || got.NewPath != "calc.py" {
+		t.Errorf("file 0 paths = %q, %q", got.OldPath, got.NewPath)
+	}
+	if got := diff.Files[1]; got.OldPath != "" || got.Path() != "new.py" {
+		t.Errorf("file 1 paths = %q, %q", got.OldPath, got.Path())
+	}
+
+	hunk := diff.Files[0].Hunks[0]
+	if hunk.Section != "import math" {
+		t.Errorf("section = %q", hunk.Section)
+	}
+	want := []DiffLine{
+		{Kind: LineContext, Content: "def calculate_sum(numbers):", OldLine: 1, NewLine: 1},
+		{Kind: LineRemoved, Content: "    total = 1", OldLine: 2},
+		{Kind: LineAdded, Content: "    total = 0", NewLine: 2},
+		{Kind: LineAdded, Content: "    # Sum all numbers", NewLine: 3},
+		{Kind: LineContext, Content: "    for num in numbers:", OldLine: 3, NewLine: 4},
+		{Kind: LineContext, Content: "        total += num", OldLine: 4, NewLine: 5},
+	}
+	if !reflect.DeepEqual(hunk.Lines, want) {
+		t.Errorf("lines = %+v, want %+v", hunk.Lines, want)
+	}
+}
+
+func TestParseDiffPlain(t *testing.T) {
+	const plain = "--- old/main.go\t2024-01-01 00:00:00\n+++ new/main.go\t2024-01-02 00:00:00\n@@ -3 +3 @@\n-a\n+b\n"
+
+	diff, err := ParseDiff(plain)
+	if err != nil {
+		t.Fatalf("ParseDiff() error = %v", err)
+	}
+	if got := diff.Files[0].Path(); got != "new/main.go" {
+		t.Errorf("path = %q", got)
+	}
+	if got := diff.Files[0].Hunks[0].Lines[1]; got.NewLine != 3 {
+		t.Errorf("added line = %+v", got)
+	}
+}
+
+func TestParseDiffInvalid(t *testing.T) {
+	for _, text := range []string{"print(1)", "--- a/x\n+++ b/x\n@@ -1 +1 @@\n*oops\n"} {
+		if _, err := ParseDiff(text); !errors.Is(err, ErrInvalidDiff) {
+			t.Errorf("ParseDiff(%q) error = %v, want ErrInvalidDiff", text, err)
+		}
+	}
+}
+
+func TestLooksLikeDiff(t *testing.T) {
+	if !LooksLikeDiff(formatPatch) {
+		t.Error("LooksLikeDiff(formatPatch) = false")
+	}
+	if LooksLikeDiff("x = 1\n# @@ -1 +1 @@\n") {
+		t.Error("LooksLikeDiff(code) = true")
+	}
+}
+
+func TestMapFindings(t *testing.T) {
+	diff, err := ParseDiff(formatPatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	findings := []Finding{
+		{File: "b/calc.py", StartLine: 1, EndLine: 4, Message: "Narrowed to added lines"},
+		{File: "calc.py", StartLine: 5, Message: "Moved to closest added line"},
+		{File: "src/new.py", StartLine: 2, Message: "Resolved by suffix"},
+		{File: "calc.py", StartLine: 40, Message: "Outside of hunks"},
+		{File: "other.py", StartLine: 2, Message: "Unknown file"},
+	}
+	want := []Finding{
+		{File: "calc.py", StartLine: 2, EndLine: 3, Message: "Narrowed to added lines"},
+		{File: "calc.py", StartLine: 3, EndLine: 3, Message: "Moved to closest added line"},
+		{File: "new.py", StartLine: 2, EndLine: 2, Message: "Resolved by suffix"},
+		{File: "calc.py", Message: "Outside of hunks"},
+		{File: "other.py", Message: "Unknown file"},
+	}
+
+	if got := diff.MapFindings(findings); !reflect.DeepEqual(got, want) {
+		t.Errorf("MapFindings() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,227 @@
+package review
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// File is a source file of a module review.
+type File struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// Limits of the files uploaded for a module review
+const (
+	MaxUploadSize = 10 << 20  // Maximum size in bytes of an upload, compressed archives included
+	MaxFileSize   = 256 << 10 // Files larger than this are skipped
+	MaxFiles      = 200       // Maximum number of files of a module review
+
+	MaxArchiveSize    = 64 << 20 // Maximum uncompressed size in bytes of the entries read from an archive, skipped ones included
+	MaxArchiveEntries = 10000    // Maximum number of entries of an archive, skipped ones included
+)
+
+// Errors returned when reading uploaded files
+var (
+	ErrInvalidArchive = errors.New("invalid archive")
+	ErrTooManyFiles   = fmt.Errorf("too many files: the limit is %d", MaxFiles)
+
+	errArchiveTooLarge = fmt.Errorf("%w: more than %d bytes uncompressed", ErrInvalidArchive, MaxArchiveSize)
+)
+
+// ignoredDirs are directories whose files are not reviewed, such as
+// dependencies and build output.
+var ignoredDirs = []string{"node_modules", "vendor", "dist", "build", "target", "__pycache__", "__MACOSX"}
+
+// ReadUpload reads an uploaded file. Zip and tar archives, optionally
+// gzip-compressed, are expanded to the source files they contain; binary files,
+// files larger than MaxFileSize and files in hidden or dependency directories
+// are skipped.
+func ReadUpload(name string, r io.Reader) ([]File, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxUploadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", name, err)
+	}
+	if len(data) > MaxUploadSize {
+		return nil, fmt.Errorf("%s is too large: the limit is %d bytes", name, MaxUploadSize)
+	}
+
+	switch lower := strings.ToLower(name); {
+	case strings.HasSuffix(lower, ".zip"):
+		return readZip(data)
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+		}
+		defer gz.Close()
+		return readTar(gz)
+	case strings.HasSuffix(lower, ".tar"):
+		return readTar(bytes.NewReader(data))
+	}
+
+	if !isText(data) || len(data) > MaxFileSize {
+		return nil, nil
+	}
+	return []File{{Path: path.Base(name), Content: string(data)}}, nil
+}
+
+// IsArchive reports whether the file name is that of an archive read by ReadUpload.
+func IsArchive(name string) bool {
+	lower := strings.ToLower(name)
+	for _, ext := range []string{".zip", ".tar.gz", ".tgz", ".tar"} {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// readZip reads the source files of a zip archive.
+func readZip(data []byte) ([]File, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+	}
+
+	if len(zr.File) > MaxArchiveEntries {
+		return nil, fmt.Errorf("%w: more than %d entries", ErrInvalidArchive, MaxArchiveEntries)
+	}
+
+	var (
+		files []File
+		size  int64
+	)
+	for _, zf := range zr.File {
+		if zf.FileInfo().IsDir() || skipPath(zf.Name) || zf.UncompressedSize64 > MaxFileSize {
+			continue
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, MaxFileSize+1))
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+		}
+		// Binary files are decompressed before being skipped
+		if size += int64(len(content)); size > MaxArchiveSize {
+			return nil, errArchiveTooLarge
+		}
+
+		if files, err = appendFile(files, zf.Name, content); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// readTar reads the source files of a tar archive.
+func readTar(r io.Reader) ([]File, error) {
+	tr := tar.NewReader(r)
+
+	var (
+		files   []File
+		entries int
+		size    int64
+	)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+		}
+
+		// Skipped entries are decompressed too when moving to the next one
+		if entries++; entries > MaxArchiveEntries {
+			return nil, fmt.Errorf("%w: more than %d entries", ErrInvalidArchive, MaxArchiveEntries)
+		}
+		if size += max(hdr.Size, 0); size > MaxArchiveSize {
+			return nil, errArchiveTooLarge
+		}
+		if hdr.Typeflag != tar.TypeReg || skipPath(hdr.Name) || hdr.Size > MaxFileSize {
+			continue
+		}
+
+		content, err := io.ReadAll(io.LimitReader(tr, MaxFileSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidArchive, err)
+		}
+
+		if files, err = appendFile(files, hdr.Name, content); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// appendFile appends a file read from an archive unless it is binary or too large.
+func appendFile(files []File, name string, content []byte) ([]File, error) {
+	if !isText(content) || len(content) > MaxFileSize {
+		return files, nil
+	}
+	if len(files) == MaxFiles {
+		return nil, ErrTooManyFiles
+	}
+	return append(files, File{Path: path.Clean(strings.TrimPrefix(name, "./")), Content: string(content)}), nil
+}
+
+// skipPath reports whether an archive entry is in a hidden or dependency directory,
+// or is a hidden file.
+func skipPath(name string) bool {
+	for _, part := range strings.Split(path.Clean(strings.TrimPrefix(name, "./")), "/") {
+		if strings.HasPrefix(part, ".") || slices.Contains(ignoredDirs, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// isText reports whether the content looks like a text file.
+func isText(content []byte) bool {
+	return utf8.Valid(content) && !bytes.ContainsRune(content, 0)
+}
+
+// languages maps file extensions to the languages of the review prompt.
+var languages = map[string]string{
+	".c":     "c",
+	".cc":    "cpp",
+	".cpp":   "cpp",
+	".cs":    "csharp",
+	".diff":  DiffLanguage,
+	".go":    "go",
+	".h":     "c",
+	".hpp":   "cpp",
+	".java":  "java",
+	".js":    "javascript",
+	".jsx":   "javascript",
+	".kt":    "kotlin",
+	".patch": DiffLanguage,
+	".php":   "php",
+	".py":    "python",
+	".rb":    "ruby",
+	".rs":    "rust",
+	".sh":    "shell",
+	".sql":   "sql",
+	".swift": "swift",
+	".ts":    "typescript",
+	".tsx":   "typescript",
+}
+
+// LanguageFromPath infers the language of a file from its extension.
+// It returns an empty language for unknown extensions.
+func LanguageFromPath(name string) string {
+	return languages[strings.ToLower(path.Ext(name))]
+}
@@ -0,0 +1,357 @@
+package review
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Severity indicates how important a finding is.
+type Severity string
+
+// Severity levels, from the most to the least important.
+const (
+	SeverityCritical Severity = "critical" // Bugs or vulnerabilities that must be fixed
+	SeverityMajor    Severity = "major"    // Significant problems that should be fixed
+	SeverityMinor    Severity = "minor"    // Small problems worth fixing
+	SeverityInfo     Severity = "info"     // Suggestions and remarks
+)
+
+// Severities lists the severity levels, from the most to the least important.
+var Severities = []Severity{SeverityCritical, SeverityMajor, SeverityMinor, SeverityInfo}
+
+// Category classifies the kind of problem a finding is about.
+type Category string
+
+// Finding categories.
+const (
+	CategoryBug             Category = "bug"
+	CategorySecurity        Category = "security"
+	CategoryPerformance     Category = "performance"
+	CategoryStyle           Category = "style"
+	CategoryMaintainability Category = "maintainability"
+	CategoryOther           Category = "other"
+)
+
+// Categories lists the finding categories.
+var Categories = []Category{
+	CategoryBug,
+	CategorySecurity,
+	CategoryPerformance,
+	CategoryStyle,
+	CategoryMaintainability,
+	CategoryOther,
+}
+
+// Finding is a single issue reported by a review.
+type Finding struct {
+	Severity   Severity `json:"severity"`
+	Category   Category `json:"category"`
+	File       string   `json:"file,omitempty"`      // Path of the file the finding is about, for diff reviews
+	StartLine  int      `json:"startLine,omitempty"` // First line of the code the finding is about, 0 if unknown
+	EndLine    int      `json:"endLine,omitempty"`   // Last line of the code the finding is about, 0 if unknown
+	Message    string   `json:"message"`
+	Suggestion string   `json:"suggestion,omitempty"` // Suggested fix
+}
+
+// Report is the structured output of a review.
+type Report struct {
+	Summary  string    `json:"summary"`
+	Findings []Finding `json:"findings"`
+}
+
+// FindingGroup is a set of findings with the same severity.
+type FindingGroup struct {
+	Severity Severity
+	Findings []Finding
+}
+
+// GroupBySeverity groups the findings by severity, from the most to the least important.
+// Severities without findings are omitted.
+func GroupBySeverity(findings []Finding) []FindingGroup {
+	var groups []FindingGroup
+	for _, severity := range Severities {
+		var group []Finding
+		for _, f := range findings {
+			if f.Severity == severity {
+				group = append(group, f)
+			}
+		}
+		if len(group) > 0 {
+			groups = append(groups, FindingGroup{Severity: severity, Findings: group})
+		}
+	}
+	return groups
+}
+
+// ErrInvalidReport is returned when the model output cannot be parsed as a report.
+var ErrInvalidReport = errors.New("invalid review report")
+
+// ReportSchema is the JSON schema of the report the model is asked to produce.
+const ReportSchema = `{
+  "type": "object",
+  "required": ["summary", "findings"],
+  "properties": {
+    "summary": {"type": "string", "description": "Overall assessment of the code in Markdown"},
+    "findings": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "required": ["severity", "category", "message"],
+        "properties": {
+          "severity": {"enum": ["critical", "major", "minor", "info"]},
+          "category": {"enum": ["bug", "security", "performance", "style", "maintainability", "other"]},
+          "file": {"type": "string", "description": "Path of the file the finding is about, when reviewing a diff"},
+          "startLine": {"type": "integer", "minimum": 1},
+          "endLine": {"type": "integer", "minimum": 1},
+          "message": {"type": "string", "description": "Description of the issue"},
+          "suggestion": {"type": "string", "description": "Suggested fix, may include code"}
+        }
+      }
+    }
+  }
+}`
+
+// ReportInstructions returns the prompt instructions asking for a report in JSON.
+func ReportInstructions() string {
+	return "Respond only with a JSON object matching the following JSON schema, without any surrounding text.\n" +
+		"Each finding describes a single issue. Refer to the line numbers shown at the beginning of each line of the code.\n\n" +
+		ReportSchema + "\n\n"
+}
+
+// NumberLines prefixes each line of the code with its line number,
+// so the model can refer to line ranges in its findings.
+func NumberLines(code string) string {
+	lines := strings.Split(code, "\n")
+	width := len(strconv.Itoa(len(lines)))
+
+	var b strings.Builder
+	for i, line := range lines {
+		fmt.Fprintf(&b, "%*d | %s\n", width, i+1, line)
+	}
+	return b.String()
+}
+
+// rawReport is a lenient representation of the report produced by a model.
+type rawReport struct {
+	Summary  string       `json:"summary"`
+	Findings []rawFinding `json:"findings"`
+	Issues   []rawFinding `json:"issues"` // Common alternative to "findings"
+}
+
+// rawFinding is a lenient representation of a finding produced by a model.
+type rawFinding struct {
+	Severity    string      `json:"severity"`
+	Category    string      `json:"category"`
+	File        string      `json:"file"`
+	Path        string      `json:"path"` // Common alternative to "file"
+	StartLine   lenientInt  `json:"startLine"`
+	EndLine     lenientInt  `json:"endLine"`
+	Line        lenientInt  `json:"line"`
+	Lines       lenientInts `json:"lines"`
+	Message     string      `json:"message"`
+	Description string      `json:"description"`
+	Suggestion  string      `json:"suggestion"`
+	Fix         string      `json:"fix"`
+}
+
+// ParseReport parses the report produced by a model for code with the given number of lines.
+// Model output is repaired where possible: surrounding text and code fences are removed,
+// unknown severities and categories are mapped to the closest known value, and line
+// ranges are ordered and clamped to the code. Findings without a message are dropped.
+func ParseReport(content string, lineCount int) (*Report, error) {
+	data := extractJSON(content)
+	if data == nil {
+		return nil, fmt.Errorf("%w: no JSON value found", ErrInvalidReport)
+	}
+
+	var raw rawReport
+	if data[0] == '[' {
+		// A bare list of findings
+		if err := json.Unmarshal(data, &raw.Findings); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidReport, err)
+		}
+	} else if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidReport, err)
+	}
+
+	report := &Report{
+		Summary:  strings.TrimSpace(raw.Summary),
+		Findings: []Finding{},
+	}
+	for _, rf := range append(raw.Findings, raw.Issues...) {
+		if f, ok := rf.normalize(lineCount); ok {
+			report.Findings = append(report.Findings, f)
+		}
+	}
+
+	return report, nil
+}
+
+// normalize converts a raw finding to a Finding, reporting false if it has no message.
+func (rf rawFinding) normalize(lineCount int) (Finding, bool) {
+	f := Finding{
+		Severity:   normalizeSeverity(rf.Severity),
+		Category:   normalizeCategory(rf.Category),
+		File:       strings.TrimSpace(firstNonEmpty(rf.File, rf.Path)),
+		Message:    strings.TrimSpace(firstNonEmpty(rf.Message, rf.Description)),
+		Suggestion: strings.TrimSpace(firstNonEmpty(rf.Suggestion, rf.Fix)),
+	}
+	if f.Message == "" {
+		return Finding{}, false
+	}
+
+	start, end := int(rf.StartLine), int(rf.EndLine)
+	if start == 0 && len(rf.Lines) > 0 {
+		start, end = rf.Lines[0], rf.Lines[len(rf.Lines)-1]
+	}
+	if start == 0 {
+		start = int(rf.Line)
+	}
+	f.StartLine, f.EndLine = normalizeLineRange(start, end, lineCount)
+
+	return f, true
+}
+
+// normalizeLineRange orders a line range and clamps it to the code.
+// Ranges entirely outside of the code are dropped.
+func normalizeLineRange(start, end, lineCount int) (int, int) {
+	if start <= 0 && end <= 0 {
+		return 0, 0
+	}
+	if start <= 0 {
+		start = end
+	}
+	if end <= 0 {
+		end = start
+	}
+	if end < start {
+		start, end = end, start
+	}
+	if lineCount > 0 {
+		if start > lineCount {
+			return 0, 0
+		}
+		end = min(end, lineCount)
+	}
+	return start, end
+}
+
+// normalizeSeverity maps a severity produced by a model to a known severity.
+func normalizeSeverity(s string) Severity {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "critical", "blocker", "fatal":
+		return SeverityCritical
+	case "major", "high", "error":
+		return SeverityMajor
+	case "minor", "medium", "moderate", "warning":
+		return SeverityMinor
+	default:
+		return SeverityInfo
+	}
+}
+
+// normalizeCategory maps a category produced by a model to a known category.
+func normalizeCategory(s string) Category {
+	c := Category(strings.ToLower(strings.TrimSpace(s)))
+	for _, known := range Categories {
+		if c == known {
+			return c
+		}
+	}
+
+	switch c {
+	case "bugs", "correctness", "error", "logic":
+		return CategoryBug
+	case "vulnerability":
+		return CategorySecurity
+	case "perf", "efficiency":
+		return CategoryPerformance
+	case "readability", "convention", "formatting", "naming":
+		return CategoryStyle
+	case "maintenance", "design", "best-practice", "best practice":
+		return CategoryMaintainability
+	default:
+		return CategoryOther
+	}
+}
+
+// extractJSON returns the outermost JSON object or array in the content,
+// ignoring any surrounding text such as Markdown code fences.
+func extractJSON(content string) []byte {
+	data := []byte(strings.TrimSpace(content))
+
+	start := bytes.IndexAny(data, "{[")
+	if start < 0 {
+		return nil
+	}
+	closing := byte('}')
+	if data[start] == '[' {
+		closing = ']'
+	}
+	end := bytes.LastIndexByte(data, closing)
+	if end < start {
+		return nil
+	}
+
+	return data[start : end+1]
+}
+
+// firstNonEmpty returns the first non-empty string.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// lenientInt decodes an integer that may be encoded as a JSON number, string or null.
+type lenientInt int
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (i *lenientInt) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*i = 0
+		return nil
+	}
+
+	// Models sometimes write ranges such as "10-12"; keep the first line
+	s, _, _ = strings.Cut(s, "-")
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		// Ignore values that are not line numbers instead of rejecting the report
+		*i = 0
+		return nil //nolint:nilerr
+	}
+	*i = lenientInt(f)
+	return nil
+}
+
+// lenientInts decodes a list of line numbers, accepting a single number as well.
+type lenientInts []int
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (l *lenientInts) UnmarshalJSON(data []byte) error {
+	var values []lenientInt
+	if err := json.Unmarshal(data, &values); err != nil {
+		var v lenientInt
+		if err := v.UnmarshalJSON(data); err != nil {
+			return err
+		}
+		values = []lenientInt{v}
+	}
+
+	*l = nil
+	for _, v := range values {
+		if v > 0 {
+			*l = append(*l, int(v))
+		}
+	}
+	return nil
+}
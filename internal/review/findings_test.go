@@ -0,0 +1,107 @@
+package review
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseReport(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		lineCount int
+		want      *Report
+		wantErr   bool
+	}{
+		{
+			name:      "Valid",
+			content:   `{"summary": "Looks good", "findings": [{"severity": "major", "category": "bug", "startLine": 2, "endLine": 3, "message": "Off by one", "suggestion": "Use <="}]}`,
+			lineCount: 10,
+			want: &Report{
+				Summary: "Looks good",
+				Findings: []Finding{
+					{Severity: SeverityMajor, Category: CategoryBug, StartLine: 2, EndLine: 3, Message: "Off by one", Suggestion: "Use <="},
+				},
+			},
+		},
+		{
+			name:      "CodeFenceAndSurroundingText",
+			content:   "Here is the review:\n```json\n{\"summary\": \"ok\", \"findings\": []}\n```",
+			lineCount: 10,
+			want:      &Report{Summary: "ok", Findings: []Finding{}},
+		},
+		{
+			name:      "RepairsFields",
+			content:   `{"summary": "s", "issues": [{"severity": "HIGH", "category": "readability", "line": "4", "description": "Unclear name", "fix": "Rename"}, {"severity": "warning", "category": "unknown", "startLine": 9, "endLine": 7, "message": "Reversed"}]}`,
+			lineCount: 10,
+			want: &Report{
+				Summary: "s",
+				Findings: []Finding{
+					{Severity: SeverityMajor, Category: CategoryStyle, StartLine: 4, EndLine: 4, Message: "Unclear name", Suggestion: "Rename"},
+					{Severity: SeverityMinor, Category: CategoryOther, StartLine: 7, EndLine: 9, Message: "Reversed"},
+				},
+			},
+		},
+		{
+			name:      "ClampsLinesAndDropsEmptyMessages",
+			content:   `[{"severity": "critical", "category": "security", "startLine": 8, "endLine": 20, "message": "Injection"}, {"severity": "info", "startLine": 50, "message": "Out of range"}, {"severity": "info", "message": " "}]`,
+			lineCount: 10,
+			want: &Report{
+				Findings: []Finding{
+					{Severity: SeverityCritical, Category: CategorySecurity, StartLine: 8, EndLine: 10, Message: "Injection"},
+					{Severity: SeverityInfo, Category: CategoryOther, Message: "Out of range"},
+				},
+			},
+		},
+		{
+			name:    "NotJSON",
+			content: "# Review\nEverything looks fine.",
+			wantErr: true,
+		},
+		{
+			name:    "MalformedJSON",
+			content: `{"summary": "s", "findings": [`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := ParseReport(tt.content, tt.lineCount)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidReport) {
+					t.Fatalf("Expected ErrInvalidReport, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGroupBySeverity(t *testing.T) {
+	findings := []Finding{
+		{Severity: SeverityInfo, Message: "a"},
+		{Severity: SeverityCritical, Message: "b"},
+		{Severity: SeverityInfo, Message: "c"},
+	}
+
+	groups := GroupBySeverity(findings)
+	if len(groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %d", len(groups))
+	}
+	if groups[0].Severity != SeverityCritical || len(groups[0].Findings) != 1 {
+		t.Errorf("Expected the critical group first, got %+v", groups[0])
+	}
+	if groups[1].Severity != SeverityInfo || len(groups[1].Findings) != 2 {
+		t.Errorf("Expected 2 info findings, got %+v", groups[1])
+	}
+}
@@ -0,0 +1,174 @@
+package review
+
+import (
+	"coda/internal/llm"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ModuleLanguage is the language of reviews of several files.
+const ModuleLanguage = "module"
+
+// ManifestEntry describes a file of a module review.
+type ManifestEntry struct {
+	Path     string `json:"path"`
+	Language string `json:"language,omitempty"`
+	Lines    int    `json:"lines"`
+	Tokens   int    `json:"tokens"`   // Estimated number of prompt tokens of the file
+	Included bool   `json:"included"` // False when the file did not fit in the context window
+}
+
+// Manifest lists the files of a module review and which of them fit in the prompt.
+type Manifest struct {
+	Entries []ManifestEntry `json:"entries"`
+	Budget  int             `json:"budget"` // Prompt tokens available for the files
+}
+
+// BuildManifest builds the manifest of the files, including them in path order
+// as long as they fit in the budget. Files that do not fit are skipped so that
+// smaller files after them can still be included.
+func BuildManifest(files []File, budget int) *Manifest {
+	m := &Manifest{Budget: budget}
+	remaining := budget
+	for _, f := range uniqueFiles(files) {
+		entry := ManifestEntry{
+			Path:     f.Path,
+			Language: LanguageFromPath(f.Path),
+			Lines:    strings.Count(f.Content, "\n") + 1,
+			Tokens:   llm.EstimateTokens(renderFile(f)),
+		}
+		if entry.Tokens <= remaining {
+			entry.Included = true
+			remaining -= entry.Tokens
+		}
+		m.Entries = append(m.Entries, entry)
+	}
+	return m
+}
+
+// Included returns the number of files included in the prompt.
+func (m *Manifest) Included() int {
+	n := 0
+	for _, e := range m.Entries {
+		if e.Included {
+			n++
+		}
+	}
+	return n
+}
+
+// Render renders the manifest and the included files for the model.
+// Each file is preceded by its path and its lines are numbered so that
+// findings can refer to them.
+func (m *Manifest) Render(files []File) string {
+	var b strings.Builder
+	b.WriteString("## Manifest\n")
+	for _, e := range m.Entries {
+		fmt.Fprintf(&b, "- %s (%d lines", e.Path, e.Lines)
+		if !e.Included {
+			b.WriteString(", omitted: does not fit in the context window")
+		}
+		b.WriteString(")\n")
+	}
+	b.WriteString("\n")
+
+	for _, f := range uniqueFiles(files) {
+		if e := m.entry(f.Path); e != nil && e.Included {
+			b.WriteString(renderFile(f))
+		}
+	}
+	return b.String()
+}
+
+// MapFindings maps findings back to the files of the module. The file of a
+// finding is resolved against the paths of the manifest and its line range is
+// clamped to that file. Findings without a file are cross-file findings and
+// have no line range.
+func (m *Manifest) MapFindings(findings []Finding) []Finding {
+	paths := make([]string, len(m.Entries))
+	for i, e := range m.Entries {
+		paths[i] = e.Path
+	}
+
+	mapped := make([]Finding, 0, len(findings))
+	for _, f := range findings {
+		i := matchPath(paths, f.File)
+		if i < 0 {
+			f.StartLine, f.EndLine = 0, 0
+			mapped = append(mapped, f)
+			continue
+		}
+
+		f.File = m.Entries[i].Path
+		f.StartLine, f.EndLine = normalizeLineRange(f.StartLine, f.EndLine, m.Entries[i].Lines)
+		mapped = append(mapped, f)
+	}
+	return mapped
+}
+
+// entry returns the manifest entry of a file.
+func (m *Manifest) entry(path string) *ManifestEntry {
+	for i := range m.Entries {
+		if m.Entries[i].Path == path {
+			return &m.Entries[i]
+		}
+	}
+	return nil
+}
+
+// matchPath returns the index of the path a finding refers to, or -1 if there is none.
+// Models may shorten or prefix paths, so paths are also matched by suffix.
+// Findings without a path refer to the only path when there is a single one.
+func matchPath(paths []string, p string) int {
+	p = parseFilePath(p)
+	if p == "" {
+		if len(paths) == 1 {
+			return 0
+		}
+		return -1
+	}
+
+	if i := slices.Index(paths, p); i >= 0 {
+		return i
+	}
+	return slices.IndexFunc(paths, func(candidate string) bool {
+		return strings.HasSuffix(candidate, "/"+p) || strings.HasSuffix(p, "/"+candidate)
+	})
+}
+
+// fileLanguages returns the languages of the files, in order of first appearance.
+func fileLanguages(files []File) []string {
+	var langs []string
+	for _, f := range files {
+		if lang := LanguageFromPath(f.Path); lang != "" && !slices.Contains(langs, lang) {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
+
+// uniqueFiles returns the files sorted by path, keeping the first of files with the same path.
+func uniqueFiles(files []File) []File {
+	sorted := slices.Clone(files)
+	slices.SortStableFunc(sorted, func(a, b File) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return slices.CompactFunc(sorted, func(a, b File) bool {
+		return a.Path == b.Path
+	})
+}
+
+// renderFile renders a file of a module review with numbered lines.
+func renderFile(f File) string {
+	return "### File: " + f.Path + "\n" + NumberLines(f.Content) + "\n"
+}
+
+// promptBudget returns the number of tokens available for the code of a review
+// with the given model, after the system prompt and the room left for the review.
+func promptBudget(model llm.Model, systemPrompt string) int {
+	return model.InputTokenLimit() - llm.EstimateMessageTokens([]llm.Message{
+		{Role: llm.RoleSystem, Content: systemPrompt},
+		{Role: llm.RoleUser},
+	})
+}
@@ -0,0 +1,161 @@
+package review
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadUpload(t *testing.T) {
+	entries := map[string]string{
+		"mod/main.go":           "package main",
+		"mod/util/util.go":      "package util",
+		"mod/.git/config":       "[core]",
+		"mod/node_modules/x.js": "x",
+		"mod/logo.png":          "\x89PNG\x00",
+		"mod/docs/README":       "docs",
+		"mod/large.txt":         strings.Repeat("a", MaxFileSize+1),
+	}
+	want := []string{"mod/docs/README", "mod/main.go", "mod/util/util.go"}
+
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	for name, content := range entries {
+		w, _ := zw.Create(name)
+		_, _ = w.Write([]byte(content))
+	}
+	_ = zw.Close()
+
+	var tarBuf bytes.Buffer
+	gz := gzip.NewWriter(&tarBuf)
+	tw := tar.NewWriter(gz)
+	for name, content := range entries {
+		_ = tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		_, _ = tw.Write([]byte(content))
+	}
+	_ = tw.Close()
+	_ = gz.Close()
+
+	for name, data := range map[string][]byte{"mod.zip": zipBuf.Bytes(), "mod.tar.gz": tarBuf.Bytes()} {
+		t.Run(name, func(t *testing.T) {
+			files, err := ReadUpload(name, bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("ReadUpload() error = %v", err)
+			}
+
+			var got []string
+			for _, f := range uniqueFiles(files) {
+				got = append(got, f.Path)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ReadUpload() paths = %v, want %v", got, want)
+			}
+		})
+	}
+
+	files, err := ReadUpload("dir/main.py", strings.NewReader("print(1)"))
+	if err != nil || len(files) != 1 || files[0].Path != "main.py" {
+		t.Errorf("ReadUpload(main.py) = %+v, %v", files, err)
+	}
+}
+
+func TestReadUploadLimits(t *testing.T) {
+	// Binary entries compress well and are skipped, but still decompressed
+	zeros := make([]byte, MaxFileSize)
+	binaryEntries := MaxArchiveSize/MaxFileSize + 1
+
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	for i := range binaryEntries {
+		w, _ := zw.Create(fmt.Sprintf("bin/%d.dat", i))
+		_, _ = w.Write(zeros)
+	}
+	_ = zw.Close()
+
+	tarGz := func(dir string, n int, size int64) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		for i := range n {
+			_ = tw.WriteHeader(&tar.Header{Name: fmt.Sprintf("%s/%d.dat", dir, i), Mode: 0o644, Size: size, Typeflag: tar.TypeReg})
+			_, _ = io.CopyN(tw, zeroReader{}, size)
+		}
+		_ = tw.Close()
+		_ = gz.Close()
+		return buf.Bytes()
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "binary.zip", data: zipBuf.Bytes()},
+		// Entries larger than MaxFileSize are skipped without being read
+		{name: "large.tar.gz", data: tarGz("big", MaxArchiveSize/(1<<20)+1, 1<<20)},
+		// Entries in hidden directories are skipped
+		{name: "entries.tar.gz", data: tarGz(".git", MaxArchiveEntries+1, 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(tt.data) > MaxUploadSize {
+				t.Fatalf("archive of %d bytes exceeds the upload limit", len(tt.data))
+			}
+			if _, err := ReadUpload(tt.name, bytes.NewReader(tt.data)); !errors.Is(err, ErrInvalidArchive) {
+				t.Errorf("ReadUpload() error = %v, want ErrInvalidArchive", err)
+			}
+		})
+	}
+}
+
+// zeroReader reads an endless stream of zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func TestBuildManifest(t *testing.T) {
+	files := []File{
+		{Path: "b.go", Content: strings.Repeat("x", 400)},
+		{Path: "a.go", Content: "package a\n"},
+		{Path: "c.py", Content: "print(1)"},
+		{Path: "a.go", Content: "duplicate"},
+	}
+
+	m := BuildManifest(files, 20)
+
+	want := []ManifestEntry{
+		{Path: "a.go", Language: "go", Lines: 2, Tokens: 10, Included: true},
+		{Path: "b.go", Language: "go", Lines: 1, Tokens: 121},
+		{Path: "c.py", Language: "python", Lines: 1, Tokens: 9, Included: true},
+	}
+	if !reflect.DeepEqual(m.Entries, want) {
+		t.Errorf("BuildManifest() = %+v, want %+v", m.Entries, want)
+	}
+
+	rendered := m.Render(files)
+	if !strings.Contains(rendered, "- b.go (1 lines, omitted") || strings.Contains(rendered, "### File: b.go") {
+		t.Errorf("Render() did not omit b.go:\n%s", rendered)
+	}
+
+	findings := m.MapFindings([]Finding{
+		{File: "src/c.py", StartLine: 1, EndLine: 9, Message: "Clamped"},
+		{StartLine: 3, Message: "Cross-file"},
+	})
+	wantFindings := []Finding{
+		{File: "c.py", StartLine: 1, EndLine: 1, Message: "Clamped"},
+		{Message: "Cross-file"},
+	}
+	if !reflect.DeepEqual(findings, wantFindings) {
+		t.Errorf("MapFindings() = %+v, want %+v", findings, wantFindings)
+	}
+}
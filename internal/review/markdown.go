@@ -0,0 +1,64 @@
+package review
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Markdown renders the review as a Markdown document.
+// Findings of a structured review are listed after the summary, grouped by severity.
+func (r *Review) Markdown() string {
+	var b strings.Builder
+	b.WriteString(strings.TrimSpace(r.Result))
+	b.WriteString("\n")
+
+	for _, group := range GroupBySeverity(r.Findings) {
+		fmt.Fprintf(&b, "\n## %s (%d)\n\n", severityTitle(group.Severity), len(group.Findings))
+		for _, f := range group.Findings {
+			b.WriteString("- **" + string(f.Category) + "**")
+			if location := findingLocation(f); location != "" {
+				b.WriteString(" (" + location + ")")
+			}
+			b.WriteString(": " + indentContinuation(f.Message) + "\n")
+			if f.Suggestion != "" {
+				b.WriteString("  - Suggestion: " + indentContinuation(f.Suggestion) + "\n")
+			}
+		}
+	}
+
+	return b.String()
+}
+
+// findingLocation returns the file and line range of a finding, such as "main.go:L3-L5".
+func findingLocation(f Finding) string {
+	var lines string
+	switch {
+	case f.StartLine == 0:
+	case f.StartLine == f.EndLine:
+		lines = fmt.Sprintf("L%d", f.StartLine)
+	default:
+		lines = fmt.Sprintf("L%d-L%d", f.StartLine, f.EndLine)
+	}
+
+	switch {
+	case f.File == "":
+		return lines
+	case lines == "":
+		return f.File
+	default:
+		return f.File + ":" + lines
+	}
+}
+
+// severityTitle returns the heading used for a severity.
+func severityTitle(s Severity) string {
+	if s == "" {
+		return ""
+	}
+	return strings.ToUpper(string(s[:1])) + string(s[1:])
+}
+
+// indentContinuation indents the continuation lines of a list item.
+func indentContinuation(text string) string {
+	return strings.ReplaceAll(strings.TrimSpace(text), "\n", "\n    ")
+}
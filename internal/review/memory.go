@@ -0,0 +1,81 @@
+package review
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// MemoryStore is a Store that keeps reviews in memory.
+// It is used where reviews should not outlive the process, such as the command-line client.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	reviews map[string]*Review
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{reviews: map[string]*Review{}}
+}
+
+// Save creates or replaces a review.
+func (s *MemoryStore) Save(_ context.Context, review *Review) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	copied := *review
+	s.reviews[review.ID] = &copied
+	return nil
+}
+
+// Get returns the review with the given ID or ErrNotFound.
+func (s *MemoryStore) Get(_ context.Context, id string) (*Review, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	review, ok := s.reviews[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := *review
+	return &copied, nil
+}
+
+// List returns up to limit reviews of the owner, newest first.
+func (s *MemoryStore) List(_ context.Context, owner string, limit int) ([]*Review, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	reviews := make([]*Review, 0, len(s.reviews))
+	for _, review := range s.reviews {
+		if review.Owner != owner {
+			continue
+		}
+		copied := *review
+		reviews = append(reviews, &copied)
+	}
+
+	// IDs sort in creation order
+	slices.SortFunc(reviews, func(a, b *Review) int {
+		return strings.Compare(b.ID, a.ID)
+	})
+	if limit > 0 && len(reviews) > limit {
+		reviews = reviews[:limit]
+	}
+	return reviews, nil
+}
+
+// Delete removes the review with the given ID or returns ErrNotFound.
+func (s *MemoryStore) Delete(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.reviews[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.reviews, id)
+	return nil
+}
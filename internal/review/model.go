@@ -1,7 +1,11 @@
 package review
 
 import (
+	"coda/internal/llm"
+	"strings"
 	"time"
+
+	"github.com/gofrs/uuid/v5"
 )
 
 // Review represents a code review entry.
@@ -12,24 +16,59 @@ type Review struct {
 	Language    string    `json:"language"`
 	DetailLevel string    `json:"detailLevel"`
 	Strictness  string    `json:"strictness"`
-	Result      string    `json:"result"`
+	Model       string    `json:"model"`
+	Result      string    `json:"result"`             // Markdown review, or the summary of a structured review
+	Findings    []Finding `json:"findings,omitempty"` // Findings of a structured review
+	Files       []File    `json:"files,omitempty"`    // Files of a module review
+	Manifest    *Manifest `json:"manifest,omitempty"` // Files of a module review and whether they were reviewed
+	Cost        *llm.Cost `json:"cost,omitempty"`     // Estimated cost of the completions, nil for unpriced models
+	Owner       string    `json:"owner,omitempty"`    // Caller who requested the review, the only one who can see it in the history
 	CreatedAt   time.Time `json:"createdAt"`
 }
 
 // NewReview creates a new Review from the given parameters.
-func NewReview(code, language, detailLevel, strictness, result string) *Review {
+// Findings are nil for reviews by models that produce free-form Markdown.
+func NewReview(code, language, detailLevel, strictness, model, result string, findings []Finding) *Review {
 	return &Review{
 		ID:          generateID(),
 		Code:        code,
 		Language:    language,
 		DetailLevel: detailLevel,
 		Strictness:  strictness,
+		Model:       model,
 		Result:      result,
+		Findings:    findings,
 		CreatedAt:   time.Now(),
 	}
 }
 
+// Source returns the reviewed code. The files of a module review are
+// concatenated, each preceded by its path.
+func (r *Review) Source() string {
+	if len(r.Files) == 0 {
+		return r.Code
+	}
+
+	var b strings.Builder
+	for i, f := range r.Files {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("==> " + f.Path + " <==\n")
+		b.WriteString(f.Content)
+		if !strings.HasSuffix(f.Content, "\n") {
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
 // generateID generates a unique ID for a review.
+// UUIDv7 IDs sort in creation order, which the store relies on for listing.
 func generateID() string {
-	return time.Now().Format("20060102150405")
+	u, err := uuid.NewV7()
+	if err != nil {
+		return uuid.Must(uuid.NewV4()).String()
+	}
+	return u.String()
 }
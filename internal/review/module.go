@@ -1,6 +1,37 @@
 package review
 
-import "go.uber.org/fx"
+import (
+	"coda/internal/config"
+	"context"
+	"fmt"
+
+	"go.uber.org/fx"
+)
 
 // Module is the fx module for the review package.
-var Module = fx.Module("review")
+// It provides the review Service and its Store backed by an embedded database file.
+var Module = fx.Module("review",
+	fx.Provide(newStore),
+	fx.Provide(NewService),
+)
+
+// newStore opens the configured review store and closes it when the application stops.
+func newStore(lc fx.Lifecycle, cfg *config.Config) (Store, error) {
+	path := cfg.Review.StorePath
+	if path == "" {
+		path = DefaultStorePath
+	}
+
+	store, err := NewBoltStore(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening review store %s: %w", path, err)
+	}
+
+	lc.Append(fx.Hook{
+		OnStop: func(_ context.Context) error {
+			return store.Close()
+		},
+	})
+
+	return store, nil
+}
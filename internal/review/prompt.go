@@ -0,0 +1,224 @@
+package review
+
+import (
+	"coda/internal/llm"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// reviewInput is the code of a review as presented to the model.
+// At most one of diff, manifest and chunks is set.
+type reviewInput struct {
+	diff     *Diff     // Parsed diff of a diff review
+	manifest *Manifest // Files of a module review that fit in the prompt
+	chunks   []chunk   // Parts of code too large to be reviewed at once
+}
+
+// buildParams builds the completion parameters for the given review request.
+func buildParams(req Request, in reviewInput) llm.CompleteParams {
+	code := req.Code
+	switch {
+	case in.diff != nil:
+		// Hunks are annotated with new-file line numbers so findings can be mapped back
+		code = in.diff.Annotate()
+	case in.manifest != nil:
+		code = in.manifest.Render(req.Files)
+	case req.Structured():
+		// Line numbers let the model report the line range of each finding
+		code = NumberLines(code)
+	}
+
+	return llm.CompleteParams{
+		Messages: []llm.Message{
+			{
+				Role:    llm.RoleSystem,
+				Content: buildSystemPrompt(req),
+			},
+			{
+				Role:    llm.RoleUser,
+				Content: code,
+			},
+		},
+		JSONMode:       req.Structured(),
+		ResponseSchema: reportSchema(req),
+	}
+}
+
+// buildSystemPrompt builds the system prompt of a review, explaining the input
+// format of diff and module reviews.
+func buildSystemPrompt(req Request) string {
+	language := req.Language
+	if req.IsModule() {
+		if langs := fileLanguages(req.Files); len(langs) > 0 {
+			language = strings.Join(langs, ", ")
+		}
+	}
+
+	// Build the custom prompt for the AI
+	prompt := buildCustomPrompt(language, req.DetailLevel, req.Strictness, req.Structured())
+	switch {
+	case req.IsDiff():
+		prompt += diffInstructions
+	case req.IsModule():
+		prompt += moduleInstructions
+		if req.Structured() {
+			prompt += "Leave the file of cross-file findings empty.\n"
+		}
+	}
+	return prompt
+}
+
+// buildChunkParams builds the completion parameters of the review of the i-th chunk.
+func buildChunkParams(req Request, chunks []chunk, i int) llm.CompleteParams {
+	c := chunks[i]
+	return llm.CompleteParams{
+		Messages: []llm.Message{
+			{
+				Role:    llm.RoleSystem,
+				Content: buildSystemPrompt(req) + fmt.Sprintf(chunkInstructions, i+1, len(chunks), c.StartLine, c.EndLine()),
+			},
+			{
+				Role:    llm.RoleUser,
+				Content: numberChunk(c, strings.Count(req.Code, "\n")+1),
+			},
+		},
+		JSONMode:       req.Structured(),
+		ResponseSchema: reportSchema(req),
+	}
+}
+
+// buildMergeParams builds the completion parameters of the pass merging the
+// reviews of the chunks into a single review.
+func buildMergeParams(req Request, chunkCount int, reviews string) llm.CompleteParams {
+	return llm.CompleteParams{
+		Messages: []llm.Message{
+			{
+				Role:    llm.RoleSystem,
+				Content: buildCustomPrompt(req.Language, req.DetailLevel, req.Strictness, req.Structured()) + fmt.Sprintf(mergeInstructions, chunkCount),
+			},
+			{
+				Role:    llm.RoleUser,
+				Content: reviews,
+			},
+		},
+		JSONMode:       req.Structured(),
+		ResponseSchema: reportSchema(req),
+	}
+}
+
+// reportSchema returns the schema of the report of structured reviews, to which
+// the providers supporting structured outputs constrain the model output.
+func reportSchema(req Request) any {
+	if !req.Structured() {
+		return nil
+	}
+	return json.RawMessage(ReportSchema)
+}
+
+// buildCustomPrompt constructs the AI prompt based on the review parameters.
+// Structured reviews ask for findings in JSON instead of a Markdown review.
+func buildCustomPrompt(language, detailLevel, strictness string, structured bool) string {
+	// Base prompt with language specification
+	customPrompt := systemPrompt + "\nprogramming language: " + language + "\n"
+	if structured {
+		customPrompt += ReportInstructions()
+	} else {
+		customPrompt += "Format your response in Markdown. Use headings, lists, code blocks, etc. to make your review clear and readable.\n\n"
+	}
+
+	// Add detail level instructions
+	customPrompt += getDetailLevelInstructions(detailLevel)
+
+	// Add strictness instructions
+	customPrompt += getStrictnessInstructions(strictness)
+
+	return customPrompt
+}
+
+// getDetailLevelInstructions returns the prompt text for the specified detail level.
+func getDetailLevelInstructions(detailLevel string) string {
+	switch detailLevel {
+	case "low":
+		return "Detail level: Low - Provide a concise overview with only the most important points. Focus on major issues and skip minor details.\n"
+	case "high":
+		return "Detail level: High - Provide an in-depth analysis with detailed explanations and specific improvement suggestions for each issue found.\n"
+	default: // medium
+		return "Detail level: Medium - Provide a balanced review with reasonable detail on important issues.\n"
+	}
+}
+
+// getStrictnessInstructions returns the prompt text for the specified strictness level.
+func getStrictnessInstructions(strictness string) string {
+	switch strictness {
+	case "low":
+		return "Strictness: Low - Focus only on critical issues like bugs, security problems, and major performance concerns. Ignore minor style issues.\n"
+	case "high":
+		return "Strictness: High - Apply strict best practices and standards. Point out all issues including minor style concerns, potential edge cases, and optimization opportunities.\n"
+	default: // medium
+		return "Strictness: Medium - Apply reasonable standards focusing on important issues while mentioning some style and optimization concerns.\n"
+	}
+}
+
+// diffInstructions explains the annotated diff format to the model.
+const diffInstructions = `Input: The code is a unified diff of changes to one or more files.
+Each file starts with a "### File:" line followed by its hunks. Lines starting with "+" were added, lines starting with "-" were removed and other lines are unchanged context.
+The number after the marker is the line number in the new version of the file; removed lines have none.
+Review only the added lines. Use the context and removed lines to understand the change, but do not report issues in code that was not changed.
+Refer to each issue by its file path and the new-file line numbers of the added lines it is about.
+`
+
+// chunkInstructions explains to the model that it reviews a part of the code.
+const chunkInstructions = `Input: The code is part %d of %d of a file too large to be reviewed at once, lines %d to %d.
+The other parts are reviewed separately: only review this part, and do not report code that is missing from it.
+`
+
+// mergeInstructions asks the model to merge the reviews of the parts of the code.
+const mergeInstructions = `Input: The code was too large to be reviewed at once, so its %d parts were reviewed separately. The input contains the reviews of the parts.
+Merge them into a single review of the whole code: remove duplicate findings, combine findings about the same issue and write an overall summary.
+Keep the line numbers of the findings and do not report issues that are not in the input.
+`
+
+// agentInstructions tells the model about the tools reading the repository of
+// the reviewed code.
+const agentInstructions = `Tools: The code belongs to a repository you can read with the tools: read files, list directories, search with grep and show git blame.
+When the code uses functions, types or constants defined elsewhere, look them up before reporting issues that depend on them, instead of guessing.
+Only call the tools you need. Report issues in the reviewed code only, not in the files you read.
+`
+
+// moduleInstructions explains the format of module reviews to the model.
+const moduleInstructions = `Input: The code is a module made of several files. It starts with a manifest listing the files, followed by each file after a "### File:" line, with line numbers.
+Files marked as omitted in the manifest did not fit and are not shown; do not review them.
+Review each file, and also report cross-file issues such as inconsistent interfaces, duplicated logic, circular dependencies or broken contracts between files.
+Refer to each issue in a single file by its file path and line numbers, and name the files involved in cross-file issues.
+`
+
+// systemPrompt is the base prompt for the AI code review system.
+var systemPrompt = `あなたはプログラミングとソフトウェア開発に特化したAIアシスタントです。
+
+提供された入力に対して、以下のように応答してください：
+
+1. コードが提示された場合は、以下の観点からコードレビューを行います：
+   - バグやエラーの可能性
+   - セキュリティ上の問題点
+   - パフォーマンスの最適化
+   - コーディング規約への準拠
+   - 可読性と保守性の向上
+   - ベストプラクティスの適用
+
+2. プログラミングに関する質問の場合は、以下の点を意識して回答します：
+   - 正確で最新の情報
+   - わかりやすい説明と具体例
+   - 複雑な概念の段階的な解説
+   - 適切なコードサンプル（必要に応じて）
+
+常に簡潔かつ具体的に回答し、余計な会話履歴を表示せず、常に新しい質問に対して直接的に応答してください。
+回答はMarkdown形式で提供し、必要に応じてコードブロックやリストを使用して情報を整理してください。
+
+[Important Note]
+1. コードレビュー結果は必ず日本語で返してください
+2. コードが提供されなかった場合は、コードレビューを行わずにエラーメッセージを返してください
+3. コードレビュアーとしての役割に徹し、無関係な情報を提供しないようにしてください
+
+[Settings]
+`
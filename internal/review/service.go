@@ -0,0 +1,463 @@
+package review
+
+import (
+	"coda/internal/agent"
+	"coda/internal/llm"
+	"coda/internal/llm/openai"
+	"coda/internal/logger"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Default values for code review parameters
+const (
+	DefaultLanguage    = "python"
+	DefaultDetailLevel = "medium"
+	DefaultStrictness  = "medium"
+)
+
+// MaxCodeLength is the maximum number of characters of code accepted for a review.
+// Code that does not fit in the context window of the model is reviewed in chunks.
+const MaxCodeLength = 500_000
+
+// DefaultModel is the model used when a review does not specify one.
+var DefaultModel = openai.ModelGPT4o
+
+// ErrUnknownModel is returned when a review requests a model that is not available.
+var ErrUnknownModel = errors.New("unknown model")
+
+// Request contains the parameters of a code review.
+// Requests with files review them as a module instead of the code.
+type Request struct {
+	Code        string
+	Files       []File
+	Language    string // Set to ModuleLanguage for module reviews
+	DetailLevel string // low, medium or high
+	Strictness  string // low, medium or high
+	Model       llm.Model
+	NoCache     bool // Review again instead of reusing the cached completions of an identical request
+}
+
+// context returns the context of the completions of the request.
+func (r Request) context(ctx context.Context) context.Context {
+	if r.NoCache {
+		return llm.WithoutCache(ctx)
+	}
+	return ctx
+}
+
+// Structured reports whether the review is requested as structured findings.
+// Models without JSON output produce a free-form Markdown review instead.
+func (r Request) Structured() bool {
+	return r.Model.Capabilities.SupportsJSON
+}
+
+// IsDiff reports whether the request is a review of the changes in a unified diff.
+func (r Request) IsDiff() bool {
+	return r.Language == DiffLanguage
+}
+
+// IsModule reports whether the request is a review of several files.
+func (r Request) IsModule() bool {
+	return len(r.Files) > 0
+}
+
+// prepare parses the code of a diff review, or budgets the files of a module
+// review against the context window of the model. Code that does not fit in the
+// context window is split into chunks reviewed separately.
+func (r Request) prepare() (reviewInput, error) {
+	switch {
+	case r.IsModule():
+		if len(r.Files) > MaxFiles {
+			return reviewInput{}, ErrTooManyFiles
+		}
+		manifest := BuildManifest(r.Files, promptBudget(r.Model, buildSystemPrompt(r)))
+		if manifest.Included() == 0 {
+			return reviewInput{}, fmt.Errorf("%w: no file fits in the context window of %s", llm.ErrContextLengthExceeded, r.Model.Name)
+		}
+		return reviewInput{manifest: manifest}, nil
+	case r.IsDiff():
+		diff, err := ParseDiff(r.Code)
+		if err != nil {
+			return reviewInput{}, err
+		}
+		return reviewInput{diff: diff}, nil
+	case r.Model.Fits(buildParams(r, reviewInput{}).Messages):
+		return reviewInput{}, nil
+	}
+
+	budget := promptBudget(r.Model, buildSystemPrompt(r)+chunkInstructions)
+	if budget < minChunkTokens {
+		return reviewInput{}, fmt.Errorf("%w: the context window of %s is too small", llm.ErrContextLengthExceeded, r.Model.Name)
+	}
+	chunks := splitChunks(r.Code, budget)
+	if len(chunks) > maxChunks {
+		return reviewInput{}, fmt.Errorf("%w: the code would be reviewed in %d chunks, the limit is %d", llm.ErrContextLengthExceeded, len(chunks), maxChunks)
+	}
+	return reviewInput{chunks: chunks}, nil
+}
+
+// withDefaults returns the request with default values for the omitted parameters.
+// Code that looks like a unified diff is reviewed as a diff when no language is given.
+func (r Request) withDefaults() Request {
+	if r.IsModule() {
+		r.Language = ModuleLanguage
+	}
+	if r.Language == "" {
+		r.Language = DefaultLanguage
+		if LooksLikeDiff(r.Code) {
+			r.Language = DiffLanguage
+		}
+	}
+	if r.DetailLevel == "" {
+		r.DetailLevel = DefaultDetailLevel
+	}
+	if r.Strictness == "" {
+		r.Strictness = DefaultStrictness
+	}
+	if r.Model.Name == "" {
+		r.Model = DefaultModel
+	}
+	return r
+}
+
+// Service runs code reviews with a language model and keeps them in the review history.
+// It is shared by the web UI and the JSON API so that both build the same prompts.
+type Service struct {
+	completer llm.Completer
+	store     Store
+	agent     *agent.Runner
+}
+
+// NewService creates a new Service with the given completer and review store.
+// When runner is not nil, models supporting function calling review code with
+// the tools of the runner.
+func NewService(completer llm.Completer, store Store, runner *agent.Runner) *Service {
+	return &Service{
+		completer: completer,
+		store:     store,
+		agent:     runner,
+	}
+}
+
+// usesAgent reports whether the review is run by the agent, letting the model
+// read the repository of the code. Chunked reviews are not.
+func (s *Service) usesAgent(req Request) bool {
+	return s.agent != nil && req.Model.Capabilities.SupportsFunctions
+}
+
+// runAgent runs the review with the agent and returns its output and cost.
+func (s *Service) runAgent(ctx context.Context, req Request, in reviewInput) (string, *llm.Cost, error) {
+	params := buildParams(req, in)
+	params.Messages[0].Content += agentInstructions
+
+	res, err := s.agent.Run(ctx, params, req.Model)
+	if err != nil {
+		return "", nil, err
+	}
+
+	logger.Info(ctx, "agentic review completed",
+		"model", req.Model.Name,
+		"steps", len(res.Steps)+1,
+		"tokens", res.Tokens)
+	return res.Response.Messages[0].Content, res.Cost, nil
+}
+
+// Models returns the models available for reviews.
+func (s *Service) Models() []llm.Model {
+	return s.completer.GetAvailableModels()
+}
+
+// ResolveModel finds an available model by name or display name.
+// An empty name resolves to DefaultModel.
+func (s *Service) ResolveModel(name string) (llm.Model, error) {
+	if name == "" {
+		return DefaultModel, nil
+	}
+
+	for _, model := range s.Models() {
+		if model.Name == name || model.DisplayName == name {
+			return model, nil
+		}
+	}
+
+	return llm.Model{}, fmt.Errorf("%w: %s", ErrUnknownModel, name)
+}
+
+// Review runs a code review and saves it to the review history.
+func (s *Service) Review(ctx context.Context, req Request) (*Review, error) {
+	ctx = req.context(ctx)
+	req = req.withDefaults()
+	in, err := req.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(in.chunks) > 0 {
+		content, cost, err := s.reviewChunks(ctx, req, in.chunks, nil)
+		if err != nil {
+			return nil, err
+		}
+		return s.save(ctx, req, in, content, cost), nil
+	}
+
+	if s.usesAgent(req) {
+		content, cost, err := s.runAgent(ctx, req, in)
+		if err != nil {
+			return nil, err
+		}
+		return s.save(ctx, req, in, content, cost), nil
+	}
+
+	ret, err := s.completer.Complete(ctx, buildParams(req, in), req.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.save(ctx, req, in, ret.Messages[0].Content, ret.Metadata.Cost), nil
+}
+
+// StreamUpdate is an update of a review being streamed.
+type StreamUpdate struct {
+	// Content is the Markdown generated so far, empty in progress updates
+	Content string
+	// Part and Parts report the progress of the chunks of chunked reviews,
+	// reviewed one at a time before their final pass. Zero in content updates.
+	Part, Parts int
+}
+
+// StreamReview runs a code review, calling fn with the Markdown generated so far
+// as the review is being streamed, and saves it to the review history.
+// Structured reviews cannot be rendered until they are complete, so fn is not
+// called with their content. Chunked reviews report the progress of each chunk,
+// then only their final pass is streamed. Agentic reviews are delivered once complete.
+func (s *Service) StreamReview(ctx context.Context, req Request, fn func(StreamUpdate) error) (*Review, error) {
+	ctx = req.context(ctx)
+	req = req.withDefaults()
+	in, err := req.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(in.chunks) > 0 {
+		content, cost, err := s.reviewChunks(ctx, req, in.chunks, fn)
+		if err != nil {
+			return nil, err
+		}
+		return s.save(ctx, req, in, content, cost), nil
+	}
+
+	// Tool calls are not streamed, so the review of the agent is delivered whole
+	if s.usesAgent(req) {
+		content, cost, err := s.runAgent(ctx, req, in)
+		if err != nil {
+			return nil, err
+		}
+		if !req.Structured() {
+			if err := fn(StreamUpdate{Content: content}); err != nil {
+				return nil, err
+			}
+		}
+		return s.save(ctx, req, in, content, cost), nil
+	}
+
+	var content strings.Builder
+	ret, err := s.completer.Stream(ctx, buildParams(req, in), req.Model, func(chunk llm.StreamChunk) error {
+		content.WriteString(chunk.Delta)
+		if req.Structured() {
+			return nil
+		}
+		return fn(StreamUpdate{Content: content.String()})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.save(ctx, req, in, ret.Messages[0].Content, ret.Metadata.Cost), nil
+}
+
+// reviewChunks reviews code too large for the context window one chunk at a time,
+// then merges the reviews of the chunks in a final pass whose output is returned
+// with the cost of all the passes.
+// When fn is not nil, it is told of each reviewed chunk and the final pass of
+// Markdown reviews is streamed to it.
+func (s *Service) reviewChunks(ctx context.Context, req Request, chunks []chunk, fn func(StreamUpdate) error) (string, *llm.Cost, error) {
+	var cost *llm.Cost
+	reviews := make([]string, len(chunks))
+	for i, c := range chunks {
+		ret, err := s.completer.Complete(ctx, buildChunkParams(req, chunks, i), req.Model)
+		if err != nil {
+			return "", nil, fmt.Errorf("reviewing lines %d-%d: %w", c.StartLine, c.EndLine(), err)
+		}
+		reviews[i] = ret.Messages[0].Content
+		cost = cost.Add(ret.Metadata.Cost)
+
+		// The chunks take minutes to review, so their progress is reported
+		if fn != nil {
+			if err := fn(StreamUpdate{Part: i + 1, Parts: len(chunks)}); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+
+	if req.Structured() {
+		content, mergeCost, err := s.mergeReports(ctx, req, reviews)
+		return content, cost.Add(mergeCost), err
+	}
+
+	var merged strings.Builder
+	for i, c := range chunks {
+		fmt.Fprintf(&merged, "## Part %d (lines %d-%d)\n\n%s\n\n", i+1, c.StartLine, c.EndLine(), strings.TrimSpace(reviews[i]))
+	}
+	params := buildMergeParams(req, len(chunks), merged.String())
+
+	// Keep the reviews of the parts when they are too large to be merged
+	if !req.Model.Fits(params.Messages) {
+		if fn != nil {
+			if err := fn(StreamUpdate{Content: merged.String()}); err != nil {
+				return "", nil, err
+			}
+		}
+		return merged.String(), cost, nil
+	}
+
+	if fn == nil {
+		ret, err := s.completer.Complete(ctx, params, req.Model)
+		if err != nil {
+			return "", nil, fmt.Errorf("merging reviews: %w", err)
+		}
+		return ret.Messages[0].Content, cost.Add(ret.Metadata.Cost), nil
+	}
+
+	var content strings.Builder
+	ret, err := s.completer.Stream(ctx, params, req.Model, func(chunk llm.StreamChunk) error {
+		content.WriteString(chunk.Delta)
+		return fn(StreamUpdate{Content: content.String()})
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("merging reviews: %w", err)
+	}
+	return ret.Messages[0].Content, cost.Add(ret.Metadata.Cost), nil
+}
+
+// mergeReports merges the structured reviews of the chunks of the code. The
+// findings are deduplicated locally, then the model merges related findings and
+// summarizes the review. The locally merged report is used when the model fails
+// to produce a valid report or the reports are too large for it. The cost of
+// the merge is returned with the report.
+func (s *Service) mergeReports(ctx context.Context, req Request, reviews []string) (string, *llm.Cost, error) {
+	lineCount := strings.Count(req.Code, "\n") + 1
+
+	var (
+		summaries []string
+		findings  []Finding
+	)
+	for i, content := range reviews {
+		report, err := ParseReport(content, lineCount)
+		if err != nil {
+			logger.Warn(ctx, "failed to parse chunk review report", "model", req.Model.Name, "chunk", i+1, "err", err)
+			continue
+		}
+		if report.Summary != "" {
+			summaries = append(summaries, report.Summary)
+		}
+		findings = append(findings, report.Findings...)
+	}
+
+	merged, err := json.Marshal(Report{
+		Summary:  strings.Join(summaries, "\n\n"),
+		Findings: dedupeFindings(findings),
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("merging reviews: %w", err)
+	}
+
+	params := buildMergeParams(req, len(reviews), string(merged))
+	if !req.Model.Fits(params.Messages) {
+		return string(merged), nil, nil
+	}
+
+	ret, err := s.completer.Complete(ctx, params, req.Model)
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", nil, err
+		}
+		logger.Warn(ctx, "failed to merge chunk reviews, using the local merge", "model", req.Model.Name, "err", err)
+		return string(merged), nil, nil
+	}
+
+	content := ret.Messages[0].Content
+	if _, err := ParseReport(content, lineCount); err != nil {
+		logger.Warn(ctx, "failed to parse merged review report, using the local merge", "model", req.Model.Name, "err", err)
+		return string(merged), ret.Metadata.Cost, nil
+	}
+	return content, ret.Metadata.Cost, nil
+}
+
+// Get returns a review from the review history of the caller of the context.
+// The reviews of other callers are not found.
+func (s *Service) Get(ctx context.Context, id string) (*Review, error) {
+	review, err := s.store.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if review.Owner != llm.CallerFromContext(ctx) {
+		return nil, ErrNotFound
+	}
+	return review, nil
+}
+
+// List returns up to limit reviews from the review history of the caller of
+// the context, newest first.
+func (s *Service) List(ctx context.Context, limit int) ([]*Review, error) {
+	return s.store.List(ctx, llm.CallerFromContext(ctx), limit)
+}
+
+// Delete removes a review from the review history of the caller of the context.
+func (s *Service) Delete(ctx context.Context, id string) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.store.Delete(ctx, id)
+}
+
+// save builds the review from the model output and its cost, and persists it to the review history.
+// Structured output that cannot be parsed is kept as a Markdown review, and findings
+// of diff and module reviews are mapped back to their files and lines.
+// A failure to save is logged but does not prevent the review from being returned.
+func (s *Service) save(ctx context.Context, req Request, in reviewInput, content string, cost *llm.Cost) *Review {
+	result := content
+	var findings []Finding
+	if req.Structured() {
+		// Line numbers of diff and module findings are checked against their files
+		lineCount := strings.Count(req.Code, "\n") + 1
+		if in.diff != nil || in.manifest != nil {
+			lineCount = 0
+		}
+
+		report, err := ParseReport(content, lineCount)
+		if err != nil {
+			logger.Warn(ctx, "failed to parse review report, falling back to markdown", "model", req.Model.Name, "err", err)
+		} else {
+			result, findings = report.Summary, report.Findings
+			switch {
+			case in.diff != nil:
+				findings = in.diff.MapFindings(findings)
+			case in.manifest != nil:
+				findings = in.manifest.MapFindings(findings)
+			}
+		}
+	}
+
+	review := NewReview(req.Code, req.Language, req.DetailLevel, req.Strictness, req.Model.DisplayName, result, findings)
+	review.Files, review.Manifest = req.Files, in.manifest
+	review.Cost = cost
+	review.Owner = llm.CallerFromContext(ctx)
+	if err := s.store.Save(ctx, review); err != nil {
+		logger.Error(ctx, "failed to save review", "err", err)
+	}
+	return review
+}
@@ -0,0 +1,32 @@
+package review
+
+import (
+	"coda/internal/llm"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestServiceHistoryOwners(t *testing.T) {
+	svc := NewService(nil, NewMemoryStore(), nil)
+	mine := llm.WithCaller(context.Background(), "key:a")
+	theirs := llm.WithCaller(context.Background(), "ip:192.0.2.1")
+
+	rv := svc.save(mine, Request{Code: "x = 1", Language: "python", Model: DefaultModel}, reviewInput{}, "# ok", nil)
+
+	if _, err := svc.Get(mine, rv.ID); err != nil {
+		t.Errorf("Get() by the owner error = %v", err)
+	}
+	if _, err := svc.Get(theirs, rv.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() by another caller error = %v, want ErrNotFound", err)
+	}
+	if reviews, _ := svc.List(theirs, 0); len(reviews) != 0 {
+		t.Errorf("List() by another caller = %d reviews, want 0", len(reviews))
+	}
+	if err := svc.Delete(theirs, rv.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete() by another caller error = %v, want ErrNotFound", err)
+	}
+	if reviews, _ := svc.List(mine, 0); len(reviews) != 1 {
+		t.Errorf("List() by the owner = %d reviews, want 1", len(reviews))
+	}
+}
@@ -0,0 +1,22 @@
+package review
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotFound is returned when a review does not exist in the store.
+var ErrNotFound = errors.New("review not found")
+
+// Store persists code reviews.
+type Store interface {
+	// Save creates or replaces a review.
+	Save(ctx context.Context, review *Review) error
+	// Get returns the review with the given ID or ErrNotFound.
+	Get(ctx context.Context, id string) (*Review, error)
+	// List returns up to limit reviews of the owner, newest first.
+	// A limit of zero or less returns all of them.
+	List(ctx context.Context, owner string, limit int) ([]*Review, error)
+	// Delete removes the review with the given ID or returns ErrNotFound.
+	Delete(ctx context.Context, id string) error
+}
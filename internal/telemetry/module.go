@@ -0,0 +1,43 @@
+package telemetry
+
+import (
+	"coda/internal/config"
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.uber.org/fx"
+)
+
+// Module is the fx module for the telemetry package.
+// It installs the tracer provider when tracing is configured and sends the
+// buffered spans when the application stops.
+var Module = fx.Module("telemetry",
+	fx.Invoke(registerTracerProvider),
+)
+
+// registerTracerProvider installs the global tracer provider and propagator.
+func registerTracerProvider(lc fx.Lifecycle, cfg *config.Config) error {
+	// Propagate the trace context of the callers even when not exporting
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if !cfg.Tracing.IsConfigured() {
+		return nil
+	}
+
+	tp, err := NewTracerProvider(context.Background(), cfg)
+	if err != nil {
+		return err
+	}
+	otel.SetTracerProvider(tp)
+
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			return tp.Shutdown(ctx)
+		},
+	})
+	return nil
+}
@@ -0,0 +1,80 @@
+// Package telemetry sets up the OpenTelemetry tracing of the application.
+// Spans are created with the global tracer provider, which does nothing
+// until NewTracerProvider's provider is installed.
+package telemetry
+
+import (
+	"coda/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// DefaultServiceName is the service name of the spans when none is configured.
+const DefaultServiceName = "coda"
+
+// ErrInvalidEndpoint is returned when the collector URL is not an absolute HTTP(S) URL.
+var ErrInvalidEndpoint = errors.New("invalid OTLP endpoint")
+
+// NewTracerProvider creates a tracer provider batching the spans to the
+// OTLP/HTTP collector of the configuration.
+func NewTracerProvider(ctx context.Context, cfg *config.Config) (*sdktrace.TracerProvider, error) {
+	endpoint, err := tracesURL(cfg.Tracing.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf("creating OTLP exporter: %w", err)
+	}
+
+	return newTracerProvider(cfg, sdktrace.WithBatcher(exporter))
+}
+
+// newTracerProvider creates a tracer provider sampling the traces started by
+// the application at the configured ratio, and those of the callers at their
+// own sampling decision.
+func newTracerProvider(cfg *config.Config, opts ...sdktrace.TracerProviderOption) (*sdktrace.TracerProvider, error) {
+	name := cfg.Tracing.ServiceName
+	if name == "" {
+		name = DefaultServiceName
+	}
+	ratio := 1.0
+	if cfg.Tracing.SampleRatio != nil {
+		ratio = *cfg.Tracing.SampleRatio
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(
+		attribute.String("service.name", name),
+		attribute.String("deployment.environment", string(cfg.Global.Env)),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("creating tracing resource: %w", err)
+	}
+
+	opts = append(opts,
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	return sdktrace.NewTracerProvider(opts...), nil
+}
+
+// tracesURL returns the URL of the traces path of the collector base URL,
+// as OTEL_EXPORTER_OTLP_ENDPOINT is defined.
+func tracesURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidEndpoint, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%w: %s is not an HTTP URL", ErrInvalidEndpoint, endpoint)
+	}
+	return u.JoinPath("v1", "traces").String(), nil
+}
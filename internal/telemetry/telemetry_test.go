@@ -0,0 +1,80 @@
+package telemetry
+
+import (
+	"coda/internal/config"
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracesURL(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+		wantErr  error
+	}{
+		{endpoint: "http://localhost:4318", want: "http://localhost:4318/v1/traces"},
+		{endpoint: "https://collector.example.com/otlp/", want: "https://collector.example.com/otlp/v1/traces"},
+		{endpoint: "localhost:4318", wantErr: ErrInvalidEndpoint},
+		{endpoint: "grpc://localhost:4317", wantErr: ErrInvalidEndpoint},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			got, err := tracesURL(tt.endpoint)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("tracesURL() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("tracesURL() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewTracerProvider(t *testing.T) {
+	none := 0.0
+	tests := []struct {
+		name      string
+		ratio     *float64
+		wantSpans int
+	}{
+		{name: "default ratio", wantSpans: 1},
+		{name: "zero ratio", ratio: &none, wantSpans: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exporter := tracetest.NewInMemoryExporter()
+			tp, err := newTracerProvider(&config.Config{
+				Global:  config.Global{Env: config.ENVProduction},
+				Tracing: config.Tracing{ServiceName: "coda-test", SampleRatio: tt.ratio},
+			}, sdktrace.WithSyncer(exporter))
+			if err != nil {
+				t.Fatalf("newTracerProvider() error = %v", err)
+			}
+
+			_, span := tp.Tracer("test").Start(context.Background(), "review")
+			span.End()
+
+			spans := exporter.GetSpans()
+			if len(spans) != tt.wantSpans {
+				t.Fatalf("spans = %d, want %d", len(spans), tt.wantSpans)
+			}
+			if len(spans) == 0 {
+				return
+			}
+			attrs := attribute.NewSet(spans[0].Resource.Attributes()...)
+			if v, _ := attrs.Value("service.name"); v.AsString() != "coda-test" {
+				t.Errorf("service.name = %q, want coda-test", v.AsString())
+			}
+			if v, _ := attrs.Value("deployment.environment"); v.AsString() != "production" {
+				t.Errorf("deployment.environment = %q, want production", v.AsString())
+			}
+		})
+	}
+}